@@ -9,7 +9,9 @@ import (
 	"github.com/joho/godotenv"
 
 	"tessellate-projects/internal/api"
+	"tessellate-projects/internal/attest"
 	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/storage"
 )
 
 func main() {
@@ -22,6 +24,18 @@ func main() {
 	// Initialize database
 	database := db.InitDB()
 
+	// Initialize object storage for evidence files
+	objects, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Load the key project sign-offs are signed with
+	signer, err := attest.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
 	// Set gin mode based on environment
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -33,8 +47,9 @@ func main() {
 	// Add CORS middleware for development
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -50,7 +65,7 @@ func main() {
 	})
 
 	// Setup API routes
-	api.SetupRoutes(router, database)
+	api.SetupRoutes(router, database, objects, signer)
 
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProjectCompliance handles GET /api/v1/projects/:id/compliance
+//
+// Counts the project's requirements by assessment result. Sections are not
+// assessed and are left out. Compliance is the share of applicable
+// requirements, those not NOT_APPLICABLE, that are met directly or through
+// a compensating control, and is left out while none is applicable.
+func (h *RequirementHandler) GetProjectCompliance(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	var statuses []db.RequirementStatus
+	if err := h.conn(c).Model(&db.Requirement{}).Where("project_id = ? AND section = ?", project.ID, false).
+		Pluck("status", &statuses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project requirements",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := models.ProjectComplianceResponse{
+		ProjectID: project.ID,
+		ByStatus:  map[string]int{},
+	}
+	for _, status := range db.RequirementStatuses {
+		response.ByStatus[string(status)] = 0
+	}
+	for _, status := range statuses {
+		response.TotalRequirements++
+		response.ByStatus[string(status)]++
+		if status == db.RequirementStatusNotApplicable {
+			continue
+		}
+		response.ApplicableRequirements++
+		if satisfied(status) {
+			response.MetRequirements++
+		}
+	}
+	if response.ApplicableRequirements > 0 {
+		compliance := percentage(response.MetRequirements, response.ApplicableRequirements)
+		response.Compliance = &compliance
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// satisfied reports whether an assessment result counts as met.
+func satisfied(status db.RequirementStatus) bool {
+	return status == db.RequirementStatusMet || status == db.RequirementStatusCompensatingControl
+}
+
+// needsRationale reports whether a requirement may only be assessed with the
+// given result when the assessment says why.
+func needsRationale(status db.RequirementStatus) bool {
+	return status == db.RequirementStatusNotApplicable || status == db.RequirementStatusCompensatingControl
+}
+
+// checkRationale writes a 400 response when a requirement is given a result
+// that needs a rationale without one.
+func checkRationale(c *gin.Context, status db.RequirementStatus, rationale *string) bool {
+	if needsRationale(status) && (rationale == nil || strings.TrimSpace(*rationale) == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "a " + string(status) + " requirement needs a rationale",
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
+
+// checkSectionStatus writes a 400 response when a section is given a result;
+// sections are not assessed and stay NOT_TESTED.
+func checkSectionStatus(c *gin.Context, section bool, status db.RequirementStatus) bool {
+	if section && status != db.RequirementStatusNotTested {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "sections are not assessed; their status stays NOT_TESTED",
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
+
+// checkAssessment returns a PreconditionError unless the requirement may be
+// assessed with the given result: sections are not assessed at all,
+// (partially) met results need its audit tasks reviewed, and those needing a
+// rationale need the one given with the transition or one already on the
+// requirement.
+func checkAssessment(database *db.Database, id uint, to db.RequirementStatus, rationale string) error {
+	var requirement db.Requirement
+	if err := database.First(&requirement, id).Error; err != nil {
+		return err
+	}
+	if requirement.Section && to != db.RequirementStatusNotTested {
+		return &workflow.PreconditionError{Reason: "sections are not assessed"}
+	}
+
+	if needsRationale(to) && strings.TrimSpace(rationale) == "" {
+		if requirement.Rationale == nil || strings.TrimSpace(*requirement.Rationale) == "" {
+			return &workflow.PreconditionError{Reason: "a " + string(to) + " requirement needs a rationale"}
+		}
+	}
+
+	switch to {
+	case db.RequirementStatusMet, db.RequirementStatusPartiallyMet, db.RequirementStatusCompensatingControl:
+		return checkRequirementReviewed(database, id)
+	}
+	return nil
+}
+
+// combineResults sums up the assessment results of several requirements.
+// NOT_APPLICABLE ones are left out, and when nothing is left the result is
+// NOT_APPLICABLE. Otherwise it is MET when all are met, or
+// COMPENSATING_CONTROL when some only through a compensating control;
+// PARTIALLY_MET when any is at least partially met; NOT_TESTED when none
+// has been tested; and NOT_MET otherwise.
+func combineResults(results []db.RequirementStatus) db.RequirementStatus {
+	var applicable, met, partial, untested int
+	compensated := false
+	for _, result := range results {
+		switch result {
+		case db.RequirementStatusNotApplicable:
+			continue
+		case db.RequirementStatusCompensatingControl:
+			compensated = true
+			met++
+		case db.RequirementStatusMet:
+			met++
+		case db.RequirementStatusPartiallyMet:
+			partial++
+		case db.RequirementStatusNotTested:
+			untested++
+		}
+		applicable++
+	}
+
+	switch {
+	case applicable == 0:
+		return db.RequirementStatusNotApplicable
+	case met == applicable && compensated:
+		return db.RequirementStatusCompensatingControl
+	case met == applicable:
+		return db.RequirementStatusMet
+	case met > 0 || partial > 0:
+		return db.RequirementStatusPartiallyMet
+	case untested == applicable:
+		return db.RequirementStatusNotTested
+	}
+	return db.RequirementStatusNotMet
+}
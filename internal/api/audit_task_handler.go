@@ -3,32 +3,58 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"tessellate-projects/internal/db"
 	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // AuditTaskHandler
 type AuditTaskHandler struct {
-	db *db.Database
+	store
 }
 
 func NewAuditTaskHandler(database *db.Database) *AuditTaskHandler {
-	return &AuditTaskHandler{db: database}
+	return &AuditTaskHandler{store{db: database}}
 }
 
 func (h *AuditTaskHandler) GetAuditTasks(c *gin.Context) {
 	var tasks []db.AuditTask
 
+	preloads, err := parseIncludes(c, "auditTask", "issues")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	// Optional requirement filter
 	requirementID := c.Query("requirementId")
-	query := h.db.Preload("Issue")
+	query := withPreloads(h.conn(c).DB, preloads, "")
 
 	if requirementID != "" {
 		query = query.Where("requirement_id = ?", requirementID)
 	}
+	// Tasks relying on evidence that has expired
+	if c.Query("staleEvidence") == "true" {
+		query = query.Where(staleEvidenceExists, time.Now().UTC())
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where(overdueAuditTask, time.Now().UTC())
+	}
+	if assigneeID := c.Query("assigneeId"); assigneeID != "" {
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+	if reviewerID := c.Query("reviewerId"); reviewerID != "" {
+		query = query.Where("reviewer_id = ?", reviewerID)
+	}
 
 	if err := query.Find(&tasks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -40,10 +66,17 @@ func (h *AuditTaskHandler) GetAuditTasks(c *gin.Context) {
 
 	response := make([]models.AuditTaskResponse, len(tasks))
 	for i, task := range tasks {
-		response[i] = h.convertToAuditTaskResponse(&task)
+		response[i] = convertToAuditTaskResponse(&task)
+	}
+	if err := flagStaleEvidence(h.conn(c), response); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check evidence expiry",
+			Code:  http.StatusInternalServerError,
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
@@ -56,13 +89,7 @@ func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
 		return
 	}
 
-	type CreateAuditTaskRequest struct {
-		Text   string  `json:"text" binding:"required"`
-		Status *string `json:"status,omitempty"`
-		Notes  *string `json:"notes,omitempty"`
-	}
-
-	var req CreateAuditTaskRequest
+	var req models.CreateAuditTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Invalid request",
@@ -74,7 +101,7 @@ func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
 
 	// Verify requirement exists
 	var requirement db.Requirement
-	if err := h.db.First(&requirement, requirementID).Error; err != nil {
+	if err := h.conn(c).First(&requirement, requirementID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Requirement not found",
 			Code:  http.StatusNotFound,
@@ -82,9 +109,17 @@ func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
 		return
 	}
 
-	status := "PENDING"
+	status := db.AuditTaskStatusPending
 	if req.Status != nil {
-		status = *req.Status
+		status = db.AuditTaskStatus(*req.Status)
+	}
+	if err := checkInitialStatus(h.conn(c), workflow.EntityAuditTask, requirement.ProjectID, string(status)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+	if !checkAssignment(c, h.conn(c), requirement.ProjectID, req.AssigneeID, req.ReviewerID, nil, nil) ||
+		!checkReviewerAssignment(c, h.conn(c), requirement.ProjectID, &db.AuditTask{AssigneeID: req.AssigneeID}, req.ReviewerID) {
+		return
 	}
 
 	task := db.AuditTask{
@@ -92,9 +127,12 @@ func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
 		Text:          req.Text,
 		Status:        status,
 		Notes:         req.Notes,
+		DueDate:       utcTime(req.DueDate),
+		AssigneeID:    req.AssigneeID,
+		ReviewerID:    req.ReviewerID,
 	}
 
-	if err := h.db.Create(&task).Error; err != nil {
+	if err := h.conn(c).Create(&task).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create audit task",
 			Code:  http.StatusInternalServerError,
@@ -102,7 +140,7 @@ func (h *AuditTaskHandler) CreateAuditTask(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToAuditTaskResponse(&task)
+	response := convertToAuditTaskResponse(&task)
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -116,8 +154,18 @@ func (h *AuditTaskHandler) GetAuditTask(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "auditTask", "issues")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var task db.AuditTask
-	if err := h.db.Preload("Issue").First(&task, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&task, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Audit task not found",
 			Code:  http.StatusNotFound,
@@ -125,8 +173,16 @@ func (h *AuditTaskHandler) GetAuditTask(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToAuditTaskResponse(&task)
-	c.JSON(http.StatusOK, response)
+	response := []models.AuditTaskResponse{convertToAuditTaskResponse(&task)}
+	if err := flagStaleEvidence(h.conn(c), response); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check evidence expiry",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	renderResource(c, http.StatusOK, response[0])
 }
 
 func (h *AuditTaskHandler) UpdateAuditTask(c *gin.Context) {
@@ -139,24 +195,8 @@ func (h *AuditTaskHandler) UpdateAuditTask(c *gin.Context) {
 		return
 	}
 
-	type UpdateAuditTaskRequest struct {
-		Text   *string `json:"text,omitempty"`
-		Status *string `json:"status,omitempty"`
-		Notes  *string `json:"notes,omitempty"`
-	}
-
-	var req UpdateAuditTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var task db.AuditTask
-	if err := h.db.First(&task, id).Error; err != nil {
+	if err := h.conn(c).First(&task, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Audit task not found",
 			Code:  http.StatusNotFound,
@@ -164,18 +204,57 @@ func (h *AuditTaskHandler) UpdateAuditTask(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Text != nil {
-		task.Text = *req.Text
-	}
-	if req.Status != nil {
-		task.Status = *req.Status
+	var req models.UpdateAuditTaskRequest
+	if !bindUpdate(c, toUpdateAuditTaskRequest(&task), &req) {
+		return
 	}
-	if req.Notes != nil {
-		task.Notes = req.Notes
+
+	// The reviewer is checked against the task as it will be assigned
+	assigned := task
+	assigned.AssigneeID = req.AssigneeID
+	projectID := projectIDFor(h.conn(c), workflow.EntityAuditTask, task.ID)
+	if !checkAssignment(c, h.conn(c), projectID, req.AssigneeID, req.ReviewerID, task.AssigneeID, task.ReviewerID) ||
+		!checkReviewerAssignment(c, h.conn(c), projectID, &assigned, req.ReviewerID) {
+		return
 	}
 
-	if err := h.db.Save(&task).Error; err != nil {
+	task.Text = req.Text
+	task.Notes = req.Notes
+	task.DueDate = utcTime(req.DueDate)
+	task.AssigneeID = req.AssigneeID
+	task.ReviewerID = req.ReviewerID
+	task.ReviewNotes = req.ReviewNotes
+
+	var user *db.User
+	from := string(task.Status)
+	fields := map[string]string{}
+	if db.AuditTaskStatus(req.Status) != task.Status {
+		var ok bool
+		if user, ok = requireUser(c, h.conn(c)); !ok {
+			return
+		}
+		if req.ReviewNotes != nil {
+			fields["reviewNotes"] = *req.ReviewNotes
+		}
+		applyReview(&task, user, req.Status, fields, time.Now().UTC())
+	}
+	task.Status = db.AuditTaskStatus(req.Status)
+
+	// The transition is only recorded if the task saves with it
+	var transitionErr error
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if user != nil {
+			if _, transitionErr = changeStatus(&db.Database{DB: tx}, user, workflow.EntityAuditTask, task.ID, from, req.Status, fields); transitionErr != nil {
+				return transitionErr
+			}
+		}
+		return tx.Save(&task).Error
+	})
+	if transitionErr != nil {
+		writeTransitionError(c, transitionErr)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update audit task",
 			Code:  http.StatusInternalServerError,
@@ -183,8 +262,16 @@ func (h *AuditTaskHandler) UpdateAuditTask(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToAuditTaskResponse(&task)
-	c.JSON(http.StatusOK, response)
+	response := []models.AuditTaskResponse{convertToAuditTaskResponse(&task)}
+	if err := flagStaleEvidence(h.conn(c), response); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check evidence expiry",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response[0])
 }
 
 func (h *AuditTaskHandler) DeleteAuditTask(c *gin.Context) {
@@ -197,7 +284,7 @@ func (h *AuditTaskHandler) DeleteAuditTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.AuditTask{}, id).Error; err != nil {
+	if err := h.conn(c).Delete(&db.AuditTask{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete audit task",
 			Code:  http.StatusInternalServerError,
@@ -218,8 +305,18 @@ func (h *AuditTaskHandler) GetRequirementAuditTasks(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "auditTask", "issues")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var tasks []db.AuditTask
-	if err := h.db.Where("requirement_id = ?", requirementID).Preload("Issue").Find(&tasks).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Where("requirement_id = ?", requirementID).Find(&tasks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch requirement audit tasks",
 			Code:  http.StatusInternalServerError,
@@ -229,40 +326,44 @@ func (h *AuditTaskHandler) GetRequirementAuditTasks(c *gin.Context) {
 
 	response := make([]models.AuditTaskResponse, len(tasks))
 	for i, task := range tasks {
-		response[i] = h.convertToAuditTaskResponse(&task)
+		response[i] = convertToAuditTaskResponse(&task)
+	}
+	if err := flagStaleEvidence(h.conn(c), response); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check evidence expiry",
+			Code:  http.StatusInternalServerError,
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
-// Helper function to convert db.AuditTask to models.AuditTaskResponse
-func (h *AuditTaskHandler) convertToAuditTaskResponse(task *db.AuditTask) models.AuditTaskResponse {
-	response := models.AuditTaskResponse{
-		ID:            task.ID,
-		RequirementID: task.RequirementID,
-		Text:          task.Text,
-		Status:        task.Status,
-		Notes:         task.Notes,
-		CreatedAt:     task.CreatedAt,
-		UpdatedAt:     task.UpdatedAt,
-	}
-
-	// Convert issue if loaded
-	if task.Issue != nil {
-		response.Issue = &models.IssueResponse{
-			ID:          task.Issue.ID,
-			AuditTaskID: task.Issue.AuditTaskID,
-			Title:       task.Issue.Title,
-			Description: task.Issue.Description,
-			Priority:    task.Issue.Priority,
-			Phase:       task.Issue.Phase,
-			EstimateHrs: task.Issue.EstimateHrs,
-			Status:      task.Issue.Status,
-			Type:        task.Issue.Type,
-			CreatedAt:   task.Issue.CreatedAt,
-			UpdatedAt:   task.Issue.UpdatedAt,
-		}
+// flagStaleEvidence sets StaleEvidence on the tasks linked to current
+// evidence that has expired.
+func flagStaleEvidence(database *db.Database, tasks []models.AuditTaskResponse) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	var stale []uint
+	if err := database.Model(&db.AuditTask{}).
+		Where("id IN ?", ids).
+		Where(staleEvidenceExists, time.Now().UTC()).
+		Pluck("id", &stale).Error; err != nil {
+		return err
 	}
 
-	return response
+	staleIDs := make(map[uint]bool, len(stale))
+	for _, id := range stale {
+		staleIDs[id] = true
+	}
+	for i := range tasks {
+		tasks[i].StaleEvidence = staleIDs[tasks[i].ID]
+	}
+	return nil
 }
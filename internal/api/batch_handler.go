@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// refPattern matches references to earlier batch results, e.g. $ref:0.id or
+// $ref:2.auditTasks.0.id.
+var refPattern = regexp.MustCompile(`\$ref:(\d+)((?:\.[A-Za-z0-9_]+)+)`)
+
+// errBatchFailed rolls back the batch transaction when an operation fails.
+var errBatchFailed = errors.New("batch operation failed")
+
+// BatchHandler runs several API operations in a single transaction
+type BatchHandler struct {
+	store
+	router http.Handler
+}
+
+func NewBatchHandler(database *db.Database, router http.Handler) *BatchHandler {
+	return &BatchHandler{store: store{db: database}, router: router}
+}
+
+// ExecuteBatch handles POST /api/v1/batch
+//
+// Operations are dispatched through the router in order, inside one
+// database transaction. Later operations may refer to the results of
+// earlier ones with $ref:<index>.<field>. If any operation fails the whole
+// batch is rolled back.
+func (h *BatchHandler) ExecuteBatch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	for i, op := range req.Operations {
+		if !strings.HasPrefix(op.Path, "/api/v1/") || strings.HasPrefix(op.Path, "/api/v1/batch") {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: fmt.Sprintf("operation %d: path must be an /api/v1 resource other than /batch", i),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	response := models.BatchResponse{Results: []models.BatchOperationResult{}}
+	results := make([]interface{}, 0, len(req.Operations))
+
+	var cleanups []func()
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		ctx := withTx(c.Request.Context(), &db.Database{DB: tx}, &cleanups)
+
+		for i, op := range req.Operations {
+			result := models.BatchOperationResult{Index: i}
+
+			path, body, err := resolveRefs(op, results)
+			if err != nil {
+				result.Status = http.StatusBadRequest
+				result.Body, _ = json.Marshal(models.ErrorResponse{
+					Error:   "Invalid reference",
+					Message: err.Error(),
+					Code:    http.StatusBadRequest,
+				})
+				response.Results = append(response.Results, result)
+				response.FailedIndex = &i
+				return errBatchFailed
+			}
+
+			sub, err := http.NewRequestWithContext(ctx, op.Method, path, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			sub.Header.Set("Content-Type", batchContentType(op.Method, body))
+			sub.Header.Set("Authorization", c.GetHeader("Authorization"))
+
+			recorder := httptest.NewRecorder()
+			h.router.ServeHTTP(recorder, sub)
+
+			result.Status = recorder.Code
+			result.Body = recorder.Body.Bytes()
+			response.Results = append(response.Results, result)
+
+			if recorder.Code >= http.StatusBadRequest {
+				response.FailedIndex = &i
+				return errBatchFailed
+			}
+
+			var decoded interface{}
+			json.Unmarshal(recorder.Body.Bytes(), &decoded)
+			results = append(results, decoded)
+		}
+		return nil
+	})
+	// Undo what operations did outside the database, such as storing evidence
+	if err != nil {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}
+
+	switch {
+	case err == nil:
+		response.Committed = true
+		c.JSON(http.StatusOK, response)
+	case errors.Is(err, errBatchFailed):
+		status := response.Results[*response.FailedIndex].Status
+		if status < http.StatusBadRequest {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, response)
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to execute batch",
+			Code:  http.StatusInternalServerError,
+		})
+	}
+}
+
+// batchContentType picks the media type of a sub-request body. PATCH bodies
+// are merge patches unless they are an array of JSON Patch operations.
+func batchContentType(method string, body []byte) string {
+	if method != http.MethodPatch {
+		return "application/json"
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		return jsonPatchContentType
+	}
+	return mergePatchContentType
+}
+
+// resolveRefs substitutes $ref placeholders in an operation's path and body
+// with values from earlier results. A body string consisting solely of a
+// reference is replaced by the referenced value itself, so numeric IDs stay
+// numeric.
+func resolveRefs(op models.BatchOperation, results []interface{}) (string, []byte, error) {
+	path, err := substituteRefs(op.Path, results)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(op.Body) == 0 {
+		return path, nil, nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(op.Body, &body); err != nil {
+		return "", nil, fmt.Errorf("invalid body: %w", err)
+	}
+	body, err = resolveBodyRefs(body, results)
+	if err != nil {
+		return "", nil, err
+	}
+
+	encoded, err := json.Marshal(body)
+	return path, encoded, err
+}
+
+func resolveBodyRefs(value interface{}, results []interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			resolved, err := resolveBodyRefs(item, results)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveBodyRefs(item, results)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	case string:
+		if match := refPattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			return lookupRef(match, results)
+		}
+		return substituteRefs(v, results)
+	default:
+		return v, nil
+	}
+}
+
+func substituteRefs(s string, results []interface{}) (string, error) {
+	var firstErr error
+	out := refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		value, err := lookupRef(refPattern.FindStringSubmatch(ref), results)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ref
+		}
+		if f, ok := value.(float64); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return fmt.Sprint(value)
+	})
+	return out, firstErr
+}
+
+// lookupRef resolves a parsed $ref match against the earlier results.
+func lookupRef(match []string, results []interface{}) (interface{}, error) {
+	index, err := strconv.Atoi(match[1])
+	if err != nil || index >= len(results) {
+		return nil, fmt.Errorf("%s refers to an operation that has not run yet", match[0])
+	}
+
+	value := results[index]
+	for _, key := range strings.Split(strings.TrimPrefix(match[2], "."), ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("%s: index %q out of range", match[0], key)
+			}
+			value = v[i]
+		default:
+			value = nil
+		}
+		if value == nil {
+			return nil, fmt.Errorf("%s does not resolve to a value", match[0])
+		}
+	}
+	return value, nil
+}
@@ -12,26 +12,36 @@ import (
 
 // ClientHandler
 type ClientHandler struct {
-	db *db.Database
+	store
 }
 
 func NewClientHandler(database *db.Database) *ClientHandler {
-	return &ClientHandler{db: database}
+	return &ClientHandler{store{db: database}}
 }
 
 func (h *ClientHandler) GetClients(c *gin.Context) {
+	preloads, err := parseIncludes(c, "client", "users", "projects")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var clients []db.Client
-	if err := h.db.Preload("Users").Preload("Projects").Find(&clients).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Find(&clients).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch clients", Code: 500})
 		return
 	}
 
 	response := make([]models.ClientResponse, len(clients))
 	for i, client := range clients {
-		response[i] = h.convertToClientResponse(&client)
+		response[i] = convertToClientResponse(&client)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *ClientHandler) CreateClient(c *gin.Context) {
@@ -52,7 +62,7 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		ContactEmail: req.ContactEmail,
 	}
 
-	if err := h.db.Create(&client).Error; err != nil {
+	if err := h.conn(c).Create(&client).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create client",
 			Code:  http.StatusInternalServerError,
@@ -60,7 +70,7 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToClientResponse(&client)
+	response := convertToClientResponse(&client)
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -74,8 +84,18 @@ func (h *ClientHandler) GetClient(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "client", "users", "projects")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var client db.Client
-	if err := h.db.Preload("Users").Preload("Projects").First(&client, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&client, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Client not found",
 			Code:  http.StatusNotFound,
@@ -83,8 +103,8 @@ func (h *ClientHandler) GetClient(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToClientResponse(&client)
-	c.JSON(http.StatusOK, response)
+	response := convertToClientResponse(&client)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *ClientHandler) UpdateClient(c *gin.Context) {
@@ -97,18 +117,8 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateClientRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var client db.Client
-	if err := h.db.First(&client, id).Error; err != nil {
+	if err := h.conn(c).First(&client, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Client not found",
 			Code:  http.StatusNotFound,
@@ -116,21 +126,17 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Name != nil {
-		client.Name = *req.Name
-	}
-	if req.Industry != nil {
-		client.Industry = req.Industry
-	}
-	if req.ContactName != nil {
-		client.ContactName = req.ContactName
-	}
-	if req.ContactEmail != nil {
-		client.ContactEmail = req.ContactEmail
+	var req models.UpdateClientRequest
+	if !bindUpdate(c, toUpdateClientRequest(&client), &req) {
+		return
 	}
 
-	if err := h.db.Save(&client).Error; err != nil {
+	client.Name = req.Name
+	client.Industry = req.Industry
+	client.ContactName = req.ContactName
+	client.ContactEmail = req.ContactEmail
+
+	if err := h.conn(c).Save(&client).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update client",
 			Code:  http.StatusInternalServerError,
@@ -138,7 +144,7 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToClientResponse(&client)
+	response := convertToClientResponse(&client)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -152,7 +158,7 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.Client{}, id).Error; err != nil {
+	if err := h.conn(c).Delete(&db.Client{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete client",
 			Code:  http.StatusInternalServerError,
@@ -162,50 +168,3 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully"})
 }
-
-// Helper function to convert db.Client to models.ClientResponse
-func (h *ClientHandler) convertToClientResponse(client *db.Client) models.ClientResponse {
-	response := models.ClientResponse{
-		ID:           client.ID,
-		Name:         client.Name,
-		Industry:     client.Industry,
-		ContactName:  client.ContactName,
-		ContactEmail: client.ContactEmail,
-		CreatedAt:    client.CreatedAt,
-		UpdatedAt:    client.UpdatedAt,
-	}
-
-	// Convert users if loaded
-	if len(client.Users) > 0 {
-		response.Users = make([]models.UserResponse, len(client.Users))
-		for i, user := range client.Users {
-			response.Users[i] = models.UserResponse{
-				ID:        user.ID,
-				Name:      user.Name,
-				Email:     user.Email,
-				Role:      string(user.Role),
-				ClientID:  user.ClientID,
-				CreatedAt: user.CreatedAt,
-				UpdatedAt: user.UpdatedAt,
-			}
-		}
-	}
-
-	// Convert projects if loaded
-	if len(client.Projects) > 0 {
-		response.Projects = make([]models.ProjectResponse, len(client.Projects))
-		for i, project := range client.Projects {
-			response.Projects[i] = models.ProjectResponse{
-				ID:         project.ID,
-				Name:       project.Name,
-				ClientName: project.ClientName,
-				Status:     project.Status,
-				ClientID:   project.ClientID,
-				CreatedAt:  project.CreatedAt,
-				UpdatedAt:  project.UpdatedAt,
-			}
-		}
-	}
-
-	return response
-}
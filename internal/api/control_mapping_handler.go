@@ -0,0 +1,382 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Coverage statuses reported per control by the mapped coverage analysis
+const (
+	coverageMet          = "MET"
+	coveragePartiallyMet = "PARTIALLY_MET"
+	coverageNotMet       = "NOT_MET"
+	coverageNotAddressed = "NOT_ADDRESSED"
+	// The project assessed the control as not applicable
+	coverageNotApplicable = "NOT_APPLICABLE"
+)
+
+// ControlMappingHandler maintains mappings between framework controls and
+// analyses a project's coverage of other frameworks through them
+type ControlMappingHandler struct {
+	store
+}
+
+func NewControlMappingHandler(database *db.Database) *ControlMappingHandler {
+	return &ControlMappingHandler{store{db: database}}
+}
+
+// GetControlMappings handles GET /api/v1/framework-controls/:id/mappings
+//
+// Mappings apply in both directions, so those where the control is the
+// target are listed too.
+func (h *ControlMappingHandler) GetControlMappings(c *gin.Context) {
+	controlID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework control ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var mappings []db.ControlMapping
+	if err := h.conn(c).Preload("SourceControl").Preload("TargetControl").
+		Where("source_control_id = ? OR target_control_id = ?", controlID, controlID).
+		Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch control mappings",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.ControlMappingResponse, len(mappings))
+	for i, mapping := range mappings {
+		response[i] = convertToControlMappingResponse(&mapping)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateControlMapping handles POST /api/v1/framework-controls/:id/mappings
+func (h *ControlMappingHandler) CreateControlMapping(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	controlID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework control ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.CreateControlMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var source, target db.FrameworkControl
+	if err := h.conn(c).First(&source, controlID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework control not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if err := h.conn(c).First(&target, req.TargetControlID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Target framework control not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	if source.FrameworkID == target.FrameworkID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "controls can only be mapped to controls of another framework",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var count int64
+	h.conn(c).Model(&db.ControlMapping{}).
+		Where("(source_control_id = ? AND target_control_id = ?) OR (source_control_id = ? AND target_control_id = ?)",
+			source.ID, target.ID, target.ID, source.ID).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Controls are already mapped",
+			Code:  http.StatusConflict,
+		})
+		return
+	}
+
+	mapping := db.ControlMapping{
+		SourceControlID: source.ID,
+		SourceControl:   &source,
+		TargetControlID: target.ID,
+		TargetControl:   &target,
+		Strength:        db.MappingStrength(req.Strength),
+	}
+
+	if err := h.conn(c).Omit("SourceControl", "TargetControl").Create(&mapping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create control mapping",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToControlMappingResponse(&mapping)
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateControlMapping handles PUT and PATCH /api/v1/control-mappings/:id
+func (h *ControlMappingHandler) UpdateControlMapping(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid control mapping ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var mapping db.ControlMapping
+	if err := h.conn(c).First(&mapping, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Control mapping not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var req models.UpdateControlMappingRequest
+	if !bindUpdate(c, toUpdateControlMappingRequest(&mapping), &req) {
+		return
+	}
+
+	mapping.Strength = db.MappingStrength(req.Strength)
+
+	if err := h.conn(c).Save(&mapping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update control mapping",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToControlMappingResponse(&mapping)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteControlMapping handles DELETE /api/v1/control-mappings/:id
+func (h *ControlMappingHandler) DeleteControlMapping(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid control mapping ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.conn(c).Delete(&db.ControlMapping{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete control mapping",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Control mapping deleted successfully"})
+}
+
+// GetProjectFrameworkCoverage handles GET /api/v1/projects/:id/frameworks/:frameworkId/coverage
+//
+// Coverage of any framework is derived from the assessment results of the
+// project's requirements, see projectControlStatuses. A control is met when
+// its own requirements are or when a FULL mapping links it to a met control;
+// a PARTIAL mapping to a met control makes it partially met. Controls the
+// project assessed as not applicable are left out of the coverage, and
+// every other control that is not met is listed as a gap.
+func (h *ControlMappingHandler) GetProjectFrameworkCoverage(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	frameworkID, err := strconv.ParseUint(c.Param("frameworkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := h.conn(c).Preload("Controls").First(&framework, frameworkID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	statuses, err := projectControlStatuses(h.conn(c), project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project requirements",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	controlIDs := make([]uint, len(framework.Controls))
+	for i, control := range framework.Controls {
+		controlIDs[i] = control.ID
+	}
+
+	var mappings []db.ControlMapping
+	if err := h.conn(c).Preload("SourceControl").Preload("TargetControl").
+		Where("source_control_id IN ? OR target_control_id IN ?", controlIDs, controlIDs).
+		Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch control mappings",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, mappedCoverage(&framework, statuses, mappings))
+}
+
+// mappedCoverage evaluates every control of a framework against the
+// project's control statuses and the mappings touching the framework.
+func mappedCoverage(framework *db.Framework, statuses map[uint]string, mappings []db.ControlMapping) models.MappedCoverageResponse {
+	// Index the project controls mapped onto each control of the framework
+	sources := map[uint][]models.CoverageSourceResponse{}
+	for _, mapping := range mappings {
+		for _, pair := range [][2]*db.FrameworkControl{
+			{mapping.SourceControl, mapping.TargetControl},
+			{mapping.TargetControl, mapping.SourceControl},
+		} {
+			own, other := pair[0], pair[1]
+			if own == nil || other == nil || own.FrameworkID != framework.ID {
+				continue
+			}
+			status, applied := statuses[other.ID]
+			if !applied || status == coverageNotApplicable {
+				continue
+			}
+			sources[own.ID] = append(sources[own.ID], models.CoverageSourceResponse{
+				FrameworkControlID: other.ID,
+				ControlID:          other.ControlID,
+				FrameworkID:        other.FrameworkID,
+				Strength:           string(mapping.Strength),
+				Met:                status == coverageMet,
+			})
+		}
+	}
+
+	response := models.MappedCoverageResponse{
+		FrameworkCoverageResponse: models.FrameworkCoverageResponse{
+			FrameworkID:   framework.ID,
+			Name:          framework.Name,
+			Version:       framework.Version,
+			TotalControls: len(framework.Controls),
+		},
+		Gaps: []models.ControlCoverageResponse{},
+	}
+
+	for _, control := range framework.Controls {
+		status, applied := statuses[control.ID]
+		coverage := models.ControlCoverageResponse{
+			FrameworkControlID: control.ID,
+			ControlID:          control.ControlID,
+			Title:              control.Title,
+			Applied:            applied,
+			Sources:            sources[control.ID],
+			Status:             coverageNotAddressed,
+		}
+		if applied {
+			response.AppliedControls++
+		}
+		if status == coverageNotApplicable {
+			response.NotApplicableControls++
+			continue
+		}
+		met := status == coverageMet
+		if applied || len(coverage.Sources) > 0 {
+			coverage.Status = coverageNotMet
+		}
+		if status == coveragePartiallyMet {
+			coverage.Status = coveragePartiallyMet
+		}
+		for _, source := range coverage.Sources {
+			if !source.Met {
+				continue
+			}
+			if source.Strength == string(db.MappingStrengthFull) {
+				met = true
+			} else {
+				coverage.Status = coveragePartiallyMet
+			}
+		}
+		if met {
+			coverage.Status = coverageMet
+		}
+
+		switch coverage.Status {
+		case coverageMet:
+			response.MetControls++
+		case coveragePartiallyMet:
+			response.PartiallyMetControls++
+			response.Gaps = append(response.Gaps, coverage)
+		default:
+			response.Gaps = append(response.Gaps, coverage)
+		}
+	}
+
+	if applicable := response.TotalControls - response.NotApplicableControls; applicable > 0 {
+		response.Coverage = percentage(response.MetControls, applicable)
+	}
+	return response
+}
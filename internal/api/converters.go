@@ -0,0 +1,816 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/risk"
+)
+
+// The converters below turn database models into API responses. Related
+// entities are converted whenever they have been loaded, so the shape of a
+// response follows whatever the handler preloaded via ?include=.
+
+// convertToProjectResponse converts db.Project to models.ProjectResponse
+func convertToProjectResponse(project *db.Project) models.ProjectResponse {
+	response := models.ProjectResponse{
+		ID:                    project.ID,
+		Name:                  project.Name,
+		ClientName:            project.ClientName,
+		Status:                string(project.Status),
+		ClientID:              project.ClientID,
+		LockedAt:              project.LockedAt,
+		RolledFromID:          project.RolledFromID,
+		AuditType:             (*string)(project.AuditType),
+		PeriodStart:           project.PeriodStart,
+		PeriodEnd:             project.PeriodEnd,
+		PlannedFieldworkStart: project.PlannedFieldworkStart,
+		PlannedFieldworkEnd:   project.PlannedFieldworkEnd,
+		FieldworkStart:        project.FieldworkStart,
+		FieldworkEnd:          project.FieldworkEnd,
+		ReportDueDate:         project.ReportDueDate,
+		TemplateID:            project.TemplateID,
+		CreatedAt:             project.CreatedAt,
+		UpdatedAt:             project.UpdatedAt,
+	}
+
+	if project.Client != nil {
+		client := convertToClientResponse(project.Client)
+		response.Client = &client
+	}
+
+	if project.RolledFrom != nil {
+		rolledFrom := convertToProjectResponse(project.RolledFrom)
+		response.RolledFrom = &rolledFrom
+	}
+
+	if project.Template != nil {
+		template := convertToProjectTemplateResponse(project.Template)
+		response.Template = &template
+	}
+
+	if len(project.Users) > 0 {
+		response.Users = make([]models.UserResponse, len(project.Users))
+		for i, user := range project.Users {
+			response.Users[i] = convertToUserResponse(user)
+		}
+	}
+
+	if len(project.Requirements) > 0 {
+		response.Requirements = make([]models.RequirementResponse, len(project.Requirements))
+		for i, requirement := range project.Requirements {
+			response.Requirements[i] = convertToRequirementResponse(requirement)
+		}
+	}
+
+	return response
+}
+
+// convertToUserResponse converts db.User to models.UserResponse
+func convertToUserResponse(user *db.User) models.UserResponse {
+	response := models.UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      string(user.Role),
+		ClientID:  user.ClientID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+
+	if user.Client != nil {
+		client := convertToClientResponse(user.Client)
+		response.Client = &client
+	}
+
+	if len(user.Projects) > 0 {
+		response.Projects = make([]models.ProjectResponse, len(user.Projects))
+		for i, project := range user.Projects {
+			response.Projects[i] = convertToProjectResponse(project)
+		}
+	}
+
+	return response
+}
+
+// convertToClientResponse converts db.Client to models.ClientResponse
+func convertToClientResponse(client *db.Client) models.ClientResponse {
+	response := models.ClientResponse{
+		ID:           client.ID,
+		Name:         client.Name,
+		Industry:     client.Industry,
+		ContactName:  client.ContactName,
+		ContactEmail: client.ContactEmail,
+		CreatedAt:    client.CreatedAt,
+		UpdatedAt:    client.UpdatedAt,
+	}
+
+	if len(client.Users) > 0 {
+		response.Users = make([]models.UserResponse, len(client.Users))
+		for i, user := range client.Users {
+			response.Users[i] = convertToUserResponse(user)
+		}
+	}
+
+	if len(client.Projects) > 0 {
+		response.Projects = make([]models.ProjectResponse, len(client.Projects))
+		for i, project := range client.Projects {
+			response.Projects[i] = convertToProjectResponse(project)
+		}
+	}
+
+	return response
+}
+
+// convertToRequirementResponse converts db.Requirement to models.RequirementResponse
+func convertToRequirementResponse(requirement *db.Requirement) models.RequirementResponse {
+	response := models.RequirementResponse{
+		ID:                 requirement.ID,
+		ProjectID:          requirement.ProjectID,
+		Text:               requirement.Text,
+		Category:           requirement.Category,
+		Status:             string(requirement.Status),
+		Rationale:          requirement.Rationale,
+		FrameworkControlID: requirement.FrameworkControlID,
+		Ref:                requirement.Ref,
+		ParentID:           requirement.ParentID,
+		Position:           requirement.Position,
+		Section:            requirement.Section,
+		CreatedAt:          requirement.CreatedAt,
+		UpdatedAt:          requirement.UpdatedAt,
+	}
+
+	if requirement.Project != nil {
+		project := convertToProjectResponse(requirement.Project)
+		response.Project = &project
+	}
+
+	if len(requirement.AuditTasks) > 0 {
+		response.AuditTasks = make([]models.AuditTaskResponse, len(requirement.AuditTasks))
+		for i, task := range requirement.AuditTasks {
+			response.AuditTasks[i] = convertToAuditTaskResponse(task)
+		}
+	}
+
+	if len(requirement.Issues) > 0 {
+		response.Issues = make([]models.IssueResponse, len(requirement.Issues))
+		for i, issue := range requirement.Issues {
+			response.Issues[i] = convertToIssueResponse(issue)
+		}
+	}
+
+	if requirement.FrameworkControl != nil {
+		control := convertToFrameworkControlResponse(requirement.FrameworkControl)
+		response.FrameworkControl = &control
+	}
+
+	if requirement.Parent != nil {
+		parent := convertToRequirementResponse(requirement.Parent)
+		response.Parent = &parent
+	}
+
+	if len(requirement.Children) > 0 {
+		response.Children = make([]models.RequirementResponse, len(requirement.Children))
+		for i, child := range requirement.Children {
+			response.Children[i] = convertToRequirementResponse(child)
+		}
+	}
+
+	return response
+}
+
+// convertToAuditTaskResponse converts db.AuditTask to models.AuditTaskResponse
+func convertToAuditTaskResponse(task *db.AuditTask) models.AuditTaskResponse {
+	response := models.AuditTaskResponse{
+		ID:            task.ID,
+		RequirementID: task.RequirementID,
+		Text:          task.Text,
+		Status:        string(task.Status),
+		Notes:         task.Notes,
+		DueDate:       task.DueDate,
+		Overdue:       auditTaskOverdue(task, time.Now()),
+		AssigneeID:    task.AssigneeID,
+		ReviewerID:    task.ReviewerID,
+		PreparedByID:  task.PreparedByID,
+		PreparedAt:    task.PreparedAt,
+		ReviewedByID:  task.ReviewedByID,
+		ReviewedAt:    task.ReviewedAt,
+		ReviewNotes:   task.ReviewNotes,
+		CreatedAt:     task.CreatedAt,
+		UpdatedAt:     task.UpdatedAt,
+	}
+
+	if task.Assignee != nil {
+		assignee := convertToUserResponse(task.Assignee)
+		response.Assignee = &assignee
+	}
+
+	if task.Reviewer != nil {
+		reviewer := convertToUserResponse(task.Reviewer)
+		response.Reviewer = &reviewer
+	}
+
+	if task.PreparedBy != nil {
+		preparedBy := convertToUserResponse(task.PreparedBy)
+		response.PreparedBy = &preparedBy
+	}
+
+	if task.ReviewedBy != nil {
+		reviewedBy := convertToUserResponse(task.ReviewedBy)
+		response.ReviewedBy = &reviewedBy
+	}
+
+	if task.Requirement != nil {
+		requirement := convertToRequirementResponse(task.Requirement)
+		response.Requirement = &requirement
+	}
+
+	if len(task.Issues) > 0 {
+		response.Issues = make([]models.IssueResponse, len(task.Issues))
+		for i, issue := range task.Issues {
+			response.Issues[i] = convertToIssueResponse(issue)
+		}
+	}
+
+	if len(task.Evidence) > 0 {
+		response.Evidence = make([]models.EvidenceResponse, len(task.Evidence))
+		for i, evidence := range task.Evidence {
+			response.Evidence[i] = convertToEvidenceResponse(evidence)
+		}
+	}
+
+	return response
+}
+
+// convertToCustodyEventResponse converts db.CustodyEvent to models.CustodyEventResponse
+func convertToCustodyEventResponse(event *db.CustodyEvent) models.CustodyEventResponse {
+	response := models.CustodyEventResponse{
+		ID:           event.ID,
+		EvidenceID:   event.EvidenceID,
+		Sequence:     event.Sequence,
+		Action:       string(event.Action),
+		UserID:       event.UserID,
+		OccurredAt:   event.OccurredAt,
+		Details:      event.Details,
+		PreviousHash: event.PreviousHash,
+		Hash:         event.Hash,
+	}
+
+	if event.User != nil {
+		user := convertToUserResponse(event.User)
+		response.User = &user
+	}
+
+	return response
+}
+
+// convertToEvidenceResponse converts db.Evidence to models.EvidenceResponse
+func convertToEvidenceResponse(evidence *db.Evidence) models.EvidenceResponse {
+	response := models.EvidenceResponse{
+		ID:                evidence.ID,
+		ClientID:          evidence.ClientID,
+		FileName:          evidence.FileName,
+		ContentType:       evidence.ContentType,
+		Size:              evidence.Size,
+		SHA256:            evidence.SHA256,
+		CollectedByID:     evidence.CollectedByID,
+		CollectedAt:       evidence.CollectedAt,
+		Source:            evidence.Source,
+		ValidFrom:         evidence.ValidFrom,
+		ExpiresOn:         evidence.ExpiresOn,
+		Stale:             evidenceStale(evidence, time.Now()),
+		Version:           evidence.Version,
+		PreviousVersionID: evidence.PreviousVersionID,
+		CreatedAt:         evidence.CreatedAt,
+		UpdatedAt:         evidence.UpdatedAt,
+	}
+
+	if len(evidence.Successors) > 0 {
+		response.SupersededByID = &evidence.Successors[0].ID
+	}
+
+	if evidence.Client != nil {
+		client := convertToClientResponse(evidence.Client)
+		response.Client = &client
+	}
+
+	if len(evidence.AuditTasks) > 0 {
+		response.AuditTasks = make([]models.AuditTaskResponse, len(evidence.AuditTasks))
+		for i, task := range evidence.AuditTasks {
+			response.AuditTasks[i] = convertToAuditTaskResponse(task)
+		}
+	}
+
+	if evidence.CollectedBy != nil {
+		user := convertToUserResponse(evidence.CollectedBy)
+		response.CollectedBy = &user
+	}
+
+	return response
+}
+
+// convertToDocumentRequestResponse converts db.DocumentRequest to models.DocumentRequestResponse
+func convertToDocumentRequestResponse(request *db.DocumentRequest) models.DocumentRequestResponse {
+	response := models.DocumentRequestResponse{
+		ID:             request.ID,
+		ProjectID:      request.ProjectID,
+		Description:    request.Description,
+		RequirementIDs: make([]uint, len(request.Requirements)),
+		AssigneeID:     request.AssigneeID,
+		RequestedByID:  request.RequestedByID,
+		DueDate:        request.DueDate,
+		Status:         string(request.Status),
+		Overdue:        documentRequestOverdue(request, time.Now()),
+		SubmittedAt:    request.SubmittedAt,
+		ReviewNote:     request.ReviewNote,
+		CreatedAt:      request.CreatedAt,
+		UpdatedAt:      request.UpdatedAt,
+	}
+
+	for i, requirement := range request.Requirements {
+		response.RequirementIDs[i] = requirement.ID
+	}
+
+	if request.Project != nil {
+		project := convertToProjectResponse(request.Project)
+		response.Project = &project
+	}
+
+	if request.Assignee != nil {
+		assignee := convertToUserResponse(request.Assignee)
+		response.Assignee = &assignee
+	}
+
+	if request.RequestedBy != nil {
+		requestedBy := convertToUserResponse(request.RequestedBy)
+		response.RequestedBy = &requestedBy
+	}
+
+	if len(request.Evidence) > 0 {
+		response.Evidence = make([]models.EvidenceResponse, len(request.Evidence))
+		for i, evidence := range request.Evidence {
+			response.Evidence[i] = convertToEvidenceResponse(evidence)
+		}
+	}
+
+	return response
+}
+
+// convertToIssueResponse converts db.Issue to models.IssueResponse
+func convertToIssueResponse(issue *db.Issue) models.IssueResponse {
+	response := models.IssueResponse{
+		ID:                   issue.ID,
+		AuditTaskID:          issue.AuditTaskID,
+		RequirementIDs:       make([]uint, len(issue.Requirements)),
+		Title:                issue.Title,
+		Description:          issue.Description,
+		Priority:             (*string)(issue.Priority),
+		Phase:                (*string)(issue.Phase),
+		EstimateHrs:          issue.EstimateHrs,
+		Status:               string(issue.Status),
+		Type:                 string(issue.Type),
+		ResolutionNote:       issue.ResolutionNote,
+		DueDate:              issue.DueDate,
+		DueDateFromSLA:       issue.DueDateFromSLA,
+		Overdue:              issueOverdue(issue, time.Now()),
+		AssigneeID:           issue.AssigneeID,
+		ReviewerID:           issue.ReviewerID,
+		CarriedFromID:        issue.CarriedFromID,
+		CreatedAt:            issue.CreatedAt,
+		UpdatedAt:            issue.UpdatedAt,
+		CompensatingControls: issue.CompensatingControls,
+	}
+
+	if issue.AuditTask != nil {
+		task := convertToAuditTaskResponse(issue.AuditTask)
+		response.AuditTask = &task
+	}
+
+	if issue.Assignee != nil {
+		assignee := convertToUserResponse(issue.Assignee)
+		response.Assignee = &assignee
+	}
+
+	if issue.CarriedFrom != nil {
+		carriedFrom := convertToIssueResponse(issue.CarriedFrom)
+		response.CarriedFrom = &carriedFrom
+	}
+
+	if issue.Reviewer != nil {
+		reviewer := convertToUserResponse(issue.Reviewer)
+		response.Reviewer = &reviewer
+	}
+
+	for i, requirement := range issue.Requirements {
+		response.RequirementIDs[i] = requirement.ID
+	}
+
+	response.InherentRisk = convertToRiskScoreResponse(issue.InherentLikelihood, issue.InherentImpact, issue.InherentRating)
+	response.ResidualRisk = convertToRiskScoreResponse(issue.ResidualLikelihood, issue.ResidualImpact, issue.ResidualRating)
+
+	if issue.Remediation != nil {
+		remediation := convertToRemediationResponse(issue.Remediation)
+		response.Remediation = &remediation
+	}
+
+	if len(issue.Retests) > 0 {
+		response.Retests = make([]models.RetestResponse, len(issue.Retests))
+		for i, retest := range issue.Retests {
+			response.Retests[i] = convertToRetestResponse(retest)
+		}
+	}
+
+	return response
+}
+
+// convertToRemediationResponse converts db.Remediation to models.RemediationResponse
+func convertToRemediationResponse(remediation *db.Remediation) models.RemediationResponse {
+	response := models.RemediationResponse{
+		ID:                 remediation.ID,
+		IssueID:            remediation.IssueID,
+		Agreement:          string(remediation.Agreement),
+		ManagementResponse: remediation.ManagementResponse,
+		ActionPlan:         remediation.ActionPlan,
+		OwnerID:            remediation.OwnerID,
+		TargetDate:         remediation.TargetDate,
+		RespondedByID:      remediation.RespondedByID,
+		Milestones:         make([]models.RemediationMilestoneResponse, len(remediation.Milestones)),
+		CreatedAt:          remediation.CreatedAt,
+		UpdatedAt:          remediation.UpdatedAt,
+	}
+
+	if remediation.Owner != nil {
+		owner := convertToUserResponse(remediation.Owner)
+		response.Owner = &owner
+	}
+
+	if remediation.RespondedBy != nil {
+		respondedBy := convertToUserResponse(remediation.RespondedBy)
+		response.RespondedBy = &respondedBy
+	}
+
+	now := time.Now()
+	for i, milestone := range remediation.Milestones {
+		response.Milestones[i] = convertToRemediationMilestoneResponse(milestone, now)
+	}
+
+	if len(remediation.Updates) > 0 {
+		response.Updates = make([]models.RemediationUpdateResponse, len(remediation.Updates))
+		for i, update := range remediation.Updates {
+			response.Updates[i] = convertToRemediationUpdateResponse(update)
+		}
+	}
+
+	return response
+}
+
+func convertToRemediationMilestoneResponse(milestone *db.RemediationMilestone, now time.Time) models.RemediationMilestoneResponse {
+	return models.RemediationMilestoneResponse{
+		ID:          milestone.ID,
+		Title:       milestone.Title,
+		DueDate:     milestone.DueDate,
+		Completed:   milestone.CompletedAt != nil,
+		CompletedAt: milestone.CompletedAt,
+		Overdue:     milestone.CompletedAt == nil && milestone.DueDate != nil && now.After(*milestone.DueDate),
+	}
+}
+
+func convertToRemediationUpdateResponse(update *db.RemediationUpdate) models.RemediationUpdateResponse {
+	response := models.RemediationUpdateResponse{
+		ID:              update.ID,
+		AuthorID:        update.AuthorID,
+		Note:            update.Note,
+		PercentComplete: update.PercentComplete,
+		CreatedAt:       update.CreatedAt,
+	}
+
+	if update.Author != nil {
+		author := convertToUserResponse(update.Author)
+		response.Author = &author
+	}
+
+	return response
+}
+
+// convertToRetestResponse converts db.Retest to models.RetestResponse
+func convertToRetestResponse(retest *db.Retest) models.RetestResponse {
+	response := models.RetestResponse{
+		ID:            retest.ID,
+		IssueID:       retest.IssueID,
+		Result:        string(retest.Result),
+		Notes:         retest.Notes,
+		EvidenceID:    retest.EvidenceID,
+		PerformedByID: retest.PerformedByID,
+		CreatedAt:     retest.CreatedAt,
+	}
+
+	if retest.Evidence != nil {
+		evidence := convertToEvidenceResponse(retest.Evidence)
+		response.Evidence = &evidence
+	}
+
+	if retest.PerformedBy != nil {
+		performedBy := convertToUserResponse(retest.PerformedBy)
+		response.PerformedBy = &performedBy
+	}
+
+	return response
+}
+
+// convertToRiskScoreResponse converts a scored position on the risk matrix,
+// or returns nil when the issue has not been scored.
+func convertToRiskScoreResponse(likelihood, impact *int, rating *db.IssuePriority) *models.RiskScoreResponse {
+	if likelihood == nil || impact == nil || rating == nil {
+		return nil
+	}
+	return &models.RiskScoreResponse{
+		Likelihood: *likelihood,
+		Impact:     *impact,
+		Score:      risk.Score(*likelihood, *impact),
+		Rating:     string(*rating),
+	}
+}
+
+// convertToFrameworkResponse converts db.Framework to models.FrameworkResponse
+func convertToFrameworkResponse(framework *db.Framework) models.FrameworkResponse {
+	response := models.FrameworkResponse{
+		ID:          framework.ID,
+		Name:        framework.Name,
+		Version:     framework.Version,
+		Description: framework.Description,
+		CreatedAt:   framework.CreatedAt,
+		UpdatedAt:   framework.UpdatedAt,
+	}
+
+	if len(framework.Controls) > 0 {
+		response.Controls = make([]models.FrameworkControlResponse, len(framework.Controls))
+		for i, control := range framework.Controls {
+			response.Controls[i] = convertToFrameworkControlResponse(control)
+		}
+	}
+
+	return response
+}
+
+// convertToFrameworkControlResponse converts db.FrameworkControl to models.FrameworkControlResponse
+func convertToFrameworkControlResponse(control *db.FrameworkControl) models.FrameworkControlResponse {
+	response := models.FrameworkControlResponse{
+		ID:          control.ID,
+		FrameworkID: control.FrameworkID,
+		ControlID:   control.ControlID,
+		Title:       control.Title,
+		Description: control.Description,
+		Category:    control.Category,
+		CreatedAt:   control.CreatedAt,
+		UpdatedAt:   control.UpdatedAt,
+	}
+
+	if control.Framework != nil {
+		framework := convertToFrameworkResponse(control.Framework)
+		response.Framework = &framework
+	}
+
+	return response
+}
+
+// convertToControlMappingResponse converts db.ControlMapping to models.ControlMappingResponse
+func convertToControlMappingResponse(mapping *db.ControlMapping) models.ControlMappingResponse {
+	response := models.ControlMappingResponse{
+		ID:              mapping.ID,
+		SourceControlID: mapping.SourceControlID,
+		TargetControlID: mapping.TargetControlID,
+		Strength:        string(mapping.Strength),
+		CreatedAt:       mapping.CreatedAt,
+		UpdatedAt:       mapping.UpdatedAt,
+	}
+
+	if mapping.SourceControl != nil {
+		control := convertToFrameworkControlResponse(mapping.SourceControl)
+		response.SourceControl = &control
+	}
+
+	if mapping.TargetControl != nil {
+		control := convertToFrameworkControlResponse(mapping.TargetControl)
+		response.TargetControl = &control
+	}
+
+	return response
+}
+
+// The toUpdate*Request builders produce the replacement document for a stored
+// entity, which PATCH requests are applied to.
+
+func toUpdateProjectRequest(project *db.Project) models.UpdateProjectRequest {
+	return models.UpdateProjectRequest{
+		Name:                  project.Name,
+		ClientName:            project.ClientName,
+		Status:                string(project.Status),
+		ClientID:              project.ClientID,
+		AuditType:             (*string)(project.AuditType),
+		PeriodStart:           project.PeriodStart,
+		PeriodEnd:             project.PeriodEnd,
+		PlannedFieldworkStart: project.PlannedFieldworkStart,
+		PlannedFieldworkEnd:   project.PlannedFieldworkEnd,
+		FieldworkStart:        project.FieldworkStart,
+		FieldworkEnd:          project.FieldworkEnd,
+		ReportDueDate:         project.ReportDueDate,
+	}
+}
+
+func toUpdateUserRequest(user *db.User) models.UpdateUserRequest {
+	return models.UpdateUserRequest{
+		Name:     user.Name,
+		Email:    user.Email,
+		Role:     string(user.Role),
+		ClientID: user.ClientID,
+	}
+}
+
+func toUpdateClientRequest(client *db.Client) models.UpdateClientRequest {
+	return models.UpdateClientRequest{
+		Name:         client.Name,
+		Industry:     client.Industry,
+		ContactName:  client.ContactName,
+		ContactEmail: client.ContactEmail,
+	}
+}
+
+func toUpdateRequirementRequest(requirement *db.Requirement) models.UpdateRequirementRequest {
+	return models.UpdateRequirementRequest{
+		Text:      requirement.Text,
+		Category:  requirement.Category,
+		Status:    string(requirement.Status),
+		Rationale: requirement.Rationale,
+		Ref:       requirement.Ref,
+		ParentID:  requirement.ParentID,
+		Position:  requirement.Position,
+		Section:   requirement.Section,
+	}
+}
+
+func toUpdateAuditTaskRequest(task *db.AuditTask) models.UpdateAuditTaskRequest {
+	return models.UpdateAuditTaskRequest{
+		Text:        task.Text,
+		Status:      string(task.Status),
+		Notes:       task.Notes,
+		DueDate:     task.DueDate,
+		AssigneeID:  task.AssigneeID,
+		ReviewerID:  task.ReviewerID,
+		ReviewNotes: task.ReviewNotes,
+	}
+}
+
+func toUpdateIssueRequest(issue *db.Issue) models.UpdateIssueRequest {
+	ids := make([]uint, len(issue.Requirements))
+	for i, requirement := range issue.Requirements {
+		ids[i] = requirement.ID
+	}
+	return models.UpdateIssueRequest{
+		Title:                issue.Title,
+		Description:          issue.Description,
+		Priority:             (*string)(issue.Priority),
+		Phase:                (*string)(issue.Phase),
+		EstimateHrs:          issue.EstimateHrs,
+		Status:               string(issue.Status),
+		Type:                 string(issue.Type),
+		ResolutionNote:       issue.ResolutionNote,
+		RequirementIDs:       ids,
+		InherentLikelihood:   issue.InherentLikelihood,
+		InherentImpact:       issue.InherentImpact,
+		ResidualLikelihood:   issue.ResidualLikelihood,
+		ResidualImpact:       issue.ResidualImpact,
+		CompensatingControls: issue.CompensatingControls,
+		DueDate:              issue.DueDate,
+		AssigneeID:           issue.AssigneeID,
+		ReviewerID:           issue.ReviewerID,
+	}
+}
+
+func toUpdateFrameworkRequest(framework *db.Framework) models.UpdateFrameworkRequest {
+	return models.UpdateFrameworkRequest{
+		Name:        framework.Name,
+		Version:     framework.Version,
+		Description: framework.Description,
+	}
+}
+
+func toUpdateFrameworkControlRequest(control *db.FrameworkControl) models.UpdateFrameworkControlRequest {
+	return models.UpdateFrameworkControlRequest{
+		ControlID:   control.ControlID,
+		Title:       control.Title,
+		Description: control.Description,
+		Category:    control.Category,
+	}
+}
+
+func toUpdateEvidenceRequest(evidence *db.Evidence) models.UpdateEvidenceRequest {
+	return models.UpdateEvidenceRequest{
+		Source:    evidence.Source,
+		ValidFrom: evidence.ValidFrom,
+		ExpiresOn: evidence.ExpiresOn,
+	}
+}
+
+func toUpdateDocumentRequestRequest(request *db.DocumentRequest) models.UpdateDocumentRequestRequest {
+	ids := make([]uint, len(request.Requirements))
+	for i, requirement := range request.Requirements {
+		ids[i] = requirement.ID
+	}
+	return models.UpdateDocumentRequestRequest{
+		Description:    request.Description,
+		RequirementIDs: ids,
+		AssigneeID:     request.AssigneeID,
+		DueDate:        request.DueDate,
+	}
+}
+
+func toRemediationRequest(remediation *db.Remediation) models.RemediationRequest {
+	return models.RemediationRequest{
+		Agreement:          string(remediation.Agreement),
+		ManagementResponse: remediation.ManagementResponse,
+		ActionPlan:         remediation.ActionPlan,
+		OwnerID:            remediation.OwnerID,
+		TargetDate:         remediation.TargetDate,
+	}
+}
+
+func toUpdateRemediationMilestoneRequest(milestone *db.RemediationMilestone) models.UpdateRemediationMilestoneRequest {
+	return models.UpdateRemediationMilestoneRequest{
+		Title:     milestone.Title,
+		DueDate:   milestone.DueDate,
+		Completed: milestone.CompletedAt != nil,
+	}
+}
+
+func toUpdateControlMappingRequest(mapping *db.ControlMapping) models.UpdateControlMappingRequest {
+	return models.UpdateControlMappingRequest{
+		Strength: string(mapping.Strength),
+	}
+}
+
+func convertToSignOffResponse(signOff *db.SignOff) models.SignOffResponse {
+	response := models.SignOffResponse{
+		ID:             signOff.ID,
+		ProjectID:      signOff.ProjectID,
+		SignerID:       signOff.SignerID,
+		Role:           string(signOff.Role),
+		Statement:      signOff.Statement,
+		SignedAt:       signOff.SignedAt,
+		SnapshotDigest: signOff.SnapshotDigest,
+		KeyID:          signOff.KeyID,
+		PublicKey:      signOff.PublicKey,
+		Signature:      signOff.Signature,
+	}
+
+	if signOff.Signer != nil {
+		signer := convertToUserResponse(signOff.Signer)
+		response.Signer = &signer
+	}
+
+	return response
+}
+
+// convertToProjectTemplateResponse converts db.ProjectTemplate to models.ProjectTemplateResponse
+func convertToProjectTemplateResponse(template *db.ProjectTemplate) models.ProjectTemplateResponse {
+	var content models.ProjectTemplateContent
+	json.Unmarshal([]byte(template.Content), &content)
+	if content.Requirements == nil {
+		content.Requirements = []models.TemplateRequirement{}
+	}
+	if content.Team == nil {
+		content.Team = []models.TemplateMember{}
+	}
+	if content.DocumentRequests == nil {
+		content.DocumentRequests = []models.TemplateDocumentRequest{}
+	}
+
+	response := models.ProjectTemplateResponse{
+		ID:               template.ID,
+		Name:             template.Name,
+		Version:          template.Version,
+		Description:      template.Description,
+		AuditType:        (*string)(template.AuditType),
+		ProjectName:      template.ProjectName,
+		SourceProjectID:  template.SourceProjectID,
+		CreatedByID:      template.CreatedByID,
+		Variables:        templateVariables(template.ProjectName, &content),
+		Requirements:     content.Requirements,
+		Team:             content.Team,
+		DocumentRequests: content.DocumentRequests,
+		CreatedAt:        template.CreatedAt,
+	}
+
+	if template.SourceProject != nil {
+		sourceProject := convertToProjectResponse(template.SourceProject)
+		response.SourceProject = &sourceProject
+	}
+
+	if template.CreatedBy != nil {
+		createdBy := convertToUserResponse(template.CreatedBy)
+		response.CreatedBy = &createdBy
+	}
+
+	return response
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const tokenPrefix = "mock-token-"
+
+// currentUser resolves the caller from the bearer token issued by Login. It
+// returns nil when no valid token was sent.
+func currentUser(c *gin.Context, database *db.Database) *db.User {
+	header := c.GetHeader("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || !strings.HasPrefix(token, tokenPrefix) {
+		return nil
+	}
+
+	userID, err := strconv.ParseUint(strings.TrimPrefix(token, tokenPrefix), 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	var user db.User
+	if err := database.First(&user, userID).Error; err != nil {
+		return nil
+	}
+	return &user
+}
+
+// requireUser returns the authenticated caller, writing a 401 response when
+// there is none.
+func requireUser(c *gin.Context, database *db.Database) (*db.User, bool) {
+	user := currentUser(c, database)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error: "Authentication required",
+			Code:  http.StatusUnauthorized,
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// requireRole returns the authenticated caller if they hold one of the given
+// roles, writing a 401 or 403 response otherwise.
+func requireRole(c *gin.Context, database *db.Database, roles ...db.Role) (*db.User, bool) {
+	user, ok := requireUser(c, database)
+	if !ok {
+		return nil, false
+	}
+	for _, role := range roles {
+		if user.Role == role {
+			return user, true
+		}
+	}
+	c.JSON(http.StatusForbidden, models.ErrorResponse{
+		Error: "Insufficient permissions",
+		Code:  http.StatusForbidden,
+	})
+	return nil, false
+}
+
+// canAccessProject reports whether the user may see a project's data. Admins
+// see every project, consultants the projects they are assigned to and
+// client users the projects of their own client.
+func canAccessProject(database *db.Database, user *db.User, projectID uint) (bool, error) {
+	switch user.Role {
+	case db.RoleAdmin:
+		return true, nil
+	case db.RoleConsultant:
+		var count int64
+		err := database.Table("project_users").
+			Where("project_id = ? AND user_id = ?", projectID, user.ID).
+			Count(&count).Error
+		return count > 0, err
+	case db.RoleClient:
+		if user.ClientID == nil {
+			return false, nil
+		}
+		var count int64
+		err := database.Model(&db.Project{}).
+			Where("id = ? AND client_id = ?", projectID, *user.ClientID).
+			Count(&count).Error
+		return count > 0, err
+	}
+	return false, nil
+}
+
+// accessibleProjects narrows a projects query to the ones the user may see,
+// as canAccessProject decides for a single project.
+func accessibleProjects(query *gorm.DB, user *db.User) *gorm.DB {
+	switch user.Role {
+	case db.RoleAdmin:
+		return query
+	case db.RoleConsultant:
+		return query.Where("projects.id IN (SELECT project_id FROM project_users WHERE user_id = ?)", user.ID)
+	case db.RoleClient:
+		if user.ClientID != nil {
+			return query.Where("projects.client_id = ?", *user.ClientID)
+		}
+	}
+	return query.Where("1 = 0")
+}
+
+// requireProjectAccess writes a 403 response unless the user may see the
+// project's data.
+func requireProjectAccess(c *gin.Context, database *db.Database, user *db.User, projectID uint) bool {
+	allowed, err := canAccessProject(database, user, projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check project access",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "No access to this project",
+			Code:  http.StatusForbidden,
+		})
+		return false
+	}
+	return true
+}
+
+// canAccessClient reports whether the user may see data shared across a
+// client's projects, such as its evidence library. Admins see every client,
+// consultants the clients of projects they are assigned to and client users
+// their own client.
+func canAccessClient(database *db.Database, user *db.User, clientID uint) (bool, error) {
+	switch user.Role {
+	case db.RoleAdmin:
+		return true, nil
+	case db.RoleConsultant:
+		var count int64
+		err := database.Table("project_users").
+			Joins("JOIN projects ON projects.id = project_users.project_id").
+			Where("projects.client_id = ? AND projects.deleted_at IS NULL AND project_users.user_id = ?", clientID, user.ID).
+			Count(&count).Error
+		return count > 0, err
+	case db.RoleClient:
+		return user.ClientID != nil && *user.ClientID == clientID, nil
+	}
+	return false, nil
+}
+
+// requireClientAccess writes a 403 response unless the user may see the
+// client's data.
+func requireClientAccess(c *gin.Context, database *db.Database, user *db.User, clientID uint) bool {
+	allowed, err := canAccessClient(database, user, clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check client access",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "No access to this client",
+			Code:  http.StatusForbidden,
+		})
+		return false
+	}
+	return true
+}
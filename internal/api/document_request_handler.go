@@ -0,0 +1,617 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// outstandingDocumentRequests are the statuses in which a request waits on
+// the client.
+var outstandingDocumentRequests = []db.DocumentRequestStatus{
+	db.DocumentRequestStatusRequested,
+	db.DocumentRequestStatusRejected,
+}
+
+// errNotClientUser is returned when a user who acts for the client, such as
+// the assignee of a document request, is not a CLIENT user of the project's
+// client.
+var errNotClientUser = errors.New("invalid client user")
+
+// DocumentRequestHandler manages prepared-by-client (PBC) request lists.
+// Responses are uploaded through the evidence handler, so they land in the
+// client's evidence library.
+type DocumentRequestHandler struct {
+	store
+	evidence *EvidenceHandler
+}
+
+func NewDocumentRequestHandler(database *db.Database, evidence *EvidenceHandler) *DocumentRequestHandler {
+	return &DocumentRequestHandler{store: store{db: database}, evidence: evidence}
+}
+
+// GetProjectDocumentRequests handles GET /api/v1/projects/:id/document-requests
+//
+// Optional filters: status, assigneeId and overdue=true.
+func (h *DocumentRequestHandler) GetProjectDocumentRequests(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "documentRequest")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Requirements").
+		Where("project_id = ?", project.ID)
+	if status := c.Query("status"); status != "" {
+		if !db.IsEnumValue("documentRequestStatus", status) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid status",
+				Message: fmt.Sprintf("status must be one of %v", db.Enums()["documentRequestStatus"]),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+	if assigneeID := c.Query("assigneeId"); assigneeID != "" {
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where("due_date < ? AND status IN ?", time.Now().UTC(), outstandingDocumentRequests)
+	}
+
+	var requests []db.DocumentRequest
+	if err := query.Order("due_date IS NULL, due_date").Order("id").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch document requests",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.DocumentRequestResponse, len(requests))
+	for i, request := range requests {
+		response[i] = convertToDocumentRequestResponse(&request)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateDocumentRequest handles POST /api/v1/projects/:id/document-requests
+func (h *DocumentRequestHandler) CreateDocumentRequest(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+	if !requireProjectClient(c, project) {
+		return
+	}
+
+	var req models.CreateDocumentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := checkInitialStatus(h.conn(c), workflow.EntityDocumentRequest, project.ID, string(db.DocumentRequestStatusRequested)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+
+	requirements, ok := h.checkLinks(c, project, req.RequirementIDs, req.AssigneeID)
+	if !ok {
+		return
+	}
+
+	request := db.DocumentRequest{
+		ProjectID:     project.ID,
+		Description:   req.Description,
+		Requirements:  requirements,
+		AssigneeID:    req.AssigneeID,
+		RequestedByID: user.ID,
+		DueDate:       utcTime(req.DueDate),
+		Status:        db.DocumentRequestStatusRequested,
+	}
+
+	if err := h.conn(c).Omit("Requirements.*").Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create document request",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToDocumentRequestResponse(&request)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetDocumentRequestTracker handles GET /api/v1/projects/:id/document-requests/tracker
+//
+// Shows what the client still owes: counts by status, overdue requests,
+// the outstanding load per assignee and the outstanding items, soonest due
+// first.
+func (h *DocumentRequestHandler) GetDocumentRequestTracker(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+
+	var requests []db.DocumentRequest
+	if err := h.conn(c).Preload("Requirements").Preload("Assignee").
+		Where("project_id = ?", project.ID).
+		Order("due_date IS NULL, due_date").Order("id").
+		Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch document requests",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	now := time.Now()
+	tracker := models.DocumentRequestTrackerResponse{
+		ProjectID:        project.ID,
+		Total:            len(requests),
+		ByStatus:         map[string]int{},
+		Assignees:        []models.DocumentRequestAssignee{},
+		OutstandingItems: []models.DocumentRequestResponse{},
+	}
+	for _, status := range db.DocumentRequestStatuses {
+		tracker.ByStatus[string(status)] = 0
+	}
+
+	assignees := map[uint]*models.DocumentRequestAssignee{}
+	var order []uint
+	for i := range requests {
+		request := &requests[i]
+		tracker.ByStatus[string(request.Status)]++
+		if request.Status == db.DocumentRequestStatusSubmitted {
+			tracker.AwaitingReview++
+		}
+		if !documentRequestOutstanding(request) {
+			continue
+		}
+
+		overdue := documentRequestOverdue(request, now)
+		tracker.Outstanding++
+		if overdue {
+			tracker.Overdue++
+		}
+
+		// Unassigned requests are grouped under assignee 0
+		var key uint
+		if request.AssigneeID != nil {
+			key = *request.AssigneeID
+		}
+		summary, seen := assignees[key]
+		if !seen {
+			summary = &models.DocumentRequestAssignee{AssigneeID: request.AssigneeID}
+			if request.Assignee != nil {
+				assignee := convertToUserResponse(request.Assignee)
+				summary.Assignee = &assignee
+			}
+			assignees[key] = summary
+			order = append(order, key)
+		}
+		summary.Outstanding++
+		if overdue {
+			summary.Overdue++
+		}
+		// Requests are sorted by due date, so the first one seen is the next due
+		if summary.NextDueDate == nil && request.DueDate != nil {
+			summary.NextDueDate = request.DueDate
+		}
+
+		request.Assignee = nil
+		tracker.OutstandingItems = append(tracker.OutstandingItems, convertToDocumentRequestResponse(request))
+	}
+
+	for _, key := range order {
+		tracker.Assignees = append(tracker.Assignees, *assignees[key])
+	}
+	sort.SliceStable(tracker.Assignees, func(i, j int) bool {
+		return tracker.Assignees[i].Outstanding > tracker.Assignees[j].Outstanding
+	})
+
+	c.JSON(http.StatusOK, tracker)
+}
+
+// GetDocumentRequest handles GET /api/v1/document-requests/:id
+func (h *DocumentRequestHandler) GetDocumentRequest(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "documentRequest")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	request, ok := h.loadDocumentRequest(c, user, preloads)
+	if !ok {
+		return
+	}
+
+	response := convertToDocumentRequestResponse(request)
+	renderResource(c, http.StatusOK, response)
+}
+
+// UpdateDocumentRequest handles PUT and PATCH /api/v1/document-requests/:id
+func (h *DocumentRequestHandler) UpdateDocumentRequest(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	request, ok := h.loadDocumentRequest(c, user, nil)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateDocumentRequestRequest
+	if !bindUpdate(c, toUpdateDocumentRequestRequest(request), &req) {
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, request.ProjectID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update document request",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	requirements, ok := h.checkLinks(c, &project, req.RequirementIDs, req.AssigneeID)
+	if !ok {
+		return
+	}
+
+	request.Description = req.Description
+	request.AssigneeID = req.AssigneeID
+	request.DueDate = utcTime(req.DueDate)
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Requirements").Save(request).Error; err != nil {
+			return err
+		}
+		return tx.Model(request).Omit("Requirements.*").Association("Requirements").Replace(requirements)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update document request",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	request.Requirements = requirements
+
+	response := convertToDocumentRequestResponse(request)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteDocumentRequest handles DELETE /api/v1/document-requests/:id
+//
+// Evidence uploaded in response stays in the client's library.
+func (h *DocumentRequestHandler) DeleteDocumentRequest(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	request, ok := h.loadDocumentRequest(c, user, nil)
+	if !ok {
+		return
+	}
+
+	if err := h.conn(c).Delete(request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete document request",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document request deleted successfully"})
+}
+
+// UploadDocumentResponse handles POST /api/v1/document-requests/:id/responses
+//
+// Client users answer a request by uploading a file, with the form fields of
+// an evidence upload. The file is filed as evidence in the client's library
+// and the request moves to SUBMITTED through its workflow.
+func (h *DocumentRequestHandler) UploadDocumentResponse(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	request, ok := h.loadDocumentRequest(c, user, nil)
+	if !ok {
+		return
+	}
+	if request.Status == db.DocumentRequestStatusAccepted {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Document request already accepted",
+			Message: "reopen the request before uploading further documents",
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, request.ProjectID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to upload document",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if !requireProjectClient(c, &project) {
+		return
+	}
+
+	evidence, ok := h.evidence.receiveEvidence(c, user, *project.ClientID)
+	if !ok {
+		return
+	}
+	if evidence.Source == nil {
+		evidence.Source = ptr(fmt.Sprintf("Document request %d", request.ID))
+	}
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(evidence).Error; err != nil {
+			return err
+		}
+		if _, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionUploaded, &user.ID, nil); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO document_request_evidence (document_request_id, evidence_id) VALUES (?, ?)", request.ID, evidence.ID).Error; err != nil {
+			return err
+		}
+
+		if request.Status == db.DocumentRequestStatusSubmitted {
+			return nil
+		}
+		database := &db.Database{DB: tx}
+		to := string(db.DocumentRequestStatusSubmitted)
+		if _, err := changeStatus(database, user, workflow.EntityDocumentRequest, request.ID, string(request.Status), to, nil); err != nil {
+			return err
+		}
+		return saveStatus(database, user, workflow.EntityDocumentRequest, request.ID, to, nil)
+	})
+	if err != nil {
+		h.evidence.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		if errors.Is(err, workflow.ErrIllegalTransition) || errors.Is(err, workflow.ErrRoleNotAllowed) {
+			writeTransitionError(c, err)
+			return
+		}
+		log.Printf("Failed to save response to document request %d: %v", request.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to upload document",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetDocumentResponses handles GET /api/v1/document-requests/:id/responses
+func (h *DocumentRequestHandler) GetDocumentResponses(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "evidence")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	request, ok := h.loadDocumentRequest(c, user, nil)
+	if !ok {
+		return
+	}
+
+	var evidence []db.Evidence
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Successors").
+		Joins("JOIN document_request_evidence ON document_request_evidence.evidence_id = evidences.id").
+		Where("document_request_evidence.document_request_id = ?", request.ID).
+		Order("evidences.created_at").
+		Find(&evidence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch document request responses",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.EvidenceResponse, len(evidence))
+	for i, item := range evidence {
+		response[i] = convertToEvidenceResponse(&item)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// loadProject fetches the project named by the :id parameter and checks that
+// the user may access it.
+func (h *DocumentRequestHandler) loadProject(c *gin.Context, user *db.User) (*db.Project, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return nil, false
+	}
+	return &project, true
+}
+
+// loadDocumentRequest fetches the document request named by the :id
+// parameter, with its requirements, and checks that the user may access its
+// project.
+func (h *DocumentRequestHandler) loadDocumentRequest(c *gin.Context, user *db.User, preloads []string) (*db.DocumentRequest, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid document request ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var request db.DocumentRequest
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Requirements").
+		First(&request, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Document request not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireProjectAccess(c, h.conn(c), user, request.ProjectID) {
+		return nil, false
+	}
+	return &request, true
+}
+
+// checkLinks loads the requirements a request covers and checks the
+// assignee. Requirements must belong to the project and the assignee must be
+// a CLIENT user of the project's client. It writes a 422 response otherwise.
+func (h *DocumentRequestHandler) checkLinks(c *gin.Context, project *db.Project, requirementIDs []uint, assigneeID *uint) ([]*db.Requirement, bool) {
+	requirements, err := projectRequirements(h.conn(c), project.ID, requirementIDs)
+	if err == nil && assigneeID != nil {
+		err = checkClientUser(h.conn(c), project, *assigneeID)
+	}
+
+	if errors.Is(err, errRequirementNotInProject) || errors.Is(err, errNotClientUser) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid document request",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check document request",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+	return requirements, true
+}
+
+// checkClientUser checks that the user exists and is a CLIENT user of the
+// project's client.
+func checkClientUser(database *db.Database, project *db.Project, userID uint) error {
+	var user db.User
+	err := database.First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: user %d not found", errNotClientUser, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role != db.RoleClient || user.ClientID == nil || project.ClientID == nil || *user.ClientID != *project.ClientID {
+		return fmt.Errorf("%w: user %d is not a CLIENT user of the project's client", errNotClientUser, userID)
+	}
+	return nil
+}
+
+// requireProjectClient writes a 422 response when the project has no
+// client to request documents from.
+func requireProjectClient(c *gin.Context, project *db.Project) bool {
+	if project.ClientID == nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Project has no client",
+			Message: "assign the project to a client before requesting documents",
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return false
+	}
+	return true
+}
+
+// documentRequestOutstanding reports whether the request waits on the client.
+func documentRequestOutstanding(request *db.DocumentRequest) bool {
+	for _, status := range outstandingDocumentRequests {
+		if request.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// documentRequestOverdue reports whether an outstanding request is past its
+// due date.
+func documentRequestOverdue(request *db.DocumentRequest, now time.Time) bool {
+	return request.DueDate != nil && now.After(*request.DueDate) && documentRequestOutstanding(request)
+}
@@ -0,0 +1,1191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/storage"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultEvidenceMaxBytes caps evidence uploads when EVIDENCE_MAX_BYTES is
+// not set.
+const defaultEvidenceMaxBytes = 25 << 20
+
+// evidenceMaxBytes reads the upload size limit from EVIDENCE_MAX_BYTES.
+func evidenceMaxBytes() int64 {
+	raw := os.Getenv("EVIDENCE_MAX_BYTES")
+	if raw == "" {
+		return defaultEvidenceMaxBytes
+	}
+	limit, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || limit <= 0 {
+		log.Printf("Ignoring invalid EVIDENCE_MAX_BYTES %q", raw)
+		return defaultEvidenceMaxBytes
+	}
+	return limit
+}
+
+// EvidenceHandler stores files collected for audit tasks. Metadata is kept
+// in the database, the content in the configured object storage.
+type EvidenceHandler struct {
+	store
+	objects  storage.Storage
+	maxBytes int64
+}
+
+func NewEvidenceHandler(database *db.Database, objects storage.Storage) *EvidenceHandler {
+	return &EvidenceHandler{store: store{db: database}, objects: objects, maxBytes: evidenceMaxBytes()}
+}
+
+// errEvidenceSuperseded aborts a new version of evidence that already has one.
+var errEvidenceSuperseded = errors.New("evidence already superseded")
+
+// currentEvidence restricts a query on evidences to versions that have not
+// been superseded.
+const currentEvidence = "NOT EXISTS (SELECT 1 FROM evidences newer WHERE newer.previous_version_id = evidences.id AND newer.deleted_at IS NULL)"
+
+// staleEvidenceExists matches audit tasks linked to a current evidence item
+// that has expired at the bound time.
+const staleEvidenceExists = "EXISTS (SELECT 1 FROM audit_task_evidence JOIN evidences ON evidences.id = audit_task_evidence.evidence_id" +
+	" WHERE audit_task_evidence.audit_task_id = audit_tasks.id AND evidences.deleted_at IS NULL" +
+	" AND evidences.expires_on IS NOT NULL AND evidences.expires_on <= ? AND " + currentEvidence + ")"
+
+// UploadEvidence handles POST /api/v1/audit-tasks/:id/evidence
+//
+// The file is sent in the "file" form field. Optional form fields are
+// collectedAt (RFC 3339, defaults to now), collectedById (defaults to the
+// uploader), source, validFrom and expiresOn (dates or RFC 3339). The
+// content type is sniffed from the content rather than taken from the
+// client. The evidence is filed in the library of the project's client and
+// linked to the task.
+func (h *EvidenceHandler) UploadEvidence(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	task, ok := h.loadAuditTask(c, user)
+	if !ok {
+		return
+	}
+	clientID, ok := requireTaskClient(c, task)
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.receiveEvidence(c, user, clientID)
+	if !ok {
+		return
+	}
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(evidence).Error; err != nil {
+			return err
+		}
+		if _, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionUploaded, &user.ID, nil); err != nil {
+			return err
+		}
+		return linkEvidence(tx, task, evidence, user)
+	})
+	if err != nil {
+		h.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusCreated, response)
+}
+
+// UploadClientEvidence handles POST /api/v1/clients/:id/evidence
+//
+// Adds a file to the client's evidence library without linking it to any
+// audit task yet. The form fields are those of UploadEvidence.
+func (h *EvidenceHandler) UploadClientEvidence(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.receiveEvidence(c, user, client.ID)
+	if !ok {
+		return
+	}
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(evidence).Error; err != nil {
+			return err
+		}
+		_, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionUploaded, &user.ID, nil)
+		return err
+	})
+	if err != nil {
+		h.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetClientEvidence handles GET /api/v1/clients/:id/evidence
+//
+// Lists the client's evidence library, current versions only unless
+// allVersions=true. stale=true or stale=false filters on expiry.
+func (h *EvidenceHandler) GetClientEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "evidence")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Successors").
+		Where("client_id = ?", client.ID)
+	if c.Query("allVersions") != "true" {
+		query = query.Where(currentEvidence)
+	}
+	switch c.Query("stale") {
+	case "true":
+		query = query.Where("expires_on IS NOT NULL AND expires_on <= ?", time.Now().UTC())
+	case "false":
+		query = query.Where("expires_on IS NULL OR expires_on > ?", time.Now().UTC())
+	}
+
+	var evidence []db.Evidence
+	if err := query.Order("collected_at").Order("version").Find(&evidence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.EvidenceResponse, len(evidence))
+	for i, item := range evidence {
+		response[i] = convertToEvidenceResponse(&item)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// LinkEvidence handles POST /api/v1/audit-tasks/:id/evidence/:evidenceId
+//
+// Reuses evidence from the client's library for another audit task, in the
+// same or another project of the client. Linking an already linked item is
+// a no-op.
+func (h *EvidenceHandler) LinkEvidence(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	task, evidence, ok := h.loadLink(c, user)
+	if !ok {
+		return
+	}
+	if len(evidence.Successors) > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Evidence superseded",
+			Message: fmt.Sprintf("link the current version, evidence %d", evidence.Successors[0].ID),
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	var linked int64
+	if err := h.conn(c).Table("audit_task_evidence").
+		Where("audit_task_id = ? AND evidence_id = ?", task.ID, evidence.ID).
+		Count(&linked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to link evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if linked == 0 {
+		err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+			return linkEvidence(tx, task, evidence, user)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to link evidence",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusOK, response)
+}
+
+// UnlinkEvidence handles DELETE /api/v1/audit-tasks/:id/evidence/:evidenceId
+//
+// Removes every version of the evidence from the task. The evidence itself
+// stays in the client's library.
+func (h *EvidenceHandler) UnlinkEvidence(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	task, evidence, ok := h.loadLink(c, user)
+	if !ok {
+		return
+	}
+
+	versions, err := evidenceVersions(h.conn(c).DB, evidence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to unlink evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	unlinked := 0
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		for i := range versions {
+			result := tx.Exec("DELETE FROM audit_task_evidence WHERE audit_task_id = ? AND evidence_id = ?", task.ID, versions[i].ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			unlinked++
+			details := fmt.Sprintf("Unlinked from audit task %d", task.ID)
+			if _, err := db.AppendCustodyEvent(tx, &versions[i], db.CustodyActionUnlinked, &user.ID, &details); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to unlink evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if unlinked == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Evidence is not linked to this audit task",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Evidence unlinked successfully"})
+}
+
+// UpdateEvidence handles PUT and PATCH /api/v1/evidence/:id
+//
+// Only the source and validity period can change; the content and its
+// hash are write-once.
+func (h *EvidenceHandler) UpdateEvidence(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, nil)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateEvidenceRequest
+	if !bindUpdate(c, toUpdateEvidenceRequest(evidence), &req) {
+		return
+	}
+	if !validEvidencePeriod(c, req.ValidFrom, req.ExpiresOn) {
+		return
+	}
+
+	evidence.Source = req.Source
+	evidence.ValidFrom = utcTime(req.ValidFrom)
+	evidence.ExpiresOn = utcTime(req.ExpiresOn)
+
+	if err := h.conn(c).Omit("Successors").Save(evidence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusOK, response)
+}
+
+// UploadEvidenceVersion handles POST /api/v1/evidence/:id/versions
+//
+// Evidence is never overwritten. The new file is stored as a new evidence
+// record in the same library that supersedes the current version and is
+// linked to the same audit tasks, except those of signed off projects, which
+// keep the version they were signed off with. The form fields are those of
+// UploadEvidence.
+func (h *EvidenceHandler) UploadEvidenceVersion(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	previous, ok := h.loadEvidence(c, user, []string{"AuditTasks"})
+	if !ok {
+		return
+	}
+	if len(previous.Successors) > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Evidence already superseded",
+			Message: fmt.Sprintf("upload new versions against evidence %d", previous.Successors[0].ID),
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	evidence, ok := h.receiveEvidence(c, user, previous.ClientID)
+	if !ok {
+		return
+	}
+	evidence.Version = previous.Version + 1
+	evidence.PreviousVersionID = &previous.ID
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		// Re-check inside the transaction; the unique index on the previous
+		// version catches anything that still slips through
+		var newer int64
+		if err := tx.Model(&db.Evidence{}).Where("previous_version_id = ?", previous.ID).Count(&newer).Error; err != nil {
+			return err
+		}
+		if newer > 0 {
+			return errEvidenceSuperseded
+		}
+		if err := tx.Create(evidence).Error; err != nil {
+			return err
+		}
+
+		uploaded := fmt.Sprintf("Supersedes version %d (evidence %d)", previous.Version, previous.ID)
+		if _, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionUploaded, &user.ID, &uploaded); err != nil {
+			return err
+		}
+		superseded := fmt.Sprintf("Superseded by version %d (evidence %d)", evidence.Version, evidence.ID)
+		if _, err := db.AppendCustodyEvent(tx, previous, db.CustodyActionSuperseded, &user.ID, &superseded); err != nil {
+			return err
+		}
+		for _, task := range previous.AuditTasks {
+			database := &db.Database{DB: tx}
+			locked, err := projectLocked(database, projectIDFor(database, workflow.EntityAuditTask, task.ID))
+			if err != nil {
+				return err
+			}
+			if locked {
+				continue
+			}
+			if err := linkEvidence(tx, task, evidence, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		if errors.Is(err, errEvidenceSuperseded) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "Evidence already superseded",
+				Code:  http.StatusConflict,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create evidence version",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetAuditTaskEvidence handles GET /api/v1/audit-tasks/:id/evidence
+//
+// Only the current version of each file is listed unless allVersions=true.
+func (h *EvidenceHandler) GetAuditTaskEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "evidence")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	task, ok := h.loadAuditTask(c, user)
+	if !ok {
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Successors").
+		Joins("JOIN audit_task_evidence ON audit_task_evidence.evidence_id = evidences.id").
+		Where("audit_task_evidence.audit_task_id = ?", task.ID)
+	if c.Query("allVersions") != "true" {
+		query = query.Where(currentEvidence)
+	}
+
+	var evidence []db.Evidence
+	if err := query.Order("collected_at").Order("version").Find(&evidence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.EvidenceResponse, len(evidence))
+	for i, item := range evidence {
+		response[i] = convertToEvidenceResponse(&item)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetEvidence handles GET /api/v1/evidence/:id
+func (h *EvidenceHandler) GetEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "evidence", "collectedBy")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, preloads)
+	if !ok {
+		return
+	}
+	if !h.recordCustody(c, evidence, db.CustodyActionViewed, user, nil) {
+		return
+	}
+
+	response := convertToEvidenceResponse(evidence)
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetEvidenceVersions handles GET /api/v1/evidence/:id/versions
+//
+// Lists every version of the file, oldest first, whichever version is named.
+func (h *EvidenceHandler) GetEvidenceVersions(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, nil)
+	if !ok {
+		return
+	}
+
+	versions, err := evidenceVersions(h.conn(c).DB, evidence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch evidence versions",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.EvidenceResponse, len(versions))
+	for i, version := range versions {
+		response[i] = convertToEvidenceResponse(&version)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetEvidenceCustody handles GET /api/v1/evidence/:id/custody
+func (h *EvidenceHandler) GetEvidenceCustody(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, nil)
+	if !ok {
+		return
+	}
+
+	var events []db.CustodyEvent
+	if err := h.conn(c).Preload("User").
+		Where("evidence_id = ?", evidence.ID).
+		Order("sequence").
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch custody log",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := models.CustodyLogResponse{
+		EvidenceID: evidence.ID,
+		ChainValid: true,
+		Events:     make([]models.CustodyEventResponse, len(events)),
+	}
+	if broken := db.VerifyCustodyChain(evidence, events); broken != 0 {
+		response.ChainValid = false
+		response.BrokenAtSequence = &broken
+	}
+	for i, event := range events {
+		response.Events[i] = convertToCustodyEventResponse(&event)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadEvidence handles GET /api/v1/evidence/:id/download
+func (h *EvidenceHandler) DownloadEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, nil)
+	if !ok {
+		return
+	}
+
+	content, err := h.objects.Get(c.Request.Context(), evidence.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Evidence content not found in storage",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read evidence %s: %v", evidence.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to read evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	defer content.Close()
+
+	if !h.recordCustody(c, evidence, db.CustodyActionDownloaded, user, nil) {
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, evidence.Size, evidence.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": evidence.FileName}),
+		"ETag":                   `"` + evidence.SHA256 + `"`,
+		"X-Content-SHA256":       evidence.SHA256,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// VerifyEvidence handles POST /api/v1/evidence/:id/verify
+//
+// Re-reads the content from storage, compares its hash and size with those
+// recorded at upload and checks the custody chain. The check itself is
+// recorded as a VERIFIED custody event.
+func (h *EvidenceHandler) VerifyEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	evidence, ok := h.loadEvidence(c, user, nil)
+	if !ok {
+		return
+	}
+
+	result, err := h.verify(c, evidence, user)
+	if err != nil {
+		log.Printf("Failed to verify evidence %d: %v", evidence.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to verify evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// VerifyAuditTaskEvidence handles POST /api/v1/audit-tasks/:id/evidence/verify
+//
+// Verifies every version of every evidence file on the task.
+func (h *EvidenceHandler) VerifyAuditTaskEvidence(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	task, ok := h.loadAuditTask(c, user)
+	if !ok {
+		return
+	}
+
+	var evidence []db.Evidence
+	if err := h.conn(c).
+		Joins("JOIN audit_task_evidence ON audit_task_evidence.evidence_id = evidences.id").
+		Where("audit_task_evidence.audit_task_id = ?", task.ID).
+		Order("evidences.id").
+		Find(&evidence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	verified := true
+	results := make([]models.EvidenceVerificationResponse, len(evidence))
+	for i := range evidence {
+		result, err := h.verify(c, &evidence[i], user)
+		if err != nil {
+			log.Printf("Failed to verify evidence %d: %v", evidence[i].ID, err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to verify evidence",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+		verified = verified && result.Verified
+		results[i] = *result
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"auditTaskId": task.ID,
+		"verified":    verified,
+		"evidence":    results,
+	})
+}
+
+// verify checks one evidence item's content and custody chain and records
+// the outcome in the chain.
+func (h *EvidenceHandler) verify(c *gin.Context, evidence *db.Evidence, user *db.User) (*models.EvidenceVerificationResponse, error) {
+	result := models.EvidenceVerificationResponse{
+		EvidenceID:     evidence.ID,
+		FileName:       evidence.FileName,
+		Version:        evidence.Version,
+		ExpectedSHA256: evidence.SHA256,
+		ExpectedSize:   evidence.Size,
+		ChainValid:     true,
+		Problems:       []string{},
+	}
+
+	content, err := h.objects.Get(c.Request.Context(), evidence.StorageKey)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		result.Problems = append(result.Problems, "content not found in storage")
+	case err != nil:
+		return nil, err
+	default:
+		hash := sha256.New()
+		size, err := io.Copy(hash, content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		actual := hex.EncodeToString(hash.Sum(nil))
+		result.ContentFound = true
+		result.ActualSHA256 = &actual
+		result.ActualSize = &size
+		if actual != evidence.SHA256 {
+			result.Problems = append(result.Problems, "content hash does not match the hash recorded at upload")
+		}
+		if size != evidence.Size {
+			result.Problems = append(result.Problems, fmt.Sprintf("content is %d bytes, %d were recorded at upload", size, evidence.Size))
+		}
+	}
+
+	var events []db.CustodyEvent
+	if err := h.conn(c).Where("evidence_id = ?", evidence.ID).Order("sequence").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if broken := db.VerifyCustodyChain(evidence, events); broken != 0 {
+		result.ChainValid = false
+		result.BrokenAtSequence = &broken
+		result.Problems = append(result.Problems, fmt.Sprintf("custody chain broken at event %d", broken))
+	}
+	result.Verified = len(result.Problems) == 0
+
+	details := "Content and custody chain verified"
+	if !result.Verified {
+		details = "Verification failed: " + strings.Join(result.Problems, "; ")
+	}
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		event, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionVerified, &user.ID, &details)
+		if err == nil {
+			result.VerifiedAt = event.OccurredAt
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// recordCustody appends an event to the evidence's custody chain. Access is
+// refused when it cannot be recorded.
+func (h *EvidenceHandler) recordCustody(c *gin.Context, evidence *db.Evidence, action db.CustodyAction, user *db.User, details *string) bool {
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		_, err := db.AppendCustodyEvent(tx, evidence, action, &user.ID, details)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to record %s for evidence %d: %v", action, evidence.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to record evidence custody",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// receiveEvidence reads the uploaded file and its form fields and writes the
+// content to a fresh storage key in the client's library. The returned
+// evidence is not saved yet; the caller deletes the stored object if saving
+// fails, and it is deleted too if the batch the request is part of rolls back.
+func (h *EvidenceHandler) receiveEvidence(c *gin.Context, user *db.User, clientID uint) (*db.Evidence, bool) {
+	// Leave room for the multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && header.Size > h.maxBytes) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error:   "Evidence file too large",
+			Message: fmt.Sprintf("files may be at most %d bytes", h.maxBytes),
+			Code:    http.StatusRequestEntityTooLarge,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "No file uploaded",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+	defer file.Close()
+
+	evidence := db.Evidence{
+		ClientID:      clientID,
+		FileName:      filepath.Base(filepath.Clean("/" + strings.ReplaceAll(header.Filename, "\\", "/"))),
+		Size:          header.Size,
+		CollectedByID: user.ID,
+		CollectedAt:   time.Now().UTC(),
+		Version:       1,
+	}
+
+	if raw := c.PostForm("collectedAt"); raw != "" {
+		collectedAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: "collectedAt must be an RFC 3339 timestamp",
+				Code:    http.StatusBadRequest,
+			})
+			return nil, false
+		}
+		evidence.CollectedAt = collectedAt.UTC()
+	}
+	if raw := c.PostForm("collectedById"); raw != "" {
+		var collector db.User
+		collectorID, err := strconv.ParseUint(raw, 10, 32)
+		if err == nil {
+			err = h.conn(c).First(&collector, collectorID).Error
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: "collectedById must reference an existing user",
+				Code:    http.StatusBadRequest,
+			})
+			return nil, false
+		}
+		evidence.CollectedByID = collector.ID
+	}
+	if source := strings.TrimSpace(c.PostForm("source")); source != "" {
+		evidence.Source = &source
+	}
+	dates := []struct {
+		field  string
+		target **time.Time
+	}{
+		{"validFrom", &evidence.ValidFrom},
+		{"expiresOn", &evidence.ExpiresOn},
+	}
+	for _, date := range dates {
+		raw := c.PostForm(date.field)
+		if raw == "" {
+			continue
+		}
+		parsed, err := parseDate(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: date.field + " must be a date or an RFC 3339 timestamp",
+				Code:    http.StatusBadRequest,
+			})
+			return nil, false
+		}
+		*date.target = &parsed
+	}
+	if !validEvidencePeriod(c, evidence.ValidFrom, evidence.ExpiresOn) {
+		return nil, false
+	}
+
+	// Sniff the type from the first 512 bytes, then put them back in front
+	// of the rest of the stream
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Error reading file",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return nil, false
+	}
+	head = head[:n]
+	evidence.ContentType = sniffContentType(head, evidence.FileName)
+
+	evidence.StorageKey, err = newEvidenceKey(clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to store evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	hash := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hash)
+	if err := h.objects.Put(c.Request.Context(), evidence.StorageKey, body, evidence.Size, evidence.ContentType); err != nil {
+		log.Printf("Failed to store evidence %s: %v", evidence.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to store evidence",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+	key := evidence.StorageKey
+	onRollback(c, func() { h.objects.Delete(context.Background(), key) })
+	evidence.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return &evidence, true
+}
+
+// loadAuditTask fetches the audit task named by the :id parameter, with its
+// requirement and project, and checks that the user may access the project.
+func (h *EvidenceHandler) loadAuditTask(c *gin.Context, user *db.User) (*db.AuditTask, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid audit task ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var task db.AuditTask
+	if err := h.conn(c).Preload("Requirement.Project").First(&task, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Audit task not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireProjectAccess(c, h.conn(c), user, auditTaskProjectID(&task)) {
+		return nil, false
+	}
+	return &task, true
+}
+
+// loadEvidence fetches the evidence named by the :id parameter and checks
+// that the user may access its client's library.
+func (h *EvidenceHandler) loadEvidence(c *gin.Context, user *db.User, preloads []string) (*db.Evidence, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid evidence ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var evidence db.Evidence
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Successors").
+		First(&evidence, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Evidence not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireClientAccess(c, h.conn(c), user, evidence.ClientID) {
+		return nil, false
+	}
+	return &evidence, true
+}
+
+// loadLink fetches the audit task named by :id and the evidence named by
+// :evidenceId, which must come from the library of the task's client.
+func (h *EvidenceHandler) loadLink(c *gin.Context, user *db.User) (*db.AuditTask, *db.Evidence, bool) {
+	task, ok := h.loadAuditTask(c, user)
+	if !ok {
+		return nil, nil, false
+	}
+	clientID, ok := requireTaskClient(c, task)
+	if !ok {
+		return nil, nil, false
+	}
+
+	evidenceID, err := strconv.ParseUint(c.Param("evidenceId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid evidence ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, nil, false
+	}
+
+	var evidence db.Evidence
+	if err := h.conn(c).Preload("Successors").First(&evidence, evidenceID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Evidence not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, nil, false
+	}
+	if evidence.ClientID != clientID {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Evidence belongs to another client",
+			Message: "evidence can only be reused within the library of the task's client",
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return nil, nil, false
+	}
+	return task, &evidence, true
+}
+
+// loadClient fetches the client named by the :id parameter and checks that
+// the user may access it.
+func (h *EvidenceHandler) loadClient(c *gin.Context, user *db.User) (*db.Client, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid client ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var client db.Client
+	if err := h.conn(c).First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Client not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireClientAccess(c, h.conn(c), user, client.ID) {
+		return nil, false
+	}
+	return &client, true
+}
+
+// linkEvidence links the evidence to the audit task and records the link in
+// its custody chain.
+func linkEvidence(tx *gorm.DB, task *db.AuditTask, evidence *db.Evidence, user *db.User) error {
+	if err := tx.Exec("INSERT INTO audit_task_evidence (audit_task_id, evidence_id) VALUES (?, ?)", task.ID, evidence.ID).Error; err != nil {
+		return err
+	}
+	details := fmt.Sprintf("Linked to audit task %d", task.ID)
+	_, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionLinked, &user.ID, &details)
+	return err
+}
+
+// evidenceVersions returns every version of the evidence's file, oldest
+// first: it walks back to the first version, then forward through the
+// versions that superseded it.
+func evidenceVersions(tx *gorm.DB, evidence *db.Evidence) ([]db.Evidence, error) {
+	versions := []db.Evidence{*evidence}
+	for versions[0].PreviousVersionID != nil {
+		var previous db.Evidence
+		if err := tx.Preload("Successors").First(&previous, *versions[0].PreviousVersionID).Error; err != nil {
+			return nil, err
+		}
+		versions = append([]db.Evidence{previous}, versions...)
+	}
+	for next := evidence.Successors; len(next) > 0; {
+		var newer db.Evidence
+		if err := tx.Preload("Successors").First(&newer, next[0].ID).Error; err != nil {
+			return nil, err
+		}
+		versions = append(versions, newer)
+		next = newer.Successors
+	}
+	return versions, nil
+}
+
+// auditTaskProjectID returns the project of a task loaded with its
+// requirement. Tasks without a requirement belong to no project, so only
+// admins can access them.
+func auditTaskProjectID(task *db.AuditTask) uint {
+	if task == nil || task.Requirement == nil {
+		return 0
+	}
+	return task.Requirement.ProjectID
+}
+
+// auditTaskClientID returns the client of a task loaded with its requirement
+// and project, or 0 when the project has no client.
+func auditTaskClientID(task *db.AuditTask) uint {
+	if task == nil || task.Requirement == nil || task.Requirement.Project == nil || task.Requirement.Project.ClientID == nil {
+		return 0
+	}
+	return *task.Requirement.Project.ClientID
+}
+
+// requireTaskClient writes a 422 response when the task's project has no
+// client, since evidence always belongs to a client's library.
+func requireTaskClient(c *gin.Context, task *db.AuditTask) (uint, bool) {
+	clientID := auditTaskClientID(task)
+	if clientID == 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Audit task has no client",
+			Message: "evidence is kept in a client's library; assign the project to a client first",
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return 0, false
+	}
+	return clientID, true
+}
+
+// validEvidencePeriod writes a 400 response unless the evidence expires
+// after its validity starts.
+func validEvidencePeriod(c *gin.Context, validFrom, expiresOn *time.Time) bool {
+	if validFrom != nil && expiresOn != nil && !expiresOn.After(*validFrom) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "expiresOn must be after validFrom",
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
+
+// evidenceStale reports whether the evidence has expired by now.
+func evidenceStale(evidence *db.Evidence, now time.Time) bool {
+	return evidence.ExpiresOn != nil && !now.Before(*evidence.ExpiresOn)
+}
+
+// utcTime normalises a timestamp to UTC so stored dates compare correctly.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// parseDate accepts an RFC 3339 timestamp or a plain date, which is
+// taken as midnight UTC.
+func parseDate(raw string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	return t.UTC(), err
+}
+
+// sniffContentType detects the MIME type from the content, falling back to
+// the file extension when the content is not recognised.
+func sniffContentType(head []byte, fileName string) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExtension := mime.TypeByExtension(filepath.Ext(fileName)); byExtension != "" {
+			return byExtension
+		}
+	}
+	return contentType
+}
+
+// newEvidenceKey returns a fresh storage key below the client's prefix.
+func newEvidenceKey(clientID uint) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("evidence/clients/%d/%s", clientID, hex.EncodeToString(b[:])), nil
+}
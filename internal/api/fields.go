@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resourceTypes maps response models onto the resource names used by
+// ?fields[<resource>]= sparse fieldsets.
+var resourceTypes = map[reflect.Type]string{
+	reflect.TypeOf(models.ProjectResponse{}):          "project",
+	reflect.TypeOf(models.UserResponse{}):             "user",
+	reflect.TypeOf(models.ClientResponse{}):           "client",
+	reflect.TypeOf(models.RequirementResponse{}):      "requirement",
+	reflect.TypeOf(models.AuditTaskResponse{}):        "auditTask",
+	reflect.TypeOf(models.IssueResponse{}):            "issue",
+	reflect.TypeOf(models.RemediationResponse{}):      "remediation",
+	reflect.TypeOf(models.RetestResponse{}):           "retest",
+	reflect.TypeOf(models.SignOffResponse{}):          "signOff",
+	reflect.TypeOf(models.EvidenceResponse{}):         "evidence",
+	reflect.TypeOf(models.DocumentRequestResponse{}):  "documentRequest",
+	reflect.TypeOf(models.ProjectTemplateResponse{}):  "projectTemplate",
+	reflect.TypeOf(models.FrameworkResponse{}):        "framework",
+	reflect.TypeOf(models.FrameworkControlResponse{}): "frameworkControl",
+}
+
+// renderResource writes a resource response, trimming each resource down to
+// the attributes requested via ?fields[<resource>]=a,b. The id and any
+// included relationships are always kept.
+func renderResource(c *gin.Context, status int, data interface{}) {
+	fieldsets, err := parseFieldsets(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid fields",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if len(fieldsets) == 0 {
+		c.JSON(status, data)
+		return
+	}
+
+	c.JSON(status, sparse(reflect.ValueOf(data), fieldsets))
+}
+
+// parseFieldsets reads every fields[<resource>] query parameter and checks
+// that both the resource and the requested attributes exist.
+func parseFieldsets(c *gin.Context) (map[string]map[string]bool, error) {
+	fieldsets := make(map[string]map[string]bool)
+	for resource, raw := range c.QueryMap("fields") {
+		typ := resourceTypeByName(resource)
+		if typ == nil {
+			return nil, fmt.Errorf("unknown resource type %q", resource)
+		}
+
+		known := make(map[string]bool)
+		for i := 0; i < typ.NumField(); i++ {
+			known[jsonName(typ.Field(i))] = true
+		}
+
+		fieldsets[resource] = make(map[string]bool)
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if !known[field] {
+				return nil, fmt.Errorf("%q is not a field of %s", field, resource)
+			}
+			fieldsets[resource][field] = true
+		}
+	}
+	return fieldsets, nil
+}
+
+func resourceTypeByName(name string) reflect.Type {
+	for typ, resource := range resourceTypes {
+		if resource == name {
+			return typ
+		}
+	}
+	return nil
+}
+
+// sparse rebuilds a response value, replacing every resource struct with a
+// map holding only the selected fields.
+func sparse(v reflect.Value, fieldsets map[string]map[string]bool) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return sparse(v.Elem(), fieldsets)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = sparse(v.Index(i), fieldsets)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = sparse(iter.Value(), fieldsets)
+		}
+		return out
+	case reflect.Struct:
+		resource, ok := resourceTypes[v.Type()]
+		if !ok {
+			return v.Interface()
+		}
+		selected, restricted := fieldsets[resource]
+
+		out := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := jsonName(field)
+			value := v.Field(i)
+
+			if strings.Contains(field.Tag.Get("json"), "omitempty") && value.IsZero() {
+				continue
+			}
+			if restricted && name != "id" && !selected[name] && !isRelationship(field.Type) {
+				continue
+			}
+			out[name] = sparse(value, fieldsets)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// isRelationship reports whether a response field holds other resources,
+// which are controlled by ?include= rather than by fieldsets.
+func isRelationship(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	_, ok := resourceTypes[typ]
+	return ok
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
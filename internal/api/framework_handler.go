@@ -0,0 +1,847 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errDuplicateControl rejects a control ID that already exists in a framework.
+var errDuplicateControl = errors.New("control already exists in this framework")
+
+// FrameworkHandler serves the compliance framework catalog and applies
+// frameworks to projects
+type FrameworkHandler struct {
+	store
+}
+
+func NewFrameworkHandler(database *db.Database) *FrameworkHandler {
+	return &FrameworkHandler{store{db: database}}
+}
+
+// GetFrameworks handles GET /api/v1/frameworks
+func (h *FrameworkHandler) GetFrameworks(c *gin.Context) {
+	preloads, err := parseIncludes(c, "framework")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "")
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+
+	var frameworks []db.Framework
+	if err := query.Order("name, version").Find(&frameworks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch frameworks",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.FrameworkResponse, len(frameworks))
+	for i, framework := range frameworks {
+		response[i] = convertToFrameworkResponse(&framework)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetFramework handles GET /api/v1/frameworks/:id
+func (h *FrameworkHandler) GetFramework(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "framework", "controls")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&framework, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	response := convertToFrameworkResponse(&framework)
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateFramework handles POST /api/v1/frameworks
+//
+// The request may carry the framework's controls, so a whole catalog can be
+// imported in one call.
+func (h *FrameworkHandler) CreateFramework(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	var req models.CreateFrameworkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if frameworkExists(h.conn(c), req.Name, req.Version, 0) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: fmt.Sprintf("Framework %s %s already exists", req.Name, req.Version),
+			Code:  http.StatusConflict,
+		})
+		return
+	}
+
+	framework := db.Framework{
+		Name:        req.Name,
+		Version:     req.Version,
+		Description: req.Description,
+	}
+	seen := map[string]bool{}
+	for _, control := range req.Controls {
+		if seen[control.ControlID] {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: fmt.Sprintf("control %s is listed more than once", control.ControlID),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		seen[control.ControlID] = true
+		framework.Controls = append(framework.Controls, &db.FrameworkControl{
+			ControlID:   control.ControlID,
+			Title:       control.Title,
+			Description: control.Description,
+			Category:    control.Category,
+		})
+	}
+
+	if err := h.conn(c).Create(&framework).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create framework",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToFrameworkResponse(&framework)
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateFramework handles PUT and PATCH /api/v1/frameworks/:id
+func (h *FrameworkHandler) UpdateFramework(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := h.conn(c).First(&framework, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var req models.UpdateFrameworkRequest
+	if !bindUpdate(c, toUpdateFrameworkRequest(&framework), &req) {
+		return
+	}
+
+	if frameworkExists(h.conn(c), req.Name, req.Version, framework.ID) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: fmt.Sprintf("Framework %s %s already exists", req.Name, req.Version),
+			Code:  http.StatusConflict,
+		})
+		return
+	}
+
+	framework.Name = req.Name
+	framework.Version = req.Version
+	framework.Description = req.Description
+
+	if err := h.conn(c).Save(&framework).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update framework",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToFrameworkResponse(&framework)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteFramework handles DELETE /api/v1/frameworks/:id
+//
+// Requirements already created from the framework keep their text; their
+// link to the deleted controls is no longer resolved.
+func (h *FrameworkHandler) DeleteFramework(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("framework_id = ?", id).Delete(&db.FrameworkControl{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.Framework{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete framework",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Framework deleted successfully"})
+}
+
+// GetFrameworkControls handles GET /api/v1/frameworks/:id/controls
+func (h *FrameworkHandler) GetFrameworkControls(c *gin.Context) {
+	frameworkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "frameworkControl")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "").Where("framework_id = ?", frameworkID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var controls []db.FrameworkControl
+	if err := query.Order("control_id").Find(&controls).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch framework controls",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.FrameworkControlResponse, len(controls))
+	for i, control := range controls {
+		response[i] = convertToFrameworkControlResponse(&control)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateFrameworkControl handles POST /api/v1/frameworks/:id/controls
+func (h *FrameworkHandler) CreateFrameworkControl(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	frameworkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.CreateFrameworkControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := h.conn(c).First(&framework, frameworkID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	if controlExists(h.conn(c), framework.ID, req.ControlID, 0) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Duplicate control",
+			Message: fmt.Sprintf("%s: %v", req.ControlID, errDuplicateControl),
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	control := db.FrameworkControl{
+		FrameworkID: framework.ID,
+		ControlID:   req.ControlID,
+		Title:       req.Title,
+		Description: req.Description,
+		Category:    req.Category,
+	}
+
+	if err := h.conn(c).Create(&control).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create framework control",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToFrameworkControlResponse(&control)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetFrameworkControl handles GET /api/v1/framework-controls/:id
+func (h *FrameworkHandler) GetFrameworkControl(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework control ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "frameworkControl", "framework")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var control db.FrameworkControl
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&control, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework control not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	response := convertToFrameworkControlResponse(&control)
+	renderResource(c, http.StatusOK, response)
+}
+
+// UpdateFrameworkControl handles PUT and PATCH /api/v1/framework-controls/:id
+func (h *FrameworkHandler) UpdateFrameworkControl(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework control ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var control db.FrameworkControl
+	if err := h.conn(c).First(&control, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework control not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var req models.UpdateFrameworkControlRequest
+	if !bindUpdate(c, toUpdateFrameworkControlRequest(&control), &req) {
+		return
+	}
+
+	if controlExists(h.conn(c), control.FrameworkID, req.ControlID, control.ID) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Duplicate control",
+			Message: fmt.Sprintf("%s: %v", req.ControlID, errDuplicateControl),
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	control.ControlID = req.ControlID
+	control.Title = req.Title
+	control.Description = req.Description
+	control.Category = req.Category
+
+	if err := h.conn(c).Save(&control).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update framework control",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToFrameworkControlResponse(&control)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteFrameworkControl handles DELETE /api/v1/framework-controls/:id
+func (h *FrameworkHandler) DeleteFrameworkControl(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework control ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.conn(c).Delete(&db.FrameworkControl{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete framework control",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Framework control deleted successfully"})
+}
+
+// UploadFrameworkControlsCSV handles POST /api/v1/uploads/framework-controls-csv/:frameworkId
+//
+// Columns are Control ID, Title, Description and Category. Rows whose control
+// ID already exists update that control, so a catalog can be re-imported when
+// the framework is revised. A leading header row is skipped.
+func (h *FrameworkHandler) UploadFrameworkControlsCSV(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	frameworkID, err := strconv.ParseUint(c.Param("frameworkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid framework ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := h.conn(c).First(&framework, frameworkID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "No file uploaded",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	var rows [][]string
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Error reading CSV",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "Control ID") {
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Error reading CSV",
+				Message: fmt.Sprintf("line %d: control ID and title are required", line),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		rows = append(rows, record)
+	}
+
+	created, updated := 0, 0
+	var controls []db.FrameworkControl
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		for _, record := range rows {
+			var control db.FrameworkControl
+			err := tx.Where("framework_id = ? AND control_id = ?", framework.ID, strings.TrimSpace(record[0])).First(&control).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if control.ID == 0 {
+				created++
+			} else {
+				updated++
+			}
+
+			control.FrameworkID = framework.ID
+			control.ControlID = strings.TrimSpace(record[0])
+			control.Title = strings.TrimSpace(record[1])
+			control.Description = csvColumn(record, 2)
+			control.Category = csvColumn(record, 3)
+			if err := tx.Save(&control).Error; err != nil {
+				return err
+			}
+			controls = append(controls, control)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to import framework controls from CSV",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.FrameworkControlResponse, len(controls))
+	for i, control := range controls {
+		response[i] = convertToFrameworkControlResponse(&control)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Framework controls imported successfully",
+		"created":  created,
+		"updated":  updated,
+		"controls": response,
+	})
+}
+
+// ApplyFramework handles POST /api/v1/projects/:id/frameworks
+//
+// A requirement is created for every control of the framework that the
+// project does not cover yet, linked back to its source control.
+func (h *FrameworkHandler) ApplyFramework(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.ApplyFrameworkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var framework db.Framework
+	if err := h.conn(c).Preload("Controls", func(query *gorm.DB) *gorm.DB {
+		return query.Order("control_id")
+	}).First(&framework, req.FrameworkID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Framework not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	if err := checkInitialStatus(h.conn(c), workflow.EntityRequirement, project.ID, string(db.RequirementStatusNotTested)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+
+	var createdRequirements []db.Requirement
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		createdRequirements, err = applyControls(tx, project.ID, framework.Controls, func(control *db.FrameworkControl) string {
+			return fmt.Sprintf("%s %s", control.ControlID, control.Title)
+		})
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to apply framework",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.RequirementResponse, len(createdRequirements))
+	for i, requirement := range createdRequirements {
+		response[i] = convertToRequirementResponse(&requirement)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Framework applied successfully",
+		"count":        len(createdRequirements),
+		"requirements": response,
+	})
+}
+
+// GetProjectFrameworks handles GET /api/v1/projects/:id/frameworks
+//
+// Returns the coverage of every framework applied to the project.
+func (h *FrameworkHandler) GetProjectFrameworks(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	statuses, err := projectControlStatuses(h.conn(c), project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project requirements",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	controlIDs := make([]uint, 0, len(statuses))
+	for id := range statuses {
+		controlIDs = append(controlIDs, id)
+	}
+
+	var frameworks []db.Framework
+	if err := h.conn(c).Preload("Controls").
+		Where("id IN (?)", h.conn(c).Model(&db.FrameworkControl{}).Select("framework_id").Where("id IN ?", controlIDs)).
+		Order("name, version").
+		Find(&frameworks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch frameworks",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.FrameworkCoverageResponse, len(frameworks))
+	for i, framework := range frameworks {
+		response[i] = frameworkCoverage(&framework, statuses)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// projectControlStatuses reports the coverage status of each framework
+// control a project has requirements for, from the combined results of the
+// requirements created from it (see combineResults): met directly or
+// through a compensating control, partially met, not applicable, or not met.
+func projectControlStatuses(database *db.Database, projectID uint) (map[uint]string, error) {
+	var requirements []db.Requirement
+	if err := database.Where("project_id = ? AND framework_control_id IS NOT NULL", projectID).
+		Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+
+	results := map[uint][]db.RequirementStatus{}
+	for _, requirement := range requirements {
+		id := *requirement.FrameworkControlID
+		results[id] = append(results[id], requirement.Status)
+	}
+
+	statuses := make(map[uint]string, len(results))
+	for id, controlResults := range results {
+		switch result := combineResults(controlResults); {
+		case satisfied(result):
+			statuses[id] = coverageMet
+		case result == db.RequirementStatusPartiallyMet:
+			statuses[id] = coveragePartiallyMet
+		case result == db.RequirementStatusNotApplicable:
+			statuses[id] = coverageNotApplicable
+		default:
+			statuses[id] = coverageNotMet
+		}
+	}
+	return statuses, nil
+}
+
+// applyControls creates a NOT_MET requirement for each control the project
+// does not cover yet, linked back to the control. text builds the
+// requirement text from the control.
+func applyControls(tx *gorm.DB, projectID uint, controls []*db.FrameworkControl, text func(*db.FrameworkControl) string) ([]db.Requirement, error) {
+	var applied []uint
+	if err := tx.Model(&db.Requirement{}).
+		Where("project_id = ? AND framework_control_id IS NOT NULL", projectID).
+		Pluck("framework_control_id", &applied).Error; err != nil {
+		return nil, err
+	}
+	alreadyApplied := map[uint]bool{}
+	for _, id := range applied {
+		alreadyApplied[id] = true
+	}
+
+	var created []db.Requirement
+	for _, control := range controls {
+		if alreadyApplied[control.ID] {
+			continue
+		}
+		requirement := db.Requirement{
+			ProjectID:          projectID,
+			Text:               text(control),
+			Category:           control.Category,
+			Status:             db.RequirementStatusNotTested,
+			FrameworkControlID: &control.ID,
+		}
+		if err := tx.Create(&requirement).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, requirement)
+	}
+	return created, nil
+}
+
+// frameworkCoverage summarises a framework with preloaded controls against a
+// project's control statuses. Controls assessed as not applicable are left
+// out of the coverage.
+func frameworkCoverage(framework *db.Framework, statuses map[uint]string) models.FrameworkCoverageResponse {
+	coverage := models.FrameworkCoverageResponse{
+		FrameworkID:   framework.ID,
+		Name:          framework.Name,
+		Version:       framework.Version,
+		TotalControls: len(framework.Controls),
+	}
+	for _, control := range framework.Controls {
+		status, applied := statuses[control.ID]
+		if applied {
+			coverage.AppliedControls++
+		}
+		switch status {
+		case coverageMet:
+			coverage.MetControls++
+		case coverageNotApplicable:
+			coverage.NotApplicableControls++
+		}
+	}
+	if applicable := coverage.TotalControls - coverage.NotApplicableControls; applicable > 0 {
+		coverage.Coverage = percentage(coverage.MetControls, applicable)
+	}
+	return coverage
+}
+
+// percentage returns part/total as a percentage rounded to one decimal place.
+func percentage(part, total int) float64 {
+	return float64(part*1000/total) / 10
+}
+
+func frameworkExists(database *db.Database, name, version string, excludeID uint) bool {
+	var count int64
+	database.Model(&db.Framework{}).
+		Where("name = ? AND version = ? AND id <> ?", name, version, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+func controlExists(database *db.Database, frameworkID uint, controlID string, excludeID uint) bool {
+	var count int64
+	database.Model(&db.FrameworkControl{}).
+		Where("framework_id = ? AND control_id = ? AND id <> ?", frameworkID, controlID, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+// csvColumn returns the trimmed value of an optional CSV column, or nil when
+// it is missing or empty.
+func csvColumn(record []string, index int) *string {
+	if index >= len(record) || strings.TrimSpace(record[index]) == "" {
+		return nil
+	}
+	value := strings.TrimSpace(record[index])
+	return &value
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	idempotencyHeader     = "Idempotency-Key"
+	idempotentReplayed    = "Idempotent-Replayed"
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// idempotencyTTL reads how long idempotency keys are remembered from
+// IDEMPOTENCY_TTL (a Go duration such as "48h"), defaulting to 24 hours.
+func idempotencyTTL() time.Duration {
+	raw := os.Getenv("IDEMPOTENCY_TTL")
+	if raw == "" {
+		return defaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		log.Printf("Ignoring invalid IDEMPOTENCY_TTL %q", raw)
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request's response is stored; a retry with the same key
+// and body replays it, while the same key with a different body is rejected
+// with 422. Keys belong to the user who sent them, so another user reusing
+// a key never sees the stored response. Bodies larger than maxBytes are
+// rejected with 413 before they are buffered for hashing.
+func idempotency(database *db.Database, ttl time.Duration, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Idempotency key must be at most 255 characters",
+				Code:  http.StatusBadRequest,
+			})
+			return
+		}
+
+		var userID uint
+		if user := currentUser(c, database); user != nil {
+			userID = user.ID
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		hash, err := requestHash(c)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+				Error:   "Request body too large",
+				Message: fmt.Sprintf("requests may be at most %d bytes", maxBytes),
+				Code:    http.StatusRequestEntityTooLarge,
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+
+		database.Where("expires_at < ?", time.Now()).Delete(&db.IdempotencyRecord{})
+
+		record := db.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+		if err := database.Create(&record).Error; err != nil {
+			replayIdempotent(c, database, userID, key, hash)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not remembered so the client can retry them
+		if recorder.Status() >= http.StatusInternalServerError {
+			database.Delete(&record)
+			return
+		}
+
+		database.Model(&record).Updates(db.IdempotencyRecord{
+			StatusCode:   recorder.Status(),
+			ContentType:  recorder.Header().Get("Content-Type"),
+			ResponseBody: recorder.body.Bytes(),
+		})
+	}
+}
+
+// replayIdempotent answers a request whose key has been seen before.
+func replayIdempotent(c *gin.Context, database *db.Database, userID uint, key, hash string) {
+	var existing db.IdempotencyRecord
+	err := database.Where(map[string]interface{}{"user_id": userID, "key": key}).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Idempotency key is being processed, retry shortly",
+			Code:  http.StatusConflict,
+		})
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check idempotency key",
+			Code:  http.StatusInternalServerError,
+		})
+	case existing.RequestHash != hash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Idempotency key reused with a different request",
+			Message: "Send a new Idempotency-Key for a different request",
+			Code:    http.StatusUnprocessableEntity,
+		})
+	case existing.StatusCode == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Original request with this idempotency key is still in progress",
+			Code:  http.StatusConflict,
+		})
+	default:
+		c.Header(idempotentReplayed, "true")
+		c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+		c.Abort()
+	}
+}
+
+// requestHash fingerprints the method, path and body of a request, then
+// restores the body for the handler. Multipart bodies are hashed part by
+// part so a retry with a new boundary still matches.
+func requestHash(c *gin.Context) (string, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+
+	mediaType, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		h.Write(body)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, part.FormName()+"\x00"+part.FileName()+"\x00")
+		if _, err := io.Copy(h, part); err != nil {
+			return "", err
+		}
+		io.WriteString(h, "\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bodyRecorder copies everything written to the response so it can be stored.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxIncludeDepth caps how many relationships a single include path may
+// traverse, e.g. requirements.auditTasks.issues is three levels deep.
+const maxIncludeDepth = 3
+
+// relationship maps a JSON relationship name onto the GORM association that
+// loads it and the resource type found on the other side.
+type relationship struct {
+	association string
+	resource    string
+}
+
+// includeWhitelist lists the relationships each resource type exposes via
+// ?include=. Anything not listed here is rejected.
+var includeWhitelist = map[string]map[string]relationship{
+	"project": {
+		"client":       {association: "Client", resource: "client"},
+		"users":        {association: "Users", resource: "user"},
+		"requirements": {association: "Requirements", resource: "requirement"},
+		"rolledFrom":   {association: "RolledFrom", resource: "project"},
+		"template":     {association: "Template", resource: "projectTemplate"},
+	},
+	"user": {
+		"client":   {association: "Client", resource: "client"},
+		"projects": {association: "Projects", resource: "project"},
+	},
+	"client": {
+		"users":    {association: "Users", resource: "user"},
+		"projects": {association: "Projects", resource: "project"},
+	},
+	"requirement": {
+		"project":          {association: "Project", resource: "project"},
+		"auditTasks":       {association: "AuditTasks", resource: "auditTask"},
+		"issues":           {association: "Issues", resource: "issue"},
+		"frameworkControl": {association: "FrameworkControl", resource: "frameworkControl"},
+		"parent":           {association: "Parent", resource: "requirement"},
+		"children":         {association: "Children", resource: "requirement"},
+	},
+	"auditTask": {
+		"requirement": {association: "Requirement", resource: "requirement"},
+		"issues":      {association: "Issues", resource: "issue"},
+		"evidence":    {association: "Evidence", resource: "evidence"},
+		"assignee":    {association: "Assignee", resource: "user"},
+		"reviewer":    {association: "Reviewer", resource: "user"},
+		"preparedBy":  {association: "PreparedBy", resource: "user"},
+		"reviewedBy":  {association: "ReviewedBy", resource: "user"},
+	},
+	"evidence": {
+		"client":      {association: "Client", resource: "client"},
+		"auditTasks":  {association: "AuditTasks", resource: "auditTask"},
+		"collectedBy": {association: "CollectedBy", resource: "user"},
+	},
+	"issue": {
+		"auditTask":   {association: "AuditTask", resource: "auditTask"},
+		"assignee":    {association: "Assignee", resource: "user"},
+		"reviewer":    {association: "Reviewer", resource: "user"},
+		"remediation": {association: "Remediation", resource: "remediation"},
+		"retests":     {association: "Retests", resource: "retest"},
+		"carriedFrom": {association: "CarriedFrom", resource: "issue"},
+	},
+	"remediation": {
+		"owner":       {association: "Owner", resource: "user"},
+		"respondedBy": {association: "RespondedBy", resource: "user"},
+		"updates":     {association: "Updates", resource: "remediationUpdate"},
+	},
+	"remediationUpdate": {
+		"author": {association: "Author", resource: "user"},
+	},
+	"signOff": {
+		"signer": {association: "Signer", resource: "user"},
+	},
+	"retest": {
+		"evidence":    {association: "Evidence", resource: "evidence"},
+		"performedBy": {association: "PerformedBy", resource: "user"},
+	},
+	"documentRequest": {
+		"project":     {association: "Project", resource: "project"},
+		"assignee":    {association: "Assignee", resource: "user"},
+		"requestedBy": {association: "RequestedBy", resource: "user"},
+		"evidence":    {association: "Evidence", resource: "evidence"},
+	},
+	"projectTemplate": {
+		"createdBy":     {association: "CreatedBy", resource: "user"},
+		"sourceProject": {association: "SourceProject", resource: "project"},
+	},
+	"framework": {
+		"controls": {association: "Controls", resource: "frameworkControl"},
+	},
+	"frameworkControl": {
+		"framework": {association: "Framework", resource: "framework"},
+	},
+}
+
+// embeddedAssociations lists associations a resource's response is always
+// built from, such as the IDs of linked records. They are preloaded wherever
+// the resource is included.
+var embeddedAssociations = map[string][]string{
+	"issue":       {"Requirements"},
+	"remediation": {"Milestones"},
+}
+
+// parseIncludes validates the ?include= query parameter for the given
+// resource type and returns the GORM preload paths it resolves to. When the
+// parameter is absent the endpoint's defaults are used instead, so existing
+// clients keep receiving the same payloads.
+func parseIncludes(c *gin.Context, resource string, defaults ...string) ([]string, error) {
+	raw, ok := c.GetQuery("include")
+	paths := defaults
+	if ok {
+		paths = nil
+		for _, path := range strings.Split(raw, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	preloads := make([]string, 0, len(paths))
+	for _, path := range paths {
+		resolved, err := resolveIncludePath(resource, path)
+		if err != nil {
+			return nil, err
+		}
+		preloads = append(preloads, resolved...)
+	}
+
+	return preloads, nil
+}
+
+// resolveIncludePath walks a dotted include path through the whitelist and
+// returns the matching GORM preload path, followed by the embedded
+// associations of every resource along it.
+func resolveIncludePath(resource, path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	if len(segments) > maxIncludeDepth {
+		return nil, fmt.Errorf("include path %q exceeds the maximum depth of %d", path, maxIncludeDepth)
+	}
+
+	associations := make([]string, len(segments))
+	var embedded []string
+	current := resource
+	for i, segment := range segments {
+		rel, ok := includeWhitelist[current][segment]
+		if !ok {
+			return nil, fmt.Errorf("%q is not an includable relationship of %s", segment, current)
+		}
+		associations[i] = rel.association
+		current = rel.resource
+		for _, association := range embeddedAssociations[current] {
+			embedded = append(embedded, strings.Join(associations[:i+1], ".")+"."+association)
+		}
+	}
+
+	return append([]string{strings.Join(associations, ".")}, embedded...), nil
+}
+
+// withPreloads applies the resolved preload paths to a query, optionally
+// nested under an association the handler already loads itself.
+func withPreloads(query *gorm.DB, preloads []string, prefix string) *gorm.DB {
+	for _, preload := range preloads {
+		if prefix != "" {
+			preload = prefix + "." + preload
+		}
+		query = query.Preload(preload)
+	}
+	return query
+}
@@ -1,34 +1,61 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"tessellate-projects/internal/db"
 	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/risk"
+	"tessellate-projects/internal/workflow"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // IssueHandler
 type IssueHandler struct {
-	db *db.Database
+	store
 }
 
 func NewIssueHandler(database *db.Database) *IssueHandler {
-	return &IssueHandler{db: database}
+	return &IssueHandler{store{db: database}}
 }
 
 func (h *IssueHandler) GetIssues(c *gin.Context) {
 	var issues []db.Issue
 
-	// Optional audit task filter
+	preloads, err := parseIncludes(c, "issue")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Optional audit task and requirement filters
 	auditTaskID := c.Query("auditTaskId")
-	query := h.db.DB
+	query := withPreloads(h.conn(c).DB, preloads, "").Preload("Requirements")
 
 	if auditTaskID != "" {
 		query = query.Where("audit_task_id = ?", auditTaskID)
 	}
+	if requirementID := c.Query("requirementId"); requirementID != "" {
+		query = query.Where("id IN (SELECT issue_id FROM issue_requirements WHERE requirement_id = ?)", requirementID)
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where(overdueIssue, time.Now().UTC(), closedIssueStatuses)
+	}
+	if assigneeID := c.Query("assigneeId"); assigneeID != "" {
+		query = query.Where("issues.assignee_id = ?", assigneeID)
+	}
+	if reviewerID := c.Query("reviewerId"); reviewerID != "" {
+		query = query.Where("issues.reviewer_id = ?", reviewerID)
+	}
 
 	if err := query.Find(&issues).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -40,10 +67,10 @@ func (h *IssueHandler) GetIssues(c *gin.Context) {
 
 	response := make([]models.IssueResponse, len(issues))
 	for i, issue := range issues {
-		response[i] = h.convertToIssueResponse(&issue)
+		response[i] = convertToIssueResponse(&issue)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *IssueHandler) CreateIssue(c *gin.Context) {
@@ -56,17 +83,7 @@ func (h *IssueHandler) CreateIssue(c *gin.Context) {
 		return
 	}
 
-	type CreateIssueRequest struct {
-		Title       string  `json:"title" binding:"required"`
-		Description *string `json:"description,omitempty"`
-		Priority    *string `json:"priority,omitempty"`
-		Phase       *string `json:"phase,omitempty"`
-		EstimateHrs *int    `json:"estimateHrs,omitempty"`
-		Status      *string `json:"status,omitempty"`
-		Type        *string `json:"type,omitempty"`
-	}
-
-	var req CreateIssueRequest
+	var req models.CreateIssueRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Invalid request",
@@ -78,7 +95,7 @@ func (h *IssueHandler) CreateIssue(c *gin.Context) {
 
 	// Verify audit task exists
 	var auditTask db.AuditTask
-	if err := h.db.First(&auditTask, auditTaskID).Error; err != nil {
+	if err := h.conn(c).First(&auditTask, auditTaskID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Audit task not found",
 			Code:  http.StatusNotFound,
@@ -86,28 +103,60 @@ func (h *IssueHandler) CreateIssue(c *gin.Context) {
 		return
 	}
 
-	status := "OPEN"
+	status := db.IssueStatusOpen
 	if req.Status != nil {
-		status = *req.Status
+		status = db.IssueStatus(*req.Status)
 	}
 
-	issueType := "DEFECT"
+	issueType := db.IssueTypeDefect
 	if req.Type != nil {
-		issueType = *req.Type
+		issueType = db.IssueType(*req.Type)
+	}
+
+	projectID := projectIDFor(h.conn(c), workflow.EntityAuditTask, auditTask.ID)
+	if err := checkInitialStatus(h.conn(c), workflow.EntityIssue, projectID, string(status)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+
+	// An issue fails the requirement it was found under unless told otherwise
+	requirementIDs := req.RequirementIDs
+	if len(requirementIDs) == 0 && auditTask.RequirementID != 0 {
+		requirementIDs = []uint{auditTask.RequirementID}
+	}
+	requirements, ok := h.issueRequirements(c, projectID, requirementIDs)
+	if !ok {
+		return
+	}
+	if !checkAssignment(c, h.conn(c), projectID, req.AssigneeID, req.ReviewerID, nil, nil) {
+		return
 	}
 
 	issue := db.Issue{
-		AuditTaskID: uint(auditTaskID),
-		Title:       req.Title,
-		Description: req.Description,
-		Priority:    req.Priority,
-		Phase:       req.Phase,
-		EstimateHrs: req.EstimateHrs,
-		Status:      status,
-		Type:        issueType,
+		AuditTaskID:          uint(auditTaskID),
+		Requirements:         requirements,
+		Title:                req.Title,
+		Description:          req.Description,
+		Priority:             (*db.IssuePriority)(req.Priority),
+		Phase:                (*db.IssuePhase)(req.Phase),
+		EstimateHrs:          req.EstimateHrs,
+		Status:               status,
+		Type:                 issueType,
+		InherentLikelihood:   req.InherentLikelihood,
+		InherentImpact:       req.InherentImpact,
+		ResidualLikelihood:   req.ResidualLikelihood,
+		ResidualImpact:       req.ResidualImpact,
+		CompensatingControls: req.CompensatingControls,
+		DueDate:              utcTime(req.DueDate),
+		DueDateFromSLA:       req.DueDate == nil,
+		AssigneeID:           req.AssigneeID,
+		ReviewerID:           req.ReviewerID,
+	}
+	if !h.scoreIssue(c, projectID, &issue) || !h.scheduleIssue(c, projectID, &issue) {
+		return
 	}
 
-	if err := h.db.Create(&issue).Error; err != nil {
+	if err := h.conn(c).Omit("Requirements.*").Create(&issue).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create issue",
 			Code:  http.StatusInternalServerError,
@@ -115,7 +164,7 @@ func (h *IssueHandler) CreateIssue(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToIssueResponse(&issue)
+	response := convertToIssueResponse(&issue)
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -129,8 +178,18 @@ func (h *IssueHandler) GetIssue(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "issue")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var issue db.Issue
-	if err := h.db.First(&issue, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Preload("Requirements").First(&issue, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Issue not found",
 			Code:  http.StatusNotFound,
@@ -138,8 +197,8 @@ func (h *IssueHandler) GetIssue(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToIssueResponse(&issue)
-	c.JSON(http.StatusOK, response)
+	response := convertToIssueResponse(&issue)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *IssueHandler) UpdateIssue(c *gin.Context) {
@@ -152,28 +211,8 @@ func (h *IssueHandler) UpdateIssue(c *gin.Context) {
 		return
 	}
 
-	type UpdateIssueRequest struct {
-		Title       *string `json:"title,omitempty"`
-		Description *string `json:"description,omitempty"`
-		Priority    *string `json:"priority,omitempty"`
-		Phase       *string `json:"phase,omitempty"`
-		EstimateHrs *int    `json:"estimateHrs,omitempty"`
-		Status      *string `json:"status,omitempty"`
-		Type        *string `json:"type,omitempty"`
-	}
-
-	var req UpdateIssueRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var issue db.Issue
-	if err := h.db.First(&issue, id).Error; err != nil {
+	if err := h.conn(c).Preload("Requirements").First(&issue, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Issue not found",
 			Code:  http.StatusNotFound,
@@ -181,38 +220,83 @@ func (h *IssueHandler) UpdateIssue(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Title != nil {
-		issue.Title = *req.Title
-	}
-	if req.Description != nil {
-		issue.Description = req.Description
-	}
-	if req.Priority != nil {
-		issue.Priority = req.Priority
-	}
-	if req.Phase != nil {
-		issue.Phase = req.Phase
+	var req models.UpdateIssueRequest
+	if !bindUpdate(c, toUpdateIssueRequest(&issue), &req) {
+		return
 	}
-	if req.EstimateHrs != nil {
-		issue.EstimateHrs = req.EstimateHrs
+
+	projectID := projectIDFor(h.conn(c), workflow.EntityIssue, issue.ID)
+	requirements, ok := h.issueRequirements(c, projectID, req.RequirementIDs)
+	if !ok {
+		return
 	}
-	if req.Status != nil {
-		issue.Status = *req.Status
+	if !checkAssignment(c, h.conn(c), projectID, req.AssigneeID, req.ReviewerID, issue.AssigneeID, issue.ReviewerID) {
+		return
 	}
-	if req.Type != nil {
-		issue.Type = *req.Type
+
+	var user *db.User
+	fields := map[string]string{}
+	from := string(issue.Status)
+	if db.IssueStatus(req.Status) != issue.Status {
+		var ok bool
+		if user, ok = requireUser(c, h.conn(c)); !ok {
+			return
+		}
+		if req.ResolutionNote != nil {
+			fields["resolutionNote"] = *req.ResolutionNote
+		}
+	}
+
+	issue.Title = req.Title
+	issue.Description = req.Description
+	issue.Priority = (*db.IssuePriority)(req.Priority)
+	issue.Phase = (*db.IssuePhase)(req.Phase)
+	issue.EstimateHrs = req.EstimateHrs
+	issue.Status = db.IssueStatus(req.Status)
+	issue.Type = db.IssueType(req.Type)
+	issue.ResolutionNote = req.ResolutionNote
+	issue.InherentLikelihood = req.InherentLikelihood
+	issue.InherentImpact = req.InherentImpact
+	issue.ResidualLikelihood = req.ResidualLikelihood
+	issue.ResidualImpact = req.ResidualImpact
+	issue.CompensatingControls = req.CompensatingControls
+	issue.AssigneeID = req.AssigneeID
+	issue.ReviewerID = req.ReviewerID
+	if !sameTime(req.DueDate, issue.DueDate) {
+		issue.DueDate = utcTime(req.DueDate)
+		issue.DueDateFromSLA = req.DueDate == nil
+	}
+	if !h.scoreIssue(c, projectID, &issue) || !h.scheduleIssue(c, projectID, &issue) {
+		return
 	}
 
-	if err := h.db.Save(&issue).Error; err != nil {
+	// The transition is only recorded if the issue saves with it
+	var transitionErr error
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if user != nil {
+			if _, transitionErr = changeStatus(&db.Database{DB: tx}, user, workflow.EntityIssue, issue.ID, from, req.Status, fields); transitionErr != nil {
+				return transitionErr
+			}
+		}
+		if err := tx.Omit("Requirements").Save(&issue).Error; err != nil {
+			return err
+		}
+		return tx.Model(&issue).Omit("Requirements.*").Association("Requirements").Replace(requirements)
+	})
+	if transitionErr != nil {
+		writeTransitionError(c, transitionErr)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update issue",
 			Code:  http.StatusInternalServerError,
 		})
 		return
 	}
+	issue.Requirements = requirements
 
-	response := h.convertToIssueResponse(&issue)
+	response := convertToIssueResponse(&issue)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -226,7 +310,7 @@ func (h *IssueHandler) DeleteIssue(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.Issue{}, id).Error; err != nil {
+	if err := h.conn(c).Delete(&db.Issue{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete issue",
 			Code:  http.StatusInternalServerError,
@@ -237,6 +321,15 @@ func (h *IssueHandler) DeleteIssue(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Issue deleted successfully"})
 }
 
+// projectIssues matches the issues found on a project's tasks, and any
+// linked to its requirements; both arguments are the project ID.
+const projectIssues = `(issues.audit_task_id IN (SELECT audit_tasks.id FROM audit_tasks
+	JOIN requirements ON audit_tasks.requirement_id = requirements.id
+	WHERE requirements.project_id = ?)
+	OR issues.id IN (SELECT issue_requirements.issue_id FROM issue_requirements
+	JOIN requirements ON issue_requirements.requirement_id = requirements.id
+	WHERE requirements.project_id = ?))`
+
 func (h *IssueHandler) GetProjectIssues(c *gin.Context) {
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -247,11 +340,33 @@ func (h *IssueHandler) GetProjectIssues(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "issue")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "").Preload("Requirements").
+		Where(projectIssues, projectID, projectID)
+	if requirementID := c.Query("requirementId"); requirementID != "" {
+		query = query.Where("id IN (SELECT issue_id FROM issue_requirements WHERE requirement_id = ?)", requirementID)
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where(overdueIssue, time.Now().UTC(), closedIssueStatuses)
+	}
+	if assigneeID := c.Query("assigneeId"); assigneeID != "" {
+		query = query.Where("issues.assignee_id = ?", assigneeID)
+	}
+	if reviewerID := c.Query("reviewerId"); reviewerID != "" {
+		query = query.Where("issues.reviewer_id = ?", reviewerID)
+	}
+
 	var issues []db.Issue
-	if err := h.db.Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
-		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
-		Where("requirements.project_id = ?", projectID).
-		Find(&issues).Error; err != nil {
+	if err := query.Order("id").Find(&issues).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch project issues",
 			Code:  http.StatusInternalServerError,
@@ -261,10 +376,10 @@ func (h *IssueHandler) GetProjectIssues(c *gin.Context) {
 
 	response := make([]models.IssueResponse, len(issues))
 	for i, issue := range issues {
-		response[i] = h.convertToIssueResponse(&issue)
+		response[i] = convertToIssueResponse(&issue)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *IssueHandler) GetAuditTaskIssues(c *gin.Context) {
@@ -277,8 +392,21 @@ func (h *IssueHandler) GetAuditTaskIssues(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "issue")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var issues []db.Issue
-	if err := h.db.Where("audit_task_id = ?", auditTaskID).Find(&issues).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Preload("Requirements").
+		Where("audit_task_id = ?", auditTaskID).
+		Order("id").
+		Find(&issues).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch audit task issues",
 			Code:  http.StatusInternalServerError,
@@ -288,25 +416,137 @@ func (h *IssueHandler) GetAuditTaskIssues(c *gin.Context) {
 
 	response := make([]models.IssueResponse, len(issues))
 	for i, issue := range issues {
-		response[i] = h.convertToIssueResponse(&issue)
+		response[i] = convertToIssueResponse(&issue)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetRequirementIssues handles GET /api/v1/requirements/:id/issues
+//
+// Lists every issue that fails the requirement, whichever task it was found on.
+func (h *IssueHandler) GetRequirementIssues(c *gin.Context) {
+	requirementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid requirement ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "issue")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var issues []db.Issue
+	if err := withPreloads(h.conn(c).DB, preloads, "").Preload("Requirements").
+		Where("id IN (SELECT issue_id FROM issue_requirements WHERE requirement_id = ?)", requirementID).
+		Order("id").
+		Find(&issues).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch requirement issues",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.IssueResponse, len(issues))
+	for i, issue := range issues {
+		response[i] = convertToIssueResponse(&issue)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// issueRequirements loads the requirements an issue fails, which must belong
+// to the issue's project. It writes a 422 response otherwise.
+func (h *IssueHandler) issueRequirements(c *gin.Context, projectID uint, ids []uint) ([]*db.Requirement, bool) {
+	requirements, err := projectRequirements(h.conn(c), projectID, ids)
+	if errors.Is(err, errRequirementNotInProject) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid issue requirements",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check issue requirements",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+	return requirements, true
+}
+
+// scoreIssue rates the issue on the risk matrix of its project's client and
+// derives its priority. It writes a 422 response when the scores do not fit.
+func (h *IssueHandler) scoreIssue(c *gin.Context, projectID uint, issue *db.Issue) bool {
+	matrix := risk.Default
+	var project db.Project
+	err := h.conn(c).First(&project, projectID).Error
+	if err == nil {
+		matrix, err = projectRiskMatrix(h.conn(c), &project)
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	if err == nil {
+		err = rateIssue(issue, matrix)
+	}
+
+	if errors.Is(err, errInvalidRiskScore) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid risk score",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to score issue",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// scheduleIssue derives the issue's due date from the SLA policy of its
+// project's client, unless the date was set by hand.
+func (h *IssueHandler) scheduleIssue(c *gin.Context, projectID uint, issue *db.Issue) bool {
+	var days map[string]int
+	var project db.Project
+	err := h.conn(c).First(&project, projectID).Error
+	if err == nil {
+		days, err = projectSLAPolicy(h.conn(c), &project)
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to schedule issue",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+
+	applySLAPolicy(issue, days)
+	return true
 }
 
-// Helper function to convert db.Issue to models.IssueResponse
-func (h *IssueHandler) convertToIssueResponse(issue *db.Issue) models.IssueResponse {
-	return models.IssueResponse{
-		ID:          issue.ID,
-		AuditTaskID: issue.AuditTaskID,
-		Title:       issue.Title,
-		Description: issue.Description,
-		Priority:    issue.Priority,
-		Phase:       issue.Phase,
-		EstimateHrs: issue.EstimateHrs,
-		Status:      issue.Status,
-		Type:        issue.Type,
-		CreatedAt:   issue.CreatedAt,
-		UpdatedAt:   issue.UpdatedAt,
+// sameTime reports whether two optional times are both unset or equal.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return a.Equal(*b)
 }
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"tessellate-projects/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetaHandler serves reference data that front ends need to render forms
+type MetaHandler struct{}
+
+func NewMetaHandler() *MetaHandler {
+	return &MetaHandler{}
+}
+
+// GetEnums handles GET /api/v1/meta/enums
+func (h *MetaHandler) GetEnums(c *gin.Context) {
+	c.JSON(http.StatusOK, db.Enums())
+}
@@ -0,0 +1,617 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/oscal"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OSCALHandler imports NIST OSCAL catalogs and profiles into projects and
+// exports project results as OSCAL assessment results and POA&M documents
+type OSCALHandler struct {
+	store
+}
+
+func NewOSCALHandler(database *db.Database) *OSCALHandler {
+	return &OSCALHandler{store{db: database}}
+}
+
+// ImportOSCAL handles POST /api/v1/projects/:id/oscal/import
+//
+// The "file" form field holds a catalog or a profile, in JSON or XML. A
+// profile is resolved against the catalogs uploaded in "catalog" fields. The
+// selected controls are stored as a framework named after the document and
+// applied to the project, so importing the same document again only adds the
+// controls the project does not have yet.
+func (h *OSCALHandler) ImportOSCAL(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant); !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "No file uploaded",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+	document, err := readOSCALDocument(file)
+	if err != nil {
+		writeOSCALError(c, err)
+		return
+	}
+
+	var metadata oscal.Metadata
+	var controls []oscal.ResolvedControl
+	if document.Catalog != nil {
+		metadata = document.Catalog.Metadata
+		controls = document.Catalog.ResolvedControls()
+	} else {
+		catalogs := map[string]*oscal.Catalog{}
+		for _, header := range c.Request.MultipartForm.File["catalog"] {
+			catalog, err := openOSCALCatalog(header)
+			if err != nil {
+				writeOSCALError(c, fmt.Errorf("%s: %w", header.Filename, err))
+				return
+			}
+			catalogs[header.Filename] = catalog
+		}
+
+		metadata = document.Profile.Metadata
+		controls, err = document.Profile.Resolve(catalogs)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "Cannot resolve OSCAL profile",
+				Message: err.Error(),
+				Code:    http.StatusUnprocessableEntity,
+			})
+			return
+		}
+	}
+
+	if len(controls) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid OSCAL document",
+			Message: "the document selects no controls",
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	if err := checkInitialStatus(h.conn(c), workflow.EntityRequirement, project.ID, string(db.RequirementStatusNotTested)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+
+	resolved := make(map[string]oscal.ResolvedControl, len(controls))
+	for _, control := range controls {
+		resolved[control.ID] = control
+	}
+
+	var framework db.Framework
+	var createdRequirements []db.Requirement
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := importFramework(tx, metadata, controls, &framework); err != nil {
+			return err
+		}
+		var err error
+		createdRequirements, err = applyControls(tx, project.ID, framework.Controls, func(control *db.FrameworkControl) string {
+			return oscalRequirementText(resolved[control.ControlID])
+		})
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to import OSCAL document",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.RequirementResponse, len(createdRequirements))
+	for i, requirement := range createdRequirements {
+		response[i] = convertToRequirementResponse(&requirement)
+	}
+	framework.Controls = nil
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "OSCAL document imported successfully",
+		"framework":    convertToFrameworkResponse(&framework),
+		"count":        len(createdRequirements),
+		"requirements": response,
+	})
+}
+
+// ExportAssessmentResults handles GET /api/v1/projects/:id/oscal/assessment-results
+//
+// Every requirement becomes a finding on its control, every audit task an
+// observation and every issue a risk. The assessment plan is not modelled,
+// so the document imports a back-matter placeholder for it.
+func (h *OSCALHandler) ExportAssessmentResults(c *gin.Context) {
+	project, format, ok := h.loadExport(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	planUUID := oscal.NameUUID(fmt.Sprintf("project/%d/assessment-plan", project.ID))
+	result := oscal.Result{
+		UUID:        oscal.NameUUID(fmt.Sprintf("project/%d/result", project.ID)),
+		Title:       project.Name,
+		Description: oscal.Markup(fmt.Sprintf("Assessment of %d requirements for %s.", len(project.Requirements), project.ClientName)),
+		Props:       []oscal.Property{oscalProp("project-status", string(project.Status))},
+		Start:       project.CreatedAt.UTC(),
+	}
+	if project.Status == db.ProjectStatusCompleted || project.Status == db.ProjectStatusArchived {
+		end := project.UpdatedAt.UTC()
+		result.End = &end
+	}
+
+	// Each issue is reported once, as a risk observed on the task it was
+	// found on
+	reported := map[uint]bool{}
+	for _, requirement := range project.Requirements {
+		for _, task := range requirement.AuditTasks {
+			for _, issue := range task.Issues {
+				reported[issue.ID] = true
+			}
+		}
+	}
+
+	selection := oscal.ControlSelection{}
+	selected := map[string]bool{}
+	for _, requirement := range project.Requirements {
+		target := oscalTargetID(requirement)
+		if requirement.FrameworkControl != nil && target == requirement.FrameworkControl.ControlID && !selected[target] {
+			selected[target] = true
+			selection.IncludeControls = append(selection.IncludeControls, oscal.ControlIDRef{ControlID: target})
+		}
+
+		finding := oscal.Finding{
+			UUID:        oscal.NameUUID(fmt.Sprintf("requirement/%d", requirement.ID)),
+			Title:       oscalFindingTitle(requirement),
+			Description: oscal.Markup(requirement.Text),
+			Props: []oscal.Property{
+				oscalProp("requirement-id", strconv.FormatUint(uint64(requirement.ID), 10)),
+				oscalProp("assessment-result", string(requirement.Status)),
+			},
+			Target: oscal.FindingTarget{
+				Type:     "objective-id",
+				TargetID: target,
+				Status:   oscalObjectiveStatus(requirement),
+			},
+		}
+		if requirement.Category != nil && *requirement.Category != "" {
+			finding.Props = append(finding.Props, oscalProp("category", *requirement.Category))
+		}
+
+		for _, task := range requirement.AuditTasks {
+			observation := oscalObservation(task)
+			result.Observations = append(result.Observations, observation)
+			finding.RelatedObservations = append(finding.RelatedObservations, oscal.RelatedObservation{ObservationUUID: observation.UUID})
+			for _, issue := range task.Issues {
+				result.Risks = append(result.Risks, oscalRisk(issue, observation.UUID))
+			}
+		}
+		// An issue can fail several requirements; each of their findings
+		// refers to the issue's risk
+		for _, issue := range requirement.Issues {
+			if reported[issue.ID] {
+				finding.RelatedRisks = append(finding.RelatedRisks, oscal.RelatedRisk{RiskUUID: oscalRiskUUID(issue)})
+			}
+		}
+		result.Findings = append(result.Findings, finding)
+	}
+	if len(selection.IncludeControls) == 0 {
+		selection.IncludeAll = &struct{}{}
+	}
+	result.ReviewedControls.ControlSelections = []oscal.ControlSelection{selection}
+
+	document := &oscal.AssessmentResults{
+		UUID:     oscal.NewUUID(),
+		Metadata: oscalMetadata(project, project.Name+" Assessment Results", now),
+		ImportAP: oscal.ImportRef{Href: "#" + planUUID},
+		Results:  []oscal.Result{result},
+		BackMatter: &oscal.BackMatter{Resources: []oscal.Resource{{
+			UUID:        planUUID,
+			Title:       project.Name + " Assessment Plan",
+			Description: "The assessment plan is maintained in tessellate-projects and is not exported.",
+		}}},
+	}
+
+	writeOSCALDocument(c, "assessment-results", document, document.Validate(), format, project.ID)
+}
+
+// ExportPOAM handles GET /api/v1/projects/:id/oscal/poam
+//
+// Every issue becomes a risk with a POA&M item tracking its remediation. A
+// project without issues has nothing to report and fails validation.
+func (h *OSCALHandler) ExportPOAM(c *gin.Context) {
+	project, format, ok := h.loadExport(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	document := &oscal.PlanOfActionAndMilestones{
+		UUID:     oscal.NewUUID(),
+		Metadata: oscalMetadata(project, project.Name+" Plan of Action and Milestones", now),
+		SystemID: &oscal.SystemID{
+			IdentifierType: "https://ietf.org/rfc/rfc4122",
+			ID:             oscal.NameUUID(fmt.Sprintf("project/%d", project.ID)),
+		},
+	}
+
+	for _, requirement := range project.Requirements {
+		for _, task := range requirement.AuditTasks {
+			if len(task.Issues) == 0 {
+				continue
+			}
+			observation := oscalObservation(task)
+			document.Observations = append(document.Observations, observation)
+
+			for _, issue := range task.Issues {
+				risk := oscalRisk(issue, observation.UUID)
+				item := oscal.PoamItem{
+					UUID:                oscal.NameUUID(fmt.Sprintf("poam-item/%d", issue.ID)),
+					Title:               issue.Title,
+					Description:         risk.Description,
+					RelatedObservations: []oscal.RelatedObservation{{ObservationUUID: observation.UUID}},
+					RelatedRisks:        []oscal.RelatedRisk{{RiskUUID: risk.UUID}},
+				}
+				failed := issue.Requirements
+				if len(failed) == 0 {
+					failed = []*db.Requirement{requirement}
+				}
+				controls := map[string]bool{}
+				for _, failedRequirement := range failed {
+					if control := oscalTargetID(failedRequirement); !controls[control] {
+						controls[control] = true
+						item.Props = append(item.Props, oscalProp("control-id", control))
+					}
+				}
+				if issue.EstimateHrs != nil {
+					item.Props = append(item.Props, oscalProp("estimate-hours", strconv.Itoa(*issue.EstimateHrs)))
+				}
+
+				document.Risks = append(document.Risks, risk)
+				document.PoamItems = append(document.PoamItems, item)
+			}
+		}
+	}
+
+	writeOSCALDocument(c, "plan-of-action-and-milestones", document, document.Validate(), format, project.ID)
+}
+
+// loadExport resolves the export format and loads the project with its
+// requirements, audit tasks and issues.
+func (h *OSCALHandler) loadExport(c *gin.Context) (*db.Project, oscal.Format, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, "", false
+	}
+
+	format := oscal.FormatJSON
+	switch c.Query("format") {
+	case "":
+		if strings.Contains(c.GetHeader("Accept"), "xml") {
+			format = oscal.FormatXML
+		}
+	case string(oscal.FormatJSON):
+	case string(oscal.FormatXML):
+		format = oscal.FormatXML
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid format",
+			Message: "format must be json or xml",
+			Code:    http.StatusBadRequest,
+		})
+		return nil, "", false
+	}
+
+	var project db.Project
+	if err := h.conn(c).
+		Preload("Requirements", func(query *gorm.DB) *gorm.DB {
+			return query.Order("id")
+		}).
+		Preload("Requirements.FrameworkControl").
+		Preload("Requirements.AuditTasks").
+		Preload("Requirements.AuditTasks.Issues", func(query *gorm.DB) *gorm.DB {
+			return query.Order("id")
+		}).
+		Preload("Requirements.AuditTasks.Issues.Requirements", func(query *gorm.DB) *gorm.DB {
+			return query.Order("id")
+		}).
+		Preload("Requirements.AuditTasks.Issues.Requirements.FrameworkControl").
+		Preload("Requirements.Issues", func(query *gorm.DB) *gorm.DB {
+			return query.Order("id")
+		}).
+		First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, "", false
+	}
+
+	return &project, format, true
+}
+
+// readOSCALDocument parses and validates an uploaded catalog or profile.
+func readOSCALDocument(file multipart.File) (*oscal.Document, error) {
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	document, err := oscal.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if document.Catalog != nil {
+		return document, document.Catalog.Validate()
+	}
+	return document, document.Profile.Validate()
+}
+
+func openOSCALCatalog(header *multipart.FileHeader) (*oscal.Catalog, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	document, err := readOSCALDocument(file)
+	if err != nil {
+		return nil, err
+	}
+	if document.Catalog == nil {
+		return nil, oscal.ErrUnknownDocument
+	}
+	return document.Catalog, nil
+}
+
+// writeOSCALError reports documents that cannot be parsed as bad requests and
+// documents that parse but violate the OSCAL model as unprocessable.
+func writeOSCALError(c *gin.Context, err error) {
+	var invalid *oscal.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid OSCAL document",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error:   "Cannot read OSCAL document",
+		Message: err.Error(),
+		Code:    http.StatusBadRequest,
+	})
+}
+
+// writeOSCALDocument sends an exported document as a download once it has
+// passed validation.
+func writeOSCALDocument(c *gin.Context, root string, document interface{}, invalid error, format oscal.Format, projectID uint) {
+	if invalid != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Project cannot be exported as a valid OSCAL document",
+			Message: invalid.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	data, err := oscal.Marshal(root, document, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to export OSCAL document",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	contentType := "application/json"
+	if format == oscal.FormatXML {
+		contentType = "application/xml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=project-%d-%s.%s", projectID, root, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// importFramework finds or creates the framework named by the document's
+// title and version and upserts the controls into it. On return
+// framework.Controls holds the imported controls in document order.
+func importFramework(tx *gorm.DB, metadata oscal.Metadata, controls []oscal.ResolvedControl, framework *db.Framework) error {
+	err := tx.Where("name = ? AND version = ?", metadata.Title, metadata.Version).First(framework).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		*framework = db.Framework{Name: metadata.Title, Version: metadata.Version}
+		err = tx.Create(framework).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	framework.Controls = make([]*db.FrameworkControl, len(controls))
+	for i, resolved := range controls {
+		var control db.FrameworkControl
+		err := tx.Where("framework_id = ? AND control_id = ?", framework.ID, resolved.ID).First(&control).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		control.FrameworkID = framework.ID
+		control.ControlID = resolved.ID
+		control.Title = resolved.Title
+		control.Description = optionalString(resolved.Statement)
+		control.Category = optionalString(resolved.Group)
+		if err := tx.Save(&control).Error; err != nil {
+			return err
+		}
+		framework.Controls[i] = &control
+	}
+	return nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// oscalRequirementText renders a control as "AC-2 Account Management: ..."
+// with the statement's parameters already substituted.
+func oscalRequirementText(control oscal.ResolvedControl) string {
+	text := control.Label + " " + control.Title
+	if control.Statement != "" {
+		text += ": " + control.Statement
+	}
+	return text
+}
+
+// oscalTargetID is the control a requirement's finding targets: the control ID
+// it was created from, or a synthetic ID for requirements without one.
+func oscalTargetID(requirement *db.Requirement) string {
+	if requirement.FrameworkControl != nil && oscal.IsToken(requirement.FrameworkControl.ControlID) {
+		return requirement.FrameworkControl.ControlID
+	}
+	return fmt.Sprintf("req-%d", requirement.ID)
+}
+
+// oscalFindingTitle is the title of the requirement's control, or the first
+// line of its text.
+func oscalFindingTitle(requirement *db.Requirement) string {
+	if requirement.FrameworkControl != nil {
+		return requirement.FrameworkControl.Title
+	}
+	title, _, _ := strings.Cut(requirement.Text, "\n")
+	return title
+}
+
+// oscalObjectiveStatus maps a requirement's assessment onto the status of
+// its objective. Only met requirements satisfy it, for another reason than
+// passing when met through a compensating control; requirements that are not
+// applicable or not tested were not assessed against it.
+func oscalObjectiveStatus(requirement *db.Requirement) oscal.ObjectiveStatus {
+	status := oscal.ObjectiveStatus{State: "not-satisfied", Reason: "fail"}
+	switch requirement.Status {
+	case db.RequirementStatusMet:
+		status = oscal.ObjectiveStatus{State: "satisfied", Reason: "pass"}
+	case db.RequirementStatusCompensatingControl:
+		status = oscal.ObjectiveStatus{State: "satisfied", Reason: "other"}
+	case db.RequirementStatusNotApplicable, db.RequirementStatusNotTested:
+		status.Reason = "other"
+	}
+	if requirement.Rationale != nil {
+		status.Remarks = oscal.Markup(*requirement.Rationale)
+	}
+	return status
+}
+
+func oscalMetadata(project *db.Project, title string, now time.Time) oscal.Metadata {
+	metadata := oscal.Metadata{
+		Title:        title,
+		LastModified: now,
+		Version:      project.UpdatedAt.UTC().Format(time.RFC3339),
+		OscalVersion: oscal.Version,
+		Props:        []oscal.Property{oscalProp("project-id", strconv.FormatUint(uint64(project.ID), 10))},
+	}
+	if project.ClientName != "" {
+		metadata.Props = append(metadata.Props, oscalProp("client-name", project.ClientName))
+	}
+	return metadata
+}
+
+func oscalProp(name, value string) oscal.Property {
+	return oscal.Property{Name: name, Value: value, NS: oscal.PropertyNamespace}
+}
+
+func oscalObservation(task *db.AuditTask) oscal.Observation {
+	observation := oscal.Observation{
+		UUID:        oscal.NameUUID(fmt.Sprintf("audit-task/%d", task.ID)),
+		Title:       fmt.Sprintf("Audit task %d", task.ID),
+		Description: oscal.Markup(task.Text),
+		Props:       []oscal.Property{oscalProp("status", string(task.Status))},
+		Methods:     []string{"TEST"},
+		Collected:   task.UpdatedAt.UTC(),
+	}
+	if task.Notes != nil {
+		observation.Remarks = oscal.Markup(*task.Notes)
+	}
+	return observation
+}
+
+func oscalRisk(issue *db.Issue, observationUUID string) oscal.Risk {
+	risk := oscal.Risk{
+		UUID:                oscalRiskUUID(issue),
+		Title:               issue.Title,
+		Description:         oscal.Markup(issue.Title),
+		Statement:           oscal.Markup(issue.Title),
+		Props:               []oscal.Property{oscalProp("type", string(issue.Type))},
+		Status:              oscalRiskStatus(issue.Status),
+		RelatedObservations: []oscal.RelatedObservation{{ObservationUUID: observationUUID}},
+	}
+	if issue.Description != nil && *issue.Description != "" {
+		risk.Description = oscal.Markup(*issue.Description)
+	}
+	if issue.Priority != nil {
+		risk.Props = append(risk.Props, oscalProp("priority", string(*issue.Priority)))
+	}
+	if issue.Phase != nil {
+		risk.Props = append(risk.Props, oscalProp("phase", string(*issue.Phase)))
+	}
+	return risk
+}
+
+// oscalRiskUUID is the UUID of the risk reported for an issue.
+func oscalRiskUUID(issue *db.Issue) string {
+	return oscal.NameUUID(fmt.Sprintf("issue/%d", issue.ID))
+}
+
+// oscalRiskStatus maps issue statuses onto the OSCAL risk status values.
+func oscalRiskStatus(status db.IssueStatus) string {
+	switch status {
+	case db.IssueStatusInProgress, db.IssueStatusRemediationPlanned, db.IssueStatusRemediated:
+		return "remediating"
+	case db.IssueStatusValidated, db.IssueStatusResolved, db.IssueStatusClosed:
+		return "closed"
+	default:
+		return "open"
+	}
+}
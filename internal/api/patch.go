@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// errPatchConflict is returned when a JSON Patch cannot be applied to the
+// current document, e.g. a failed "test" operation or a missing path.
+var errPatchConflict = errors.New("patch cannot be applied")
+
+// errUnsupportedPatch is returned for PATCH bodies in any other media type.
+var errUnsupportedPatch = fmt.Errorf("use %s or %s", mergePatchContentType, jsonPatchContentType)
+
+// bindUpdate decodes the body of a PUT, or applies the body of a PATCH to
+// current, into req and validates the result. current is the resource's
+// replacement document as built from the stored entity. It writes an error
+// response and returns false on failure.
+func bindUpdate(c *gin.Context, current, req interface{}) bool {
+	if c.Request.Method != http.MethodPatch {
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return false
+		}
+		return true
+	}
+
+	if err := applyPatch(c, current, req); err != nil {
+		status := http.StatusBadRequest
+		message := "Invalid patch"
+		switch {
+		case errors.Is(err, errUnsupportedPatch):
+			status = http.StatusUnsupportedMediaType
+			message = "Unsupported patch format"
+		case errors.Is(err, errPatchConflict):
+			status = http.StatusConflict
+			message = "Patch could not be applied"
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+			Code:    status,
+		})
+		return false
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
+
+// applyPatch applies a merge patch or JSON Patch body to current and decodes
+// the patched document into req, rejecting fields the resource does not have.
+func applyPatch(c *gin.Context, current, req interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != mergePatchContentType && mediaType != jsonPatchContentType {
+		return errUnsupportedPatch
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return err
+	}
+
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return err
+	}
+
+	if mediaType == mergePatchContentType {
+		doc = mergePatch(doc, patch)
+	} else {
+		ops, ok := patch.([]interface{})
+		if !ok {
+			return errors.New("JSON Patch document must be an array of operations")
+		}
+		if doc, err = jsonPatch(doc, ops); err != nil {
+			return err
+		}
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(req)
+}
+
+// mergePatch implements RFC 7396: objects are merged recursively, a null
+// value removes the member and anything else replaces it.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = mergePatch(targetObj[key], value)
+		}
+	}
+	return targetObj
+}
+
+// jsonPatch implements the RFC 6902 operations add, remove, replace, move,
+// copy and test.
+func jsonPatch(doc interface{}, ops []interface{}) (interface{}, error) {
+	for i, raw := range ops {
+		op, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation %d is not an object", i)
+		}
+		name, _ := op["op"].(string)
+		path, ok := op["path"].(string)
+		if !ok {
+			return nil, fmt.Errorf("operation %d has no path", i)
+		}
+		value, hasValue := op["value"]
+		from, _ := op["from"].(string)
+
+		var err error
+		switch name {
+		case "add":
+			if !hasValue {
+				return nil, fmt.Errorf("operation %d: add requires a value", i)
+			}
+			doc, err = pointerAdd(doc, path, value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, path)
+		case "replace":
+			if !hasValue {
+				return nil, fmt.Errorf("operation %d: replace requires a value", i)
+			}
+			if doc, _, err = pointerRemove(doc, path); err == nil {
+				doc, err = pointerAdd(doc, path, value)
+			}
+		case "move":
+			var moved interface{}
+			if doc, moved, err = pointerRemove(doc, from); err == nil {
+				doc, err = pointerAdd(doc, path, moved)
+			}
+		case "copy":
+			var copied interface{}
+			if copied, err = pointerGet(doc, from); err == nil {
+				doc, err = pointerAdd(doc, path, deepCopy(copied))
+			}
+		case "test":
+			var actual interface{}
+			if actual, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(actual, value) {
+				err = fmt.Errorf("%w: test failed at %s", errPatchConflict, path)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// splitPointer parses an RFC 6901 JSON Pointer into its reference tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s does not exist", errPatchConflict, pointer)
+			}
+			doc = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("%w: %s does not exist", errPatchConflict, pointer)
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("%w: %s does not exist", errPatchConflict, pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd sets the value at pointer, inserting into arrays and creating
+// or replacing object members.
+func pointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			index, err = strconv.Atoi(last)
+			if err != nil || index < 0 || index > len(node) {
+				return nil, fmt.Errorf("%w: invalid array index in %s", errPatchConflict, pointer)
+			}
+		}
+		updated := append(node[:index:index], append([]interface{}{value}, node[index:]...)...)
+		return pointerReplaceContainer(doc, parentPointer, updated)
+	default:
+		return nil, fmt.Errorf("%w: %s has no container", errPatchConflict, pointer)
+	}
+}
+
+// pointerRemove deletes the value at pointer and returns it.
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	removed, err := pointerGet(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, _ := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return nil, removed, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, _ := pointerGet(doc, parentPointer)
+	last := tokens[len(tokens)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		delete(node, last)
+		return doc, removed, nil
+	case []interface{}:
+		index, _ := strconv.Atoi(last)
+		updated := append(node[:index:index], node[index+1:]...)
+		doc, err = pointerReplaceContainer(doc, parentPointer, updated)
+		return doc, removed, err
+	}
+	return nil, nil, fmt.Errorf("%w: %s has no container", errPatchConflict, pointer)
+}
+
+// pointerReplaceContainer swaps in a rebuilt array, since Go slices cannot
+// grow or shrink in place inside their parent.
+func pointerReplaceContainer(doc interface{}, pointer string, container interface{}) (interface{}, error) {
+	tokens, _ := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return container, nil
+	}
+	parent, err := pointerGet(doc, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = container
+	case []interface{}:
+		index, _ := strconv.Atoi(last)
+		node[index] = container
+	}
+	return doc, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	encoded, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(encoded, &copied)
+	return copied
+}
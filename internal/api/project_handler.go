@@ -6,29 +6,45 @@ import (
 
 	"tessellate-projects/internal/db"
 	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProjectHandler struct {
-	db *db.Database
+	store
 }
 
 func NewProjectHandler(database *db.Database) *ProjectHandler {
-	return &ProjectHandler{db: database}
+	return &ProjectHandler{store{db: database}}
 }
 
 // GetProjects handles GET /api/v1/projects
 func (h *ProjectHandler) GetProjects(c *gin.Context) {
 	var projects []db.Project
 
+	preloads, err := parseIncludes(c, "project")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	// Optional status filter
 	status := c.Query("status")
-	query := h.db.DB
+	query := withPreloads(h.conn(c).DB, preloads, "")
 
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	query, ok := filterProjectSchedule(c, query)
+	if !ok {
+		return
+	}
 
 	if err := query.Find(&projects).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -41,18 +57,10 @@ func (h *ProjectHandler) GetProjects(c *gin.Context) {
 	// Convert to response models
 	response := make([]models.ProjectResponse, len(projects))
 	for i, project := range projects {
-		response[i] = models.ProjectResponse{
-			ID:         project.ID,
-			Name:       project.Name,
-			ClientName: project.ClientName,
-			Status:     project.Status,
-			ClientID:   project.ClientID,
-			CreatedAt:  project.CreatedAt,
-			UpdatedAt:  project.UpdatedAt,
-		}
+		response[i] = convertToProjectResponse(&project)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 // GetProject handles GET /api/v1/projects/:id
@@ -66,8 +74,18 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "project", "client", "users", "requirements")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var project db.Project
-	if err := h.db.Preload("Client").Preload("Users").Preload("Requirements").First(&project, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&project, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -76,8 +94,8 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 	}
 
 	// Convert to response model
-	response := h.convertToProjectResponse(&project)
-	c.JSON(http.StatusOK, response)
+	response := convertToProjectResponse(&project)
+	renderResource(c, http.StatusOK, response)
 }
 
 // CreateProject handles POST /api/v1/projects
@@ -93,13 +111,24 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 	}
 
 	project := db.Project{
-		Name:       req.Name,
-		ClientName: req.ClientName,
-		Status:     "NEW",
-		ClientID:   req.ClientID,
+		Name:                  req.Name,
+		ClientName:            req.ClientName,
+		Status:                db.ProjectStatusNew,
+		ClientID:              req.ClientID,
+		AuditType:             (*db.AuditType)(req.AuditType),
+		PeriodStart:           utcTime(req.PeriodStart),
+		PeriodEnd:             utcTime(req.PeriodEnd),
+		PlannedFieldworkStart: utcTime(req.PlannedFieldworkStart),
+		PlannedFieldworkEnd:   utcTime(req.PlannedFieldworkEnd),
+		FieldworkStart:        utcTime(req.FieldworkStart),
+		FieldworkEnd:          utcTime(req.FieldworkEnd),
+		ReportDueDate:         utcTime(req.ReportDueDate),
+	}
+	if !validProjectDates(c, &project) {
+		return
 	}
 
-	if err := h.db.Create(&project).Error; err != nil {
+	if err := h.conn(c).Create(&project).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create project",
 			Code:  http.StatusInternalServerError,
@@ -107,11 +136,14 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToProjectResponse(&project)
+	response := convertToProjectResponse(&project)
 	c.JSON(http.StatusCreated, response)
 }
 
-// UpdateProject handles PUT /api/v1/projects/:id
+// UpdateProject handles PUT and PATCH /api/v1/projects/:id
+//
+// PUT replaces the project with the request body. PATCH applies a JSON Merge
+// Patch or JSON Patch to the current project first.
 func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -122,18 +154,8 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var project db.Project
-	if err := h.db.First(&project, id).Error; err != nil {
+	if err := h.conn(c).First(&project, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -141,21 +163,51 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Name != nil {
-		project.Name = *req.Name
+	var req models.UpdateProjectRequest
+	if !bindUpdate(c, toUpdateProjectRequest(&project), &req) {
+		return
 	}
-	if req.ClientName != nil {
-		project.ClientName = *req.ClientName
+
+	project.Name = req.Name
+	project.ClientName = req.ClientName
+	project.ClientID = req.ClientID
+	project.AuditType = (*db.AuditType)(req.AuditType)
+	project.PeriodStart = utcTime(req.PeriodStart)
+	project.PeriodEnd = utcTime(req.PeriodEnd)
+	project.PlannedFieldworkStart = utcTime(req.PlannedFieldworkStart)
+	project.PlannedFieldworkEnd = utcTime(req.PlannedFieldworkEnd)
+	project.FieldworkStart = utcTime(req.FieldworkStart)
+	project.FieldworkEnd = utcTime(req.FieldworkEnd)
+	project.ReportDueDate = utcTime(req.ReportDueDate)
+	if !validProjectDates(c, &project) {
+		return
 	}
-	if req.Status != nil {
-		project.Status = *req.Status
+
+	var user *db.User
+	from := string(project.Status)
+	if db.ProjectStatus(req.Status) != project.Status {
+		var ok bool
+		if user, ok = requireUser(c, h.conn(c)); !ok {
+			return
+		}
 	}
-	if req.ClientID != nil {
-		project.ClientID = req.ClientID
+	project.Status = db.ProjectStatus(req.Status)
+
+	// The transition is only recorded if the project saves with it
+	var transitionErr error
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if user != nil {
+			if _, transitionErr = changeStatus(&db.Database{DB: tx}, user, workflow.EntityProject, project.ID, from, req.Status, nil); transitionErr != nil {
+				return transitionErr
+			}
+		}
+		return tx.Save(&project).Error
+	})
+	if transitionErr != nil {
+		writeTransitionError(c, transitionErr)
+		return
 	}
-
-	if err := h.db.Save(&project).Error; err != nil {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update project",
 			Code:  http.StatusInternalServerError,
@@ -163,7 +215,7 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToProjectResponse(&project)
+	response := convertToProjectResponse(&project)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -178,7 +230,7 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.Project{}, id).Error; err != nil {
+	if err := h.conn(c).Delete(&db.Project{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete project",
 			Code:  http.StatusInternalServerError,
@@ -201,7 +253,7 @@ func (h *ProjectHandler) ArchiveProject(c *gin.Context) {
 	}
 
 	var project db.Project
-	if err := h.db.First(&project, id).Error; err != nil {
+	if err := h.conn(c).First(&project, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -209,8 +261,26 @@ func (h *ProjectHandler) ArchiveProject(c *gin.Context) {
 		return
 	}
 
-	project.Status = "ARCHIVED"
-	if err := h.db.Save(&project).Error; err != nil {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	// The transition is only recorded if the project saves with it
+	from := string(project.Status)
+	project.Status = db.ProjectStatusArchived
+	var transitionErr error
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if _, transitionErr = changeStatus(&db.Database{DB: tx}, user, workflow.EntityProject, project.ID, from, string(db.ProjectStatusArchived), nil); transitionErr != nil {
+			return transitionErr
+		}
+		return tx.Save(&project).Error
+	})
+	if transitionErr != nil {
+		writeTransitionError(c, transitionErr)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to archive project",
 			Code:  http.StatusInternalServerError,
@@ -218,7 +288,7 @@ func (h *ProjectHandler) ArchiveProject(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToProjectResponse(&project)
+	response := convertToProjectResponse(&project)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -233,8 +303,18 @@ func (h *ProjectHandler) GetUserProjects(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "project")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var user db.User
-	if err := h.db.Preload("Projects").First(&user, userID).Error; err != nil {
+	if err := withPreloads(h.conn(c).Preload("Projects"), preloads, "Projects").First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "User not found",
 			Code:  http.StatusNotFound,
@@ -244,10 +324,10 @@ func (h *ProjectHandler) GetUserProjects(c *gin.Context) {
 
 	response := make([]models.ProjectResponse, len(user.Projects))
 	for i, project := range user.Projects {
-		response[i] = h.convertToProjectResponse(project)
+		response[i] = convertToProjectResponse(project)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 // GetClientProjects handles GET /api/v1/clients/:id/projects
@@ -261,8 +341,18 @@ func (h *ProjectHandler) GetClientProjects(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "project")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var projects []db.Project
-	if err := h.db.Where("client_id = ?", clientID).Find(&projects).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Where("client_id = ?", clientID).Find(&projects).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch client projects",
 			Code:  http.StatusInternalServerError,
@@ -272,51 +362,8 @@ func (h *ProjectHandler) GetClientProjects(c *gin.Context) {
 
 	response := make([]models.ProjectResponse, len(projects))
 	for i, project := range projects {
-		response[i] = h.convertToProjectResponse(&project)
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-// Helper function to convert db.Project to models.ProjectResponse
-func (h *ProjectHandler) convertToProjectResponse(project *db.Project) models.ProjectResponse {
-	response := models.ProjectResponse{
-		ID:         project.ID,
-		Name:       project.Name,
-		ClientName: project.ClientName,
-		Status:     project.Status,
-		ClientID:   project.ClientID,
-		CreatedAt:  project.CreatedAt,
-		UpdatedAt:  project.UpdatedAt,
-	}
-
-	// Convert related entities if loaded
-	if project.Client != nil {
-		response.Client = &models.ClientResponse{
-			ID:           project.Client.ID,
-			Name:         project.Client.Name,
-			Industry:     project.Client.Industry,
-			ContactName:  project.Client.ContactName,
-			ContactEmail: project.Client.ContactEmail,
-			CreatedAt:    project.Client.CreatedAt,
-			UpdatedAt:    project.Client.UpdatedAt,
-		}
-	}
-
-	if len(project.Users) > 0 {
-		response.Users = make([]models.UserResponse, len(project.Users))
-		for i, user := range project.Users {
-			response.Users[i] = models.UserResponse{
-				ID:        user.ID,
-				Name:      user.Name,
-				Email:     user.Email,
-				Role:      string(user.Role),
-				ClientID:  user.ClientID,
-				CreatedAt: user.CreatedAt,
-				UpdatedAt: user.UpdatedAt,
-			}
-		}
+		response[i] = convertToProjectResponse(&project)
 	}
 
-	return response
+	renderResource(c, http.StatusOK, response)
 }
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unlockedRoutes may still be written to for a signed off project: further
+// sign-offs, archiving, rolling it forward into a new project or saving it as
+// a template, evidence checks that only record custody events, and the
+// follow-up on issues that happens after the report, which is not part of
+// the signed snapshot.
+var unlockedRoutes = map[string]bool{
+	"/api/v1/projects/:id/sign-offs":                         true,
+	"/api/v1/projects/:id/archive":                           true,
+	"/api/v1/projects/:id/roll-forward":                      true,
+	"/api/v1/projects/:id/templates":                         true,
+	"/api/v1/audit-tasks/:id/evidence/verify":                true,
+	"/api/v1/issues/:id/remediation":                         true,
+	"/api/v1/issues/:id/remediation/milestones":              true,
+	"/api/v1/issues/:id/remediation/milestones/:milestoneId": true,
+	"/api/v1/issues/:id/remediation/updates":                 true,
+	"/api/v1/issues/:id/retests":                             true,
+}
+
+// projectLock rejects writes with 423 once the project the entity named by
+// the param path parameter belongs to has been signed off.
+func projectLock(database *db.Database, entityType, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if unlockedRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		// Invalid and unknown IDs are left for the handler to report
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			c.Next()
+			return
+		}
+		conn := store{db: database}.conn(c)
+		projectID := projectIDFor(conn, entityType, uint(id))
+		if projectID == 0 {
+			c.Next()
+			return
+		}
+
+		locked, err := projectLocked(conn, projectID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to check project lock",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+		if locked {
+			c.AbortWithStatusJSON(http.StatusLocked, models.ErrorResponse{
+				Error:   "Project is locked",
+				Message: "the project has been signed off and is read-only",
+				Code:    http.StatusLocked,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// projectLocked reports whether the project has been signed off.
+func projectLocked(database *db.Database, projectID uint) (bool, error) {
+	var locked int64
+	err := database.Model(&db.Project{}).
+		Where("id = ? AND locked_at IS NOT NULL", projectID).
+		Count(&locked).Error
+	return locked > 0, err
+}
@@ -0,0 +1,773 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// templateVariable matches a {{variable}} in template text.
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// ProjectTemplateHandler serves the firm's house templates for setting up
+// projects.
+type ProjectTemplateHandler struct {
+	store
+}
+
+func NewProjectTemplateHandler(database *db.Database) *ProjectTemplateHandler {
+	return &ProjectTemplateHandler{store{db: database}}
+}
+
+// GetProjectTemplates handles GET /api/v1/project-templates
+//
+// Lists templates by name, newest version first. ?name= lists the versions
+// of one template; ?latest=true keeps only the latest version of each.
+func (h *ProjectTemplateHandler) GetProjectTemplates(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant); !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "projectTemplate")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	query := withPreloads(h.conn(c).DB, preloads, "")
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if c.Query("latest") == "true" {
+		query = query.Where(`version = (SELECT MAX(latest.version) FROM project_templates latest
+			WHERE latest.name = project_templates.name AND latest.deleted_at IS NULL)`)
+	}
+
+	var templates []db.ProjectTemplate
+	if err := query.Order("name").Order("version DESC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project templates",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.ProjectTemplateResponse, len(templates))
+	for i := range templates {
+		response[i] = convertToProjectTemplateResponse(&templates[i])
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// GetProjectTemplate handles GET /api/v1/project-templates/:id
+func (h *ProjectTemplateHandler) GetProjectTemplate(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid template ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "projectTemplate")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var template db.ProjectTemplate
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project template not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	renderResource(c, http.StatusOK, convertToProjectTemplateResponse(&template))
+}
+
+// CreateProjectTemplate handles POST /api/v1/project-templates
+//
+// Saving a template under the name of an existing one adds its next version.
+func (h *ProjectTemplateHandler) CreateProjectTemplate(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	var req models.CreateProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if !checkTemplateContent(c, h.conn(c), &req.ProjectTemplateContent) {
+		return
+	}
+
+	template := db.ProjectTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		AuditType:   (*db.AuditType)(req.AuditType),
+		ProjectName: req.ProjectName,
+		CreatedByID: user.ID,
+	}
+	h.saveTemplate(c, &template, &req.ProjectTemplateContent)
+}
+
+// SaveProjectAsTemplate handles POST /api/v1/projects/:id/templates
+//
+// Saves the project's setup as a template: its audit type, its requirements
+// and their audit tasks, its team and their roles, and its document requests.
+// Statuses, assignees, dates and evidence are not part of a template.
+// Mentions of the project's client name become {{clientName}}.
+func (h *ProjectTemplateHandler) SaveProjectAsTemplate(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.SaveProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	content, err := projectTemplateContent(h.conn(c), &project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to read project",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	template := db.ProjectTemplate{
+		Name:            req.Name,
+		Description:     req.Description,
+		AuditType:       project.AuditType,
+		ProjectName:     req.ProjectName,
+		SourceProjectID: ptr(project.ID),
+		CreatedByID:     user.ID,
+	}
+	h.saveTemplate(c, &template, content)
+}
+
+// saveTemplate stores a template as the next version of its name.
+func (h *ProjectTemplateHandler) saveTemplate(c *gin.Context, template *db.ProjectTemplate, content *models.ProjectTemplateContent) {
+	if template.ProjectName == "" {
+		template.ProjectName = "{{clientName}} " + template.Name
+	}
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to encode template",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	template.Content = string(encoded)
+
+	// Versions of deleted templates are not reused
+	if err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Unscoped().Model(&db.ProjectTemplate{}).Where("name = ?", template.Name).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		template.Version = latest + 1
+		return tx.Create(template).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create project template",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, convertToProjectTemplateResponse(template))
+}
+
+// DeleteProjectTemplate handles DELETE /api/v1/project-templates/:id
+//
+// Deletes one version. Projects set up from it keep their link to it.
+func (h *ProjectTemplateHandler) DeleteProjectTemplate(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid template ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	result := h.conn(c).Delete(&db.ProjectTemplate{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete project template",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project template not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project template deleted successfully"})
+}
+
+// InstantiateProjectTemplate handles POST /api/v1/project-templates/:id/instantiate
+//
+// Sets up a new project for the client in one transaction: the team with
+// their roles, requirements (NOT_MET) with their audit tasks (PENDING), and
+// document requests (REQUESTED) for the client. {{variables}} in the
+// template are filled in; a variable without a value is rejected with a 422.
+// The caller joins the team as a MEMBER unless the template names them.
+func (h *ProjectTemplateHandler) InstantiateProjectTemplate(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid template ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.InstantiateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var template db.ProjectTemplate
+	if err := h.conn(c).First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project template not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	var content models.ProjectTemplateContent
+	if err := json.Unmarshal([]byte(template.Content), &content); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to decode template",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	var client db.Client
+	if err := h.conn(c).First(&client, req.ClientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Client not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	project := db.Project{
+		Name:                  req.Name,
+		ClientName:            client.Name,
+		Status:                db.ProjectStatusNew,
+		ClientID:              ptr(client.ID),
+		TemplateID:            ptr(template.ID),
+		AuditType:             template.AuditType,
+		PeriodStart:           utcTime(req.PeriodStart),
+		PeriodEnd:             utcTime(req.PeriodEnd),
+		PlannedFieldworkStart: utcTime(req.PlannedFieldworkStart),
+		PlannedFieldworkEnd:   utcTime(req.PlannedFieldworkEnd),
+		ReportDueDate:         utcTime(req.ReportDueDate),
+	}
+	if req.AuditType != nil {
+		project.AuditType = (*db.AuditType)(req.AuditType)
+	}
+	if project.Name == "" {
+		project.Name = template.ProjectName
+	}
+	if !validProjectDates(c, &project) {
+		return
+	}
+
+	// Fill in the variables, then make sure none was left without a value
+	values := templateValues(&project, req.Variables)
+	var missing []string
+	seen := map[string]bool{}
+	fill := func(text string) string {
+		return templateVariable.ReplaceAllStringFunc(text, func(match string) string {
+			name := templateVariable.FindStringSubmatch(match)[1]
+			value, ok := values[name]
+			if !ok && !seen[name] {
+				seen[name] = true
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+	project.Name = fill(project.Name)
+	for i := range content.Requirements {
+		requirement := &content.Requirements[i]
+		requirement.Text = fill(requirement.Text)
+		for j := range requirement.AuditTasks {
+			task := &requirement.AuditTasks[j]
+			task.Text = fill(task.Text)
+			if task.Notes != nil {
+				task.Notes = ptr(fill(*task.Notes))
+			}
+		}
+	}
+	for i := range content.DocumentRequests {
+		content.DocumentRequests[i].Description = fill(content.DocumentRequests[i].Description)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Missing template variables",
+			Message: "no value for " + strings.Join(missing, ", "),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	// The team and framework controls may have changed since the template
+	// was saved
+	if !checkTemplateContent(c, h.conn(c), &content) {
+		return
+	}
+
+	initial := map[string]string{
+		workflow.EntityRequirement:     string(db.RequirementStatusNotTested),
+		workflow.EntityAuditTask:       string(db.AuditTaskStatusPending),
+		workflow.EntityDocumentRequest: string(db.DocumentRequestStatusRequested),
+	}
+	for entityType, status := range initial {
+		if err := checkInitialStatus(h.conn(c), entityType, 0, status); err != nil {
+			writeTransitionError(c, err)
+			return
+		}
+	}
+
+	response := models.InstantiateTemplateResponse{}
+	if err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+
+		team := map[uint]bool{}
+		for _, member := range content.Team {
+			team[member.UserID] = true
+		}
+		members := content.Team
+		if !team[user.ID] {
+			members = append(members, models.TemplateMember{UserID: user.ID, Role: string(db.ProjectRoleMember)})
+		}
+		for _, member := range members {
+			if err := tx.Create(&db.ProjectUser{ProjectID: project.ID, UserID: member.UserID, Role: db.ProjectRole(member.Role)}).Error; err != nil {
+				return err
+			}
+		}
+		response.Members = len(members)
+
+		// Parents are created before the requirements under them, and
+		// siblings keep the template's order
+		positions := make([]int, len(content.Requirements))
+		next := map[string]int{}
+		for i, item := range content.Requirements {
+			positions[i] = next[item.ParentRef]
+			next[item.ParentRef]++
+		}
+		order, _ := templateOrder(&content)
+
+		byRef := map[string]*db.Requirement{}
+		for _, i := range order {
+			item := content.Requirements[i]
+			requirement := &db.Requirement{
+				ProjectID:          project.ID,
+				Text:               item.Text,
+				Category:           item.Category,
+				Status:             db.RequirementStatusNotTested,
+				FrameworkControlID: item.FrameworkControlID,
+				Position:           positions[i],
+				Section:            item.Section,
+			}
+			if item.Ref != "" {
+				requirement.Ref = ptr(item.Ref)
+			}
+			if item.ParentRef != "" {
+				requirement.ParentID = ptr(byRef[item.ParentRef].ID)
+			}
+			if err := tx.Create(requirement).Error; err != nil {
+				return err
+			}
+			if item.Ref != "" {
+				byRef[item.Ref] = requirement
+			}
+			for _, task := range item.AuditTasks {
+				if err := tx.Create(&db.AuditTask{
+					RequirementID: requirement.ID,
+					Text:          task.Text,
+					Status:        db.AuditTaskStatusPending,
+					Notes:         task.Notes,
+				}).Error; err != nil {
+					return err
+				}
+				response.AuditTasks++
+			}
+		}
+		response.Requirements = len(content.Requirements)
+
+		for _, item := range content.DocumentRequests {
+			request := db.DocumentRequest{
+				ProjectID:     project.ID,
+				Description:   item.Description,
+				RequestedByID: user.ID,
+				Status:        db.DocumentRequestStatusRequested,
+			}
+			for _, ref := range item.RequirementRefs {
+				request.Requirements = append(request.Requirements, byRef[ref])
+			}
+			if err := tx.Omit("Requirements.*").Create(&request).Error; err != nil {
+				return err
+			}
+		}
+		response.DocumentRequests = len(content.DocumentRequests)
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to set up project",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response.Project = convertToProjectResponse(&project)
+	c.JSON(http.StatusCreated, response)
+}
+
+// templateValues are the values of the variables a template may use: the
+// client's name, the audit period and its year, and any supplied by the
+// caller, which take precedence.
+func templateValues(project *db.Project, variables map[string]string) map[string]string {
+	values := map[string]string{"clientName": project.ClientName}
+	if project.PeriodStart != nil {
+		values["periodStart"] = project.PeriodStart.Format("2006-01-02")
+	}
+	if project.PeriodEnd != nil {
+		values["periodEnd"] = project.PeriodEnd.Format("2006-01-02")
+	}
+	if project.PeriodStart != nil && project.PeriodEnd != nil {
+		values["period"] = values["periodStart"] + " to " + values["periodEnd"]
+	}
+	switch {
+	case project.PeriodEnd != nil:
+		values["year"] = strconv.Itoa(project.PeriodEnd.Year())
+	case project.PeriodStart != nil:
+		values["year"] = strconv.Itoa(project.PeriodStart.Year())
+	default:
+		values["year"] = strconv.Itoa(time.Now().Year())
+	}
+	for name, value := range variables {
+		values[name] = value
+	}
+	return values
+}
+
+// templateVariables lists the variables used in a template's text, sorted.
+func templateVariables(projectName string, content *models.ProjectTemplateContent) []string {
+	texts := []string{projectName}
+	for _, requirement := range content.Requirements {
+		texts = append(texts, requirement.Text)
+		for _, task := range requirement.AuditTasks {
+			texts = append(texts, task.Text)
+			if task.Notes != nil {
+				texts = append(texts, *task.Notes)
+			}
+		}
+	}
+	for _, request := range content.DocumentRequests {
+		texts = append(texts, request.Description)
+	}
+
+	seen := map[string]bool{}
+	variables := []string{}
+	for _, text := range texts {
+		for _, match := range templateVariable.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				variables = append(variables, match[1])
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// checkTemplateContent validates a template's content: requirement refs are
+// unique and the parent refs and refs of document requests exist without
+// nesting a requirement under itself (400), and team members are
+// firm staff and framework controls exist (422). It writes the response when
+// the content is invalid.
+func checkTemplateContent(c *gin.Context, database *db.Database, content *models.ProjectTemplateContent) bool {
+	invalid := func(status int, message string) bool {
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Invalid template",
+			Message: message,
+			Code:    status,
+		})
+		return false
+	}
+
+	refs := map[string]bool{}
+	var controlIDs []uint
+	for _, requirement := range content.Requirements {
+		if requirement.Ref != "" {
+			if refs[requirement.Ref] {
+				return invalid(http.StatusBadRequest, fmt.Sprintf("requirement ref %q is used more than once", requirement.Ref))
+			}
+			refs[requirement.Ref] = true
+		}
+		if requirement.FrameworkControlID != nil {
+			controlIDs = append(controlIDs, *requirement.FrameworkControlID)
+		}
+	}
+	for _, requirement := range content.Requirements {
+		if requirement.ParentRef != "" && !refs[requirement.ParentRef] {
+			return invalid(http.StatusBadRequest, fmt.Sprintf("requirement %q refers to unknown parent ref %q", requirement.Text, requirement.ParentRef))
+		}
+	}
+	if _, cycle := templateOrder(content); cycle >= 0 {
+		return invalid(http.StatusBadRequest, fmt.Sprintf("requirement ref %q is nested under itself", content.Requirements[cycle].Ref))
+	}
+	for _, request := range content.DocumentRequests {
+		for _, ref := range request.RequirementRefs {
+			if !refs[ref] {
+				return invalid(http.StatusBadRequest, fmt.Sprintf("document request %q refers to unknown requirement ref %q", request.Description, ref))
+			}
+		}
+	}
+
+	members := map[uint]bool{}
+	var userIDs []uint
+	for _, member := range content.Team {
+		if members[member.UserID] {
+			return invalid(http.StatusBadRequest, fmt.Sprintf("user %d is in the team more than once", member.UserID))
+		}
+		members[member.UserID] = true
+		userIDs = append(userIDs, member.UserID)
+	}
+
+	if len(userIDs) > 0 {
+		var staff int64
+		if err := database.Model(&db.User{}).Where("id IN ? AND role IN ?", userIDs, []db.Role{db.RoleAdmin, db.RoleConsultant}).
+			Count(&staff).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to check template",
+				Code:  http.StatusInternalServerError,
+			})
+			return false
+		}
+		if int(staff) != len(userIDs) {
+			return invalid(http.StatusUnprocessableEntity, "team members must be existing ADMIN or CONSULTANT users")
+		}
+	}
+
+	if len(controlIDs) > 0 {
+		var known []uint
+		if err := database.Model(&db.FrameworkControl{}).Where("id IN ?", controlIDs).Pluck("id", &known).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to check template",
+				Code:  http.StatusInternalServerError,
+			})
+			return false
+		}
+		found := map[uint]bool{}
+		for _, id := range known {
+			found[id] = true
+		}
+		for _, id := range controlIDs {
+			if !found[id] {
+				return invalid(http.StatusUnprocessableEntity, fmt.Sprintf("framework control %d does not exist", id))
+			}
+		}
+	}
+	return true
+}
+
+// projectTemplateContent reads what a template saved from the project holds.
+// Requirements keep their refs; those without one, or sharing one, get R1,
+// R2, ... in order so that their nesting and document requests can name
+// them.
+func projectTemplateContent(database *db.Database, project *db.Project) (*models.ProjectTemplateContent, error) {
+	generalize := func(text string) string {
+		if project.ClientName == "" {
+			return text
+		}
+		return strings.ReplaceAll(text, project.ClientName, "{{clientName}}")
+	}
+
+	content := &models.ProjectTemplateContent{
+		Requirements:     []models.TemplateRequirement{},
+		Team:             []models.TemplateMember{},
+		DocumentRequests: []models.TemplateDocumentRequest{},
+	}
+
+	var requirements []db.Requirement
+	if err := database.Preload("AuditTasks", func(query *gorm.DB) *gorm.DB { return query.Order("id") }).
+		Where("project_id = ?", project.ID).Order("position").Order("id").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	used := map[string]int{}
+	for _, requirement := range requirements {
+		if requirement.Ref != nil {
+			used[*requirement.Ref]++
+		}
+	}
+	refs := make(map[uint]string, len(requirements))
+	for i, requirement := range requirements {
+		if requirement.Ref != nil && *requirement.Ref != "" && used[*requirement.Ref] == 1 {
+			refs[requirement.ID] = *requirement.Ref
+			continue
+		}
+		ref := "R" + strconv.Itoa(i+1)
+		for used[ref] > 0 {
+			ref += "'"
+		}
+		used[ref]++
+		refs[requirement.ID] = ref
+	}
+	for _, requirement := range requirements {
+		item := models.TemplateRequirement{
+			Ref:                refs[requirement.ID],
+			Text:               generalize(requirement.Text),
+			Category:           requirement.Category,
+			FrameworkControlID: requirement.FrameworkControlID,
+			Section:            requirement.Section,
+			AuditTasks:         []models.TemplateAuditTask{},
+		}
+		if requirement.ParentID != nil {
+			item.ParentRef = refs[*requirement.ParentID]
+		}
+		for _, task := range requirement.AuditTasks {
+			var notes *string
+			if task.Notes != nil {
+				notes = ptr(generalize(*task.Notes))
+			}
+			item.AuditTasks = append(item.AuditTasks, models.TemplateAuditTask{Text: generalize(task.Text), Notes: notes})
+		}
+		content.Requirements = append(content.Requirements, item)
+	}
+
+	// Client users are not firm staff, so they are left out of the team
+	var members []db.ProjectUser
+	if err := database.Where("project_id = ?", project.ID).
+		Where("user_id IN (SELECT id FROM users WHERE role IN ? AND deleted_at IS NULL)", []db.Role{db.RoleAdmin, db.RoleConsultant}).
+		Order("user_id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		content.Team = append(content.Team, models.TemplateMember{UserID: member.UserID, Role: string(member.Role)})
+	}
+
+	var requests []db.DocumentRequest
+	if err := database.Preload("Requirements").Where("project_id = ?", project.ID).Order("id").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		item := models.TemplateDocumentRequest{Description: generalize(request.Description)}
+		for _, requirement := range request.Requirements {
+			if ref, ok := refs[requirement.ID]; ok {
+				item.RequirementRefs = append(item.RequirementRefs, ref)
+			}
+		}
+		content.DocumentRequests = append(content.DocumentRequests, item)
+	}
+	return content, nil
+}
+
+// templateOrder orders a template's requirements so that parents come before
+// the requirements under them, see parentFirst.
+func templateOrder(content *models.ProjectTemplateContent) ([]int, int) {
+	index := make(map[string]int, len(content.Requirements))
+	for i, requirement := range content.Requirements {
+		if requirement.Ref != "" {
+			index[requirement.Ref] = i
+		}
+	}
+	return parentFirst(len(content.Requirements), func(i int) int {
+		if parent, ok := index[content.Requirements[i].ParentRef]; ok && content.Requirements[i].ParentRef != "" {
+			return parent
+		}
+		return -1
+	})
+}
@@ -0,0 +1,590 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RemediationHandler manages the client's response to an issue: the
+// management response, the remediation plan and its progress, and the
+// consultant's retests that validate the fix. Client users of the project's
+// client may respond and report progress; retests are recorded by staff.
+type RemediationHandler struct {
+	store
+	evidence *EvidenceHandler
+}
+
+func NewRemediationHandler(database *db.Database, evidence *EvidenceHandler) *RemediationHandler {
+	return &RemediationHandler{store: store{db: database}, evidence: evidence}
+}
+
+// GetRemediation handles GET /api/v1/issues/:id/remediation
+func (h *RemediationHandler) GetRemediation(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "remediation")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+	remediation, ok := h.loadRemediation(c, issue, preloads)
+	if !ok {
+		return
+	}
+
+	response := convertToRemediationResponse(remediation)
+	renderResource(c, http.StatusOK, response)
+}
+
+// SaveRemediation handles PUT and PATCH /api/v1/issues/:id/remediation
+//
+// PUT records the management response, or replaces it; PATCH changes an
+// existing one. The owner must be a CLIENT user of the project's client.
+// Planning the remediation through the issue's workflow needs a response that
+// does not disagree, an owner and a target date.
+func (h *RemediationHandler) SaveRemediation(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+
+	remediation := db.Remediation{IssueID: issue.ID}
+	err := h.conn(c).Preload("Milestones").Where("issue_id = ?", issue.ID).First(&remediation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to save remediation",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if remediation.ID == 0 && c.Request.Method == http.MethodPatch {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Remediation not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var req models.RemediationRequest
+	if !bindUpdate(c, toRemediationRequest(&remediation), &req) {
+		return
+	}
+
+	if req.OwnerID != nil {
+		var project db.Project
+		if err := h.conn(c).First(&project, projectIDFor(h.conn(c), workflow.EntityIssue, issue.ID)).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to save remediation",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+		err := checkClientUser(h.conn(c), &project, *req.OwnerID)
+		if errors.Is(err, errNotClientUser) {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "Invalid remediation owner",
+				Message: err.Error(),
+				Code:    http.StatusUnprocessableEntity,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to save remediation",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if remediation.ID == 0 {
+		status = http.StatusCreated
+	}
+	remediation.Agreement = db.ManagementAgreement(req.Agreement)
+	remediation.ManagementResponse = req.ManagementResponse
+	remediation.ActionPlan = req.ActionPlan
+	remediation.OwnerID = req.OwnerID
+	remediation.TargetDate = utcTime(req.TargetDate)
+	remediation.RespondedByID = user.ID
+
+	if err := h.conn(c).Omit("Milestones").Save(&remediation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to save remediation",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToRemediationResponse(&remediation)
+	c.JSON(status, response)
+}
+
+// CreateRemediationMilestone handles POST /api/v1/issues/:id/remediation/milestones
+func (h *RemediationHandler) CreateRemediationMilestone(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	var req models.CreateRemediationMilestoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+	remediation, ok := h.loadRemediation(c, issue, nil)
+	if !ok {
+		return
+	}
+
+	milestone := db.RemediationMilestone{
+		RemediationID: remediation.ID,
+		Title:         req.Title,
+		DueDate:       utcTime(req.DueDate),
+	}
+	if err := h.conn(c).Create(&milestone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create milestone",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToRemediationMilestoneResponse(&milestone, time.Now())
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateRemediationMilestone handles PUT and PATCH
+// /api/v1/issues/:id/remediation/milestones/:milestoneId
+func (h *RemediationHandler) UpdateRemediationMilestone(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	milestone, ok := h.loadMilestone(c, user)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRemediationMilestoneRequest
+	if !bindUpdate(c, toUpdateRemediationMilestoneRequest(milestone), &req) {
+		return
+	}
+
+	milestone.Title = req.Title
+	milestone.DueDate = utcTime(req.DueDate)
+	if !req.Completed {
+		milestone.CompletedAt = nil
+	} else if milestone.CompletedAt == nil {
+		now := time.Now().UTC()
+		milestone.CompletedAt = &now
+	}
+
+	if err := h.conn(c).Save(milestone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to update milestone",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToRemediationMilestoneResponse(milestone, time.Now())
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteRemediationMilestone handles DELETE
+// /api/v1/issues/:id/remediation/milestones/:milestoneId
+func (h *RemediationHandler) DeleteRemediationMilestone(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	milestone, ok := h.loadMilestone(c, user)
+	if !ok {
+		return
+	}
+
+	if err := h.conn(c).Delete(milestone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete milestone",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Milestone deleted successfully"})
+}
+
+// GetRemediationUpdates handles GET /api/v1/issues/:id/remediation/updates
+func (h *RemediationHandler) GetRemediationUpdates(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "remediationUpdate")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+	remediation, ok := h.loadRemediation(c, issue, nil)
+	if !ok {
+		return
+	}
+
+	var updates []*db.RemediationUpdate
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Where("remediation_id = ?", remediation.ID).
+		Order("created_at").
+		Find(&updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch remediation updates",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.RemediationUpdateResponse, len(updates))
+	for i, update := range updates {
+		response[i] = convertToRemediationUpdateResponse(update)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateRemediationUpdate handles POST /api/v1/issues/:id/remediation/updates
+//
+// Progress updates are append-only.
+func (h *RemediationHandler) CreateRemediationUpdate(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	var req models.CreateRemediationUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+	remediation, ok := h.loadRemediation(c, issue, nil)
+	if !ok {
+		return
+	}
+
+	update := db.RemediationUpdate{
+		RemediationID:   remediation.ID,
+		AuthorID:        user.ID,
+		Note:            req.Note,
+		PercentComplete: req.PercentComplete,
+	}
+	if err := h.conn(c).Create(&update).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create remediation update",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := convertToRemediationUpdateResponse(&update)
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetRetests handles GET /api/v1/issues/:id/retests
+func (h *RemediationHandler) GetRetests(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "retest")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+
+	var retests []*db.Retest
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Where("issue_id = ?", issue.ID).
+		Order("created_at").
+		Find(&retests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch retests",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.RetestResponse, len(retests))
+	for i, retest := range retests {
+		response[i] = convertToRetestResponse(retest)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateRetest handles POST /api/v1/issues/:id/retests
+//
+// The retest results are uploaded in the "file" form field, with the form
+// fields of an evidence upload, and filed as evidence linked to the issue's
+// audit task. The result form field is PASSED or FAILED and notes is
+// optional. A retest of a REMEDIATED issue moves it on through its workflow:
+// to VALIDATED when it passes, back to REMEDIATION_PLANNED when it fails.
+// Once the project is signed off the retest is still recorded, but the audit
+// task and the issue stay as they were signed off.
+func (h *RemediationHandler) CreateRetest(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return
+	}
+	var task db.AuditTask
+	if err := h.conn(c).Preload("Requirement.Project").First(&task, issue.AuditTaskID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to record retest",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	clientID, ok := requireTaskClient(c, &task)
+	if !ok {
+		return
+	}
+
+	// The form is parsed while the file is received, within the upload limit
+	evidence, ok := h.evidence.receiveEvidence(c, user, clientID)
+	if !ok {
+		return
+	}
+
+	result := db.RetestResult(c.PostForm("result"))
+	if result != db.RetestResultPassed && result != db.RetestResultFailed {
+		h.evidence.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "result must be PASSED or FAILED",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if evidence.Source == nil {
+		evidence.Source = ptr(fmt.Sprintf("Retest of issue %d", issue.ID))
+	}
+
+	retest := db.Retest{
+		IssueID:       issue.ID,
+		Result:        result,
+		PerformedByID: user.ID,
+	}
+	if notes := strings.TrimSpace(c.PostForm("notes")); notes != "" {
+		retest.Notes = &notes
+	}
+
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(evidence).Error; err != nil {
+			return err
+		}
+		if _, err := db.AppendCustodyEvent(tx, evidence, db.CustodyActionUploaded, &user.ID, nil); err != nil {
+			return err
+		}
+		retest.EvidenceID = evidence.ID
+		if err := tx.Create(&retest).Error; err != nil {
+			return err
+		}
+
+		if task.Requirement.Project.LockedAt != nil {
+			return nil
+		}
+		if err := linkEvidence(tx, &task, evidence, user); err != nil {
+			return err
+		}
+		if issue.Status != db.IssueStatusRemediated {
+			return nil
+		}
+		to := string(db.IssueStatusValidated)
+		fields := map[string]string{}
+		if result == db.RetestResultFailed {
+			to = string(db.IssueStatusRemediationPlanned)
+			fields["reason"] = "Retest failed"
+		}
+		database := &db.Database{DB: tx}
+		if _, err := changeStatus(database, user, workflow.EntityIssue, issue.ID, string(issue.Status), to, fields); err != nil {
+			return err
+		}
+		return saveStatus(database, user, workflow.EntityIssue, issue.ID, to, fields)
+	})
+	if err != nil {
+		h.evidence.objects.Delete(c.Request.Context(), evidence.StorageKey)
+		var missing *workflow.MissingFieldsError
+		var precondition *workflow.PreconditionError
+		if errors.Is(err, workflow.ErrIllegalTransition) || errors.Is(err, workflow.ErrRoleNotAllowed) ||
+			errors.As(err, &missing) || errors.As(err, &precondition) {
+			writeTransitionError(c, err)
+			return
+		}
+		log.Printf("Failed to record retest of issue %d: %v", issue.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to record retest",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	retest.Evidence = evidence
+
+	response := convertToRetestResponse(&retest)
+	c.JSON(http.StatusCreated, response)
+}
+
+// loadIssue fetches the issue named by the :id parameter and checks that the
+// user may access its project.
+func (h *RemediationHandler) loadIssue(c *gin.Context, user *db.User) (*db.Issue, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid issue ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var issue db.Issue
+	if err := h.conn(c).First(&issue, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Issue not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireProjectAccess(c, h.conn(c), user, projectIDFor(h.conn(c), workflow.EntityIssue, issue.ID)) {
+		return nil, false
+	}
+	return &issue, true
+}
+
+// loadRemediation fetches the issue's remediation with its milestones. It
+// writes a 404 response when the client has not responded yet.
+func (h *RemediationHandler) loadRemediation(c *gin.Context, issue *db.Issue, preloads []string) (*db.Remediation, bool) {
+	var remediation db.Remediation
+	err := withPreloads(h.conn(c).DB, preloads, "").
+		Preload("Milestones").
+		Where("issue_id = ?", issue.ID).
+		First(&remediation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Remediation not found",
+			Message: "the issue has no management response yet",
+			Code:    http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch remediation",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+	return &remediation, true
+}
+
+// loadMilestone fetches the milestone named by the :milestoneId parameter,
+// which must belong to the remediation of the issue named by :id.
+func (h *RemediationHandler) loadMilestone(c *gin.Context, user *db.User) (*db.RemediationMilestone, bool) {
+	milestoneID, err := strconv.ParseUint(c.Param("milestoneId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid milestone ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	issue, ok := h.loadIssue(c, user)
+	if !ok {
+		return nil, false
+	}
+	remediation, ok := h.loadRemediation(c, issue, nil)
+	if !ok {
+		return nil, false
+	}
+
+	var milestone db.RemediationMilestone
+	if err := h.conn(c).Where("remediation_id = ?", remediation.ID).First(&milestone, milestoneID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Milestone not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	return &milestone, true
+}
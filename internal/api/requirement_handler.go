@@ -2,31 +2,46 @@ package api
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"tessellate-projects/internal/db"
 	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // RequirementHandler
 type RequirementHandler struct {
-	db *db.Database
+	store
 }
 
 func NewRequirementHandler(database *db.Database) *RequirementHandler {
-	return &RequirementHandler{db: database}
+	return &RequirementHandler{store{db: database}}
 }
 
 func (h *RequirementHandler) GetRequirements(c *gin.Context) {
 	var requirements []db.Requirement
 
+	preloads, err := parseIncludes(c, "requirement", "auditTasks")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	// Optional project filter
 	projectID := c.Query("projectId")
-	query := h.db.Preload("AuditTasks")
+	query := withPreloads(h.conn(c).DB, preloads, "")
 
 	if projectID != "" {
 		query = query.Where("project_id = ?", projectID)
@@ -42,10 +57,10 @@ func (h *RequirementHandler) GetRequirements(c *gin.Context) {
 
 	response := make([]models.RequirementResponse, len(requirements))
 	for i, requirement := range requirements {
-		response[i] = h.convertToRequirementResponse(&requirement)
+		response[i] = convertToRequirementResponse(&requirement)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *RequirementHandler) CreateRequirement(c *gin.Context) {
@@ -70,7 +85,7 @@ func (h *RequirementHandler) CreateRequirement(c *gin.Context) {
 
 	// Verify project exists
 	var project db.Project
-	if err := h.db.First(&project, projectID).Error; err != nil {
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -78,19 +93,43 @@ func (h *RequirementHandler) CreateRequirement(c *gin.Context) {
 		return
 	}
 
-	status := db.RequirementStatusNotMet
+	status := db.RequirementStatusNotTested
 	if req.Status != nil {
 		status = db.RequirementStatus(*req.Status)
 	}
+	if err := checkInitialStatus(h.conn(c), workflow.EntityRequirement, project.ID, string(status)); err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+	if !checkRationale(c, status, req.Rationale) || !checkSectionStatus(c, req.Section, status) {
+		return
+	}
+
+	if !checkParent(c, h.conn(c), project.ID, 0, req.ParentID) {
+		return
+	}
 
 	requirement := db.Requirement{
 		ProjectID: uint(projectID),
 		Text:      req.Text,
 		Category:  req.Category,
 		Status:    status,
+		Rationale: req.Rationale,
+		Ref:       req.Ref,
+		ParentID:  req.ParentID,
+		Section:   req.Section,
+	}
+	if req.Position != nil {
+		requirement.Position = *req.Position
+	} else if requirement.Position, err = nextPosition(h.conn(c).DB, project.ID, req.ParentID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create requirement",
+			Code:  http.StatusInternalServerError,
+		})
+		return
 	}
 
-	if err := h.db.Create(&requirement).Error; err != nil {
+	if err := h.conn(c).Create(&requirement).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create requirement",
 			Code:  http.StatusInternalServerError,
@@ -98,7 +137,7 @@ func (h *RequirementHandler) CreateRequirement(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToRequirementResponse(&requirement)
+	response := convertToRequirementResponse(&requirement)
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -112,8 +151,18 @@ func (h *RequirementHandler) GetRequirement(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "requirement", "auditTasks")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var requirement db.Requirement
-	if err := h.db.Preload("AuditTasks").First(&requirement, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&requirement, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Requirement not found",
 			Code:  http.StatusNotFound,
@@ -121,8 +170,8 @@ func (h *RequirementHandler) GetRequirement(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToRequirementResponse(&requirement)
-	c.JSON(http.StatusOK, response)
+	response := convertToRequirementResponse(&requirement)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *RequirementHandler) UpdateRequirement(c *gin.Context) {
@@ -135,18 +184,8 @@ func (h *RequirementHandler) UpdateRequirement(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateRequirementRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var requirement db.Requirement
-	if err := h.db.First(&requirement, id).Error; err != nil {
+	if err := h.conn(c).First(&requirement, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Requirement not found",
 			Code:  http.StatusNotFound,
@@ -154,18 +193,77 @@ func (h *RequirementHandler) UpdateRequirement(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Text != nil {
-		requirement.Text = *req.Text
+	var req models.UpdateRequirementRequest
+	if !bindUpdate(c, toUpdateRequirementRequest(&requirement), &req) {
+		return
 	}
-	if req.Category != nil {
-		requirement.Category = req.Category
+
+	if !checkRationale(c, db.RequirementStatus(req.Status), req.Rationale) ||
+		!checkSectionStatus(c, req.Section, db.RequirementStatus(req.Status)) {
+		return
 	}
-	if req.Status != nil {
-		requirement.Status = db.RequirementStatus(*req.Status)
+	var user *db.User
+	var fields map[string]string
+	from := string(requirement.Status)
+	if db.RequirementStatus(req.Status) != requirement.Status {
+		var ok bool
+		if user, ok = requireUser(c, h.conn(c)); !ok {
+			return
+		}
+		if req.Rationale != nil {
+			fields = map[string]string{"rationale": *req.Rationale}
+		}
 	}
 
-	if err := h.db.Save(&requirement).Error; err != nil {
+	moved := !sameID(req.ParentID, requirement.ParentID)
+	keepPosition := req.Position == requirement.Position
+	requirement.Text = req.Text
+	requirement.Category = req.Category
+	requirement.Status = db.RequirementStatus(req.Status)
+	requirement.Rationale = req.Rationale
+	requirement.Ref = req.Ref
+	requirement.ParentID = req.ParentID
+	requirement.Position = req.Position
+	requirement.Section = req.Section
+
+	// The new parent is checked before the transition is recorded, and the
+	// transition is only kept if the requirement saves with it
+	var parentErr, transitionErr error
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		database := &db.Database{DB: tx}
+		if moved {
+			if parentErr = checkRequirementParent(database, requirement.ProjectID, requirement.ID, req.ParentID); parentErr != nil {
+				return parentErr
+			}
+			// A requirement moved without a new position goes after its new siblings
+			if keepPosition {
+				position, err := nextPosition(tx, requirement.ProjectID, req.ParentID)
+				if err != nil {
+					return err
+				}
+				requirement.Position = position
+			}
+		}
+		// Saved first so the assessment preconditions see the updated requirement
+		if err := tx.Save(&requirement).Error; err != nil {
+			return err
+		}
+		if user != nil {
+			if _, transitionErr = changeStatus(database, user, workflow.EntityRequirement, requirement.ID, from, req.Status, fields); transitionErr != nil {
+				return transitionErr
+			}
+		}
+		return nil
+	})
+	if parentErr != nil {
+		writeParentError(c, parentErr)
+		return
+	}
+	if transitionErr != nil {
+		writeTransitionError(c, transitionErr)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update requirement",
 			Code:  http.StatusInternalServerError,
@@ -173,7 +271,7 @@ func (h *RequirementHandler) UpdateRequirement(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToRequirementResponse(&requirement)
+	response := convertToRequirementResponse(&requirement)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -187,7 +285,14 @@ func (h *RequirementHandler) DeleteRequirement(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.Requirement{}, id).Error; err != nil {
+	// Sub-requirements move up to the deleted requirement's parent
+	if err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.Requirement{}).Where("parent_id = ?", id).
+			Update("parent_id", gorm.Expr("(SELECT parent_id FROM requirements WHERE id = ?)", id)).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.Requirement{}, id).Error
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete requirement",
 			Code:  http.StatusInternalServerError,
@@ -208,8 +313,19 @@ func (h *RequirementHandler) GetProjectRequirements(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "requirement", "auditTasks")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var requirements []db.Requirement
-	if err := h.db.Where("project_id = ?", projectID).Preload("AuditTasks").Find(&requirements).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Where("project_id = ?", projectID).
+		Order("position").Order("id").Find(&requirements).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch project requirements",
 			Code:  http.StatusInternalServerError,
@@ -219,10 +335,10 @@ func (h *RequirementHandler) GetProjectRequirements(c *gin.Context) {
 
 	response := make([]models.RequirementResponse, len(requirements))
 	for i, requirement := range requirements {
-		response[i] = h.convertToRequirementResponse(&requirement)
+		response[i] = convertToRequirementResponse(&requirement)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *RequirementHandler) UploadRequirementsCSV(c *gin.Context) {
@@ -237,7 +353,7 @@ func (h *RequirementHandler) UploadRequirementsCSV(c *gin.Context) {
 
 	// Verify project exists
 	var project db.Project
-	if err := h.db.First(&project, projectID).Error; err != nil {
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -255,10 +371,23 @@ func (h *RequirementHandler) UploadRequirementsCSV(c *gin.Context) {
 	}
 	defer file.Close()
 
+	// Rows are Text,Category,ID,Parent ID,Section. The ID and parent ID
+	// nest requirements: a parent ID names another row's ID or the ref of a
+	// requirement already in the project. The first row is only skipped as
+	// a header when the caller says so with ?header=true.
+	skipHeader := c.Query("header") == "true"
+	type row struct {
+		line     int
+		record   []string
+		ref      *string
+		parentID *string
+		parent   int
+	}
 	reader := csv.NewReader(file)
-	var createdRequirements []db.Requirement
-
-	for {
+	reader.FieldsPerRecord = -1
+	var rows []row
+	refs := map[string]int{}
+	for line := 1; ; line++ {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
@@ -275,70 +404,168 @@ func (h *RequirementHandler) UploadRequirementsCSV(c *gin.Context) {
 		if len(record) == 0 {
 			continue
 		}
-
-		text := record[0]
-		var category *string
-		if len(record) > 1 && record[1] != "" {
-			category = &record[1]
+		if line == 1 && skipHeader {
+			continue
 		}
 
-		requirement := db.Requirement{
-			ProjectID: uint(projectID),
-			Text:      text,
-			Category:  category,
-			Status:    db.RequirementStatusNotMet,
+		current := row{line: line, record: record, ref: csvColumn(record, 2), parentID: csvColumn(record, 3), parent: -1}
+		if current.ref != nil {
+			if _, ok := refs[*current.ref]; ok {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "Error reading CSV",
+					Message: fmt.Sprintf("line %d: ID %q is used more than once", line, *current.ref),
+					Code:    http.StatusBadRequest,
+				})
+				return
+			}
+			refs[*current.ref] = len(rows)
 		}
+		rows = append(rows, current)
+	}
 
-		if err := h.db.Create(&requirement).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Error: "Failed to create requirement from CSV",
-				Code:  http.StatusInternalServerError,
+	// Parents named in the file are created before their children
+	existing := map[string]uint{}
+	for i := range rows {
+		if rows[i].parentID == nil {
+			continue
+		}
+		if parent, ok := refs[*rows[i].parentID]; ok {
+			rows[i].parent = parent
+			continue
+		}
+		var parent db.Requirement
+		if err := h.conn(c).Where("project_id = ? AND ref = ?", project.ID, *rows[i].parentID).
+			Order("id").First(&parent).Error; err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Error reading CSV",
+				Message: fmt.Sprintf("line %d: parent ID %q not found", rows[i].line, *rows[i].parentID),
+				Code:    http.StatusBadRequest,
 			})
 			return
 		}
-
-		createdRequirements = append(createdRequirements, requirement)
+		existing[*rows[i].parentID] = parent.ID
 	}
+	order, cycle := parentFirst(len(rows), func(i int) int { return rows[i].parent })
+	if cycle >= 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Error reading CSV",
+			Message: fmt.Sprintf("line %d: requirement is nested under itself", rows[cycle].line),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	created := make([]db.Requirement, len(rows))
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		// Siblings keep the order of the file, after any already in the project
+		type siblings struct {
+			row      int
+			parentID uint
+		}
+		positions := make([]int, len(rows))
+		next := map[siblings]int{}
+		for i := range rows {
+			key := siblings{row: rows[i].parent}
+			if key.row < 0 && rows[i].parentID != nil {
+				key.parentID = existing[*rows[i].parentID]
+			}
+			if _, ok := next[key]; !ok && key.row < 0 {
+				var parentID *uint
+				if key.parentID != 0 {
+					parentID = &key.parentID
+				}
+				position, err := nextPosition(tx, project.ID, parentID)
+				if err != nil {
+					return err
+				}
+				next[key] = position
+			}
+			positions[i] = next[key]
+			next[key]++
+		}
 
-	response := make([]models.RequirementResponse, len(createdRequirements))
-	for i, requirement := range createdRequirements {
-		response[i] = h.convertToRequirementResponse(&requirement)
+		for _, i := range order {
+			record := rows[i].record
+			requirement := db.Requirement{
+				ProjectID: uint(projectID),
+				Text:      record[0],
+				Category:  csvColumn(record, 1),
+				Status:    db.RequirementStatusNotTested,
+				Ref:       rows[i].ref,
+				Section:   csvFlag(record, 4),
+			}
+			if rows[i].parent >= 0 {
+				requirement.ParentID = ptr(created[rows[i].parent].ID)
+			} else if rows[i].parentID != nil {
+				requirement.ParentID = ptr(existing[*rows[i].parentID])
+			}
+			requirement.Position = positions[i]
+			if err := tx.Create(&requirement).Error; err != nil {
+				return err
+			}
+			created[i] = requirement
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to create requirement from CSV",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	response := make([]models.RequirementResponse, len(created))
+	for i, requirement := range created {
+		response[i] = convertToRequirementResponse(&requirement)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":      "Requirements uploaded successfully",
-		"count":        len(createdRequirements),
+		"count":        len(created),
 		"requirements": response,
 	})
 }
 
-// Helper function to convert db.Requirement to models.RequirementResponse
-func (h *RequirementHandler) convertToRequirementResponse(requirement *db.Requirement) models.RequirementResponse {
-	response := models.RequirementResponse{
-		ID:        requirement.ID,
-		ProjectID: requirement.ProjectID,
-		Text:      requirement.Text,
-		Category:  requirement.Category,
-		Status:    string(requirement.Status),
-		CreatedAt: requirement.CreatedAt,
-		UpdatedAt: requirement.UpdatedAt,
-	}
-
-	// Convert audit tasks if loaded
-	if len(requirement.AuditTasks) > 0 {
-		response.AuditTasks = make([]models.AuditTaskResponse, len(requirement.AuditTasks))
-		for i, task := range requirement.AuditTasks {
-			response.AuditTasks[i] = models.AuditTaskResponse{
-				ID:            task.ID,
-				RequirementID: task.RequirementID,
-				Text:          task.Text,
-				Status:        task.Status,
-				Notes:         task.Notes,
-				CreatedAt:     task.CreatedAt,
-				UpdatedAt:     task.UpdatedAt,
-			}
-		}
+// csvFlag reads a yes/no CSV column, which is set by "true", "yes", "y",
+// "x" or "1".
+func csvFlag(record []string, index int) bool {
+	value := csvColumn(record, index)
+	if value == nil {
+		return false
+	}
+	switch strings.ToLower(*value) {
+	case "true", "yes", "y", "x", "1":
+		return true
+	}
+	return false
+}
+
+// errRequirementNotInProject is returned when a record names a requirement
+// outside its project.
+var errRequirementNotInProject = errors.New("requirement not in project")
+
+// projectRequirements loads the requirements with the given IDs, which must
+// all belong to the project.
+func projectRequirements(database *db.Database, projectID uint, ids []uint) ([]*db.Requirement, error) {
+	requirements := []*db.Requirement{}
+	if len(ids) == 0 {
+		return requirements, nil
+	}
+	if err := database.Where("id IN ?", ids).Find(&requirements).Error; err != nil {
+		return nil, err
 	}
 
-	return response
+	found := make(map[uint]bool, len(requirements))
+	for _, requirement := range requirements {
+		if requirement.ProjectID != projectID {
+			return nil, fmt.Errorf("%w: requirement %d belongs to another project", errRequirementNotInProject, requirement.ID)
+		}
+		found[requirement.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: requirement %d not found", errRequirementNotInProject, id)
+		}
+	}
+	return requirements, nil
 }
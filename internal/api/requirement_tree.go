@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errInvalidParent is returned when a requirement is placed under one it
+// cannot nest in.
+var errInvalidParent = errors.New("invalid parent")
+
+// GetRequirementTree handles GET /api/v1/projects/:id/requirements/tree
+//
+// Returns the project's top-level requirements, each with the requirements
+// nested under it as children, in order. Every requirement carries its
+// rolled-up status, see rollUpStatus.
+func (h *RequirementHandler) GetRequirementTree(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "requirement")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	var requirements []*db.Requirement
+	if err := withPreloads(h.conn(c).DB, preloads, "").Where("project_id = ?", project.ID).
+		Order("position").Order("id").Find(&requirements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project requirements",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	// The nesting is built here rather than preloaded, whatever was included
+	byID := make(map[uint]*db.Requirement, len(requirements))
+	for _, requirement := range requirements {
+		requirement.Children = nil
+		byID[requirement.ID] = requirement
+	}
+	var roots []*db.Requirement
+	for _, requirement := range requirements {
+		if requirement.ParentID != nil && byID[*requirement.ParentID] != nil {
+			parent := byID[*requirement.ParentID]
+			parent.Children = append(parent.Children, requirement)
+		} else {
+			roots = append(roots, requirement)
+		}
+	}
+
+	response := make([]models.RequirementResponse, len(roots))
+	for i, requirement := range roots {
+		response[i] = convertToRequirementResponse(requirement)
+		rollUpStatus(&response[i])
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// rollUpStatus sets the rolled-up status of a requirement and everything
+// nested under it, and returns it: its own result combined with those rolled
+// up under it, see combineResults. Sections are not assessed themselves:
+// they take the combined result of everything under them, and are NOT_TESTED
+// while empty.
+func rollUpStatus(node *models.RequirementResponse) string {
+	var results []db.RequirementStatus
+	if !node.Section {
+		results = append(results, db.RequirementStatus(node.Status))
+	}
+	for i := range node.Children {
+		results = append(results, db.RequirementStatus(rollUpStatus(&node.Children[i])))
+	}
+
+	node.RolledUpStatus = string(db.RequirementStatusNotTested)
+	if len(results) > 0 {
+		node.RolledUpStatus = string(combineResults(results))
+	}
+	return node.RolledUpStatus
+}
+
+// checkRequirementParent returns errInvalidParent unless the requirement
+// with the given ID, or a new one when id is 0, may be placed under the
+// parent: a requirement of the same project that is not nested under it.
+func checkRequirementParent(database *db.Database, projectID, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+
+	for current, depth := parentID, 0; current != nil; depth++ {
+		if *current == id {
+			return fmt.Errorf("%w: requirement %d cannot be nested under itself", errInvalidParent, id)
+		}
+		var ancestor db.Requirement
+		err := database.First(&ancestor, *current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: requirement %d not found", errInvalidParent, *current)
+		}
+		if err != nil {
+			return err
+		}
+		if ancestor.ProjectID != projectID {
+			return fmt.Errorf("%w: requirement %d belongs to another project", errInvalidParent, ancestor.ID)
+		}
+		// Guards against nesting that is already circular
+		if depth > 1000 {
+			return fmt.Errorf("%w: requirements nested too deep", errInvalidParent)
+		}
+		current = ancestor.ParentID
+	}
+	return nil
+}
+
+// checkParent validates the parent a requirement is placed under, writing a
+// 422 response when it may not nest there.
+func checkParent(c *gin.Context, database *db.Database, projectID, id uint, parentID *uint) bool {
+	return writeParentError(c, checkRequirementParent(database, projectID, id, parentID))
+}
+
+// writeParentError writes the response for an error from
+// checkRequirementParent, reporting whether there was none.
+func writeParentError(c *gin.Context, err error) bool {
+	if errors.Is(err, errInvalidParent) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid parent",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check parent",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// nextPosition returns the position after the last requirement under the
+// parent, or at the top level when parentID is nil.
+func nextPosition(database *gorm.DB, projectID uint, parentID *uint) (int, error) {
+	query := database.Model(&db.Requirement{}).Where("project_id = ?", projectID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var position int
+	err := query.Select("COALESCE(MAX(position) + 1, 0)").Scan(&position).Error
+	return position, err
+}
+
+// parentFirst orders n items so that each comes after its parent, keeping
+// their order otherwise. parent returns the index of an item's parent, or -1
+// when its parent is not among the items. When an item's ancestry is
+// circular, it returns that item's index as cycle; otherwise cycle is -1.
+func parentFirst(n int, parent func(i int) int) (order []int, cycle int) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, n)
+	order = make([]int, 0, n)
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case done:
+			return true
+		case visiting:
+			return false
+		}
+		state[i] = visiting
+		if p := parent(i); p >= 0 && !visit(p) {
+			return false
+		}
+		state[i] = done
+		order = append(order, i)
+		return true
+	}
+
+	for i := 0; i < n; i++ {
+		if !visit(i) {
+			return nil, i
+		}
+	}
+	return order, -1
+}
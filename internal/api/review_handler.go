@@ -0,0 +1,231 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNotProjectReviewer is returned when an audit task is given a reviewer
+// who may not review it.
+var errNotProjectReviewer = errors.New("invalid reviewer")
+
+// reviewerRoles are the project roles allowed to review audit tasks.
+var reviewerRoles = []db.ProjectRole{db.ProjectRoleReviewer, db.ProjectRoleLead}
+
+// ReviewHandler serves the four-eyes review of audit tasks.
+type ReviewHandler struct {
+	store
+}
+
+func NewReviewHandler(database *db.Database) *ReviewHandler {
+	return &ReviewHandler{store{db: database}}
+}
+
+// GetReviewQueue handles GET /api/v1/users/:id/review-queue
+//
+// The queue holds the tasks the user is reviewing, and the prepared tasks
+// they may pick up: those assigned to them for review and those without a
+// reviewer on projects where they are a reviewer or lead. Users see their
+// own queue; admins see anyone's.
+func (h *ReviewHandler) GetReviewQueue(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid user ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+	if user.ID != uint(id) && user.Role != db.RoleAdmin {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "Insufficient permissions",
+			Code:  http.StatusForbidden,
+		})
+		return
+	}
+
+	preloads, err := parseIncludes(c, "auditTask")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var reviewer db.User
+	if err := h.conn(c).First(&reviewer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "User not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	var inReview, ready []db.AuditTask
+	err = withPreloads(h.conn(c).DB, preloads, "").
+		Where("status = ? AND reviewer_id = ?", db.AuditTaskStatusInReview, reviewer.ID).
+		Order(dueDateOrder).Find(&inReview).Error
+	if err == nil {
+		err = withPreloads(h.conn(c).DB, preloads, "").
+			Where("status = ?", db.AuditTaskStatusPrepared).
+			Where(`(reviewer_id = ? OR (reviewer_id IS NULL AND requirement_id IN (SELECT requirements.id FROM requirements
+				JOIN project_users ON project_users.project_id = requirements.project_id
+				WHERE project_users.user_id = ? AND project_users.role IN ?)))`, reviewer.ID, reviewer.ID, reviewerRoles).
+			Where("(prepared_by_id IS NULL OR prepared_by_id <> ?)", reviewer.ID).
+			Where("(assignee_id IS NULL OR assignee_id <> ?)", reviewer.ID).
+			Order(dueDateOrder).Find(&ready).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch review queue",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := models.ReviewQueueResponse{
+		UserID:   reviewer.ID,
+		InReview: make([]models.AuditTaskResponse, len(inReview)),
+		Ready:    make([]models.AuditTaskResponse, len(ready)),
+	}
+	for i := range inReview {
+		response.InReview[i] = convertToAuditTaskResponse(&inReview[i])
+	}
+	for i := range ready {
+		response.Ready[i] = convertToAuditTaskResponse(&ready[i])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// checkReview enforces four-eyes review on audit task transitions. Only the
+// task's reviewer may start, finish or send back its review, and the reviewer
+// must be a reviewer or lead on the project other than whoever prepared or
+// was assigned the task.
+func checkReview(database *db.Database, user *db.User, id uint, from, to string) error {
+	reviewing := db.AuditTaskStatus(to) == db.AuditTaskStatusInReview || db.AuditTaskStatus(to) == db.AuditTaskStatusReviewed
+	if !reviewing && db.AuditTaskStatus(from) != db.AuditTaskStatusInReview {
+		return nil
+	}
+
+	var task db.AuditTask
+	if err := database.First(&task, id).Error; err != nil {
+		return err
+	}
+	if task.ReviewerID != nil && *task.ReviewerID != user.ID {
+		return fmt.Errorf("%w: only the task's reviewer can review it", workflow.ErrRoleNotAllowed)
+	}
+	if !reviewing {
+		return nil
+	}
+
+	err := checkTaskReviewer(database, projectIDFor(database, workflow.EntityAuditTask, task.ID), &task, user.ID)
+	if errors.Is(err, errNotProjectReviewer) {
+		return &workflow.PreconditionError{Reason: err.Error()}
+	}
+	return err
+}
+
+// checkTaskReviewer returns errNotProjectReviewer unless the user is a
+// reviewer or lead on the project and did not prepare the task and is not
+// assigned to it.
+func checkTaskReviewer(database *db.Database, projectID uint, task *db.AuditTask, userID uint) error {
+	if sameID(task.PreparedByID, &userID) || sameID(task.AssigneeID, &userID) {
+		return fmt.Errorf("%w: user %d prepared or is assigned the task and cannot review it", errNotProjectReviewer, userID)
+	}
+
+	var membership db.ProjectUser
+	err := database.Where("project_id = ? AND user_id = ?", projectID, userID).First(&membership).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	for _, role := range reviewerRoles {
+		if membership.Role == role {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: user %d is not a REVIEWER or LEAD on the project", errNotProjectReviewer, userID)
+}
+
+// checkRequirementReviewed returns a PreconditionError unless every audit
+// task of the requirement has been reviewed.
+func checkRequirementReviewed(database *db.Database, id uint) error {
+	var pending []uint
+	if err := database.Model(&db.AuditTask{}).
+		Where("requirement_id = ? AND status <> ?", id, db.AuditTaskStatusReviewed).
+		Order("id").Pluck("id", &pending).Error; err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return &workflow.PreconditionError{Reason: fmt.Sprintf("audit tasks %v have not been reviewed", pending)}
+	}
+	return nil
+}
+
+// applyReview records who prepared and reviewed a task as it moves through
+// review. A task sent back for rework loses its sign-off.
+func applyReview(task *db.AuditTask, user *db.User, to string, fields map[string]string, now time.Time) {
+	switch db.AuditTaskStatus(to) {
+	case db.AuditTaskStatusPrepared:
+		task.PreparedByID = ptr(user.ID)
+		task.PreparedAt = &now
+		task.ReviewedByID = nil
+		task.ReviewedAt = nil
+	case db.AuditTaskStatusInReview:
+		if task.ReviewerID == nil {
+			task.ReviewerID = ptr(user.ID)
+		}
+	case db.AuditTaskStatusReviewed:
+		task.ReviewedByID = ptr(user.ID)
+		task.ReviewedAt = &now
+	default:
+		task.ReviewedByID = nil
+		task.ReviewedAt = nil
+	}
+	if notes := fields["reviewNotes"]; notes != "" {
+		task.ReviewNotes = &notes
+	}
+}
+
+// checkReviewerAssignment validates a newly assigned audit task reviewer,
+// writing a 422 response when they may not review the task.
+func checkReviewerAssignment(c *gin.Context, database *db.Database, projectID uint, task *db.AuditTask, reviewerID *uint) bool {
+	if reviewerID == nil || sameID(reviewerID, task.ReviewerID) {
+		return true
+	}
+
+	err := checkTaskReviewer(database, projectID, task, *reviewerID)
+	if errors.Is(err, errNotProjectReviewer) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid assignment",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check assignment",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,449 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/risk"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// closedIssueStatuses are left out of risk heatmaps unless asked for.
+var closedIssueStatuses = []db.IssueStatus{db.IssueStatusValidated, db.IssueStatusResolved, db.IssueStatusClosed}
+
+// errInvalidRiskScore is returned when an issue's risk scores are
+// incomplete, inconsistent or do not fit the risk matrix.
+var errInvalidRiskScore = errors.New("invalid risk score")
+
+// RiskHandler manages the clients' risk matrices and reports issues on them.
+type RiskHandler struct {
+	store
+}
+
+func NewRiskHandler(database *db.Database) *RiskHandler {
+	return &RiskHandler{store{db: database}}
+}
+
+// GetClientRiskMatrix handles GET /api/v1/clients/:id/risk-matrix
+func (h *RiskHandler) GetClientRiskMatrix(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	matrix, source, err := clientRiskMatrix(client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load risk matrix",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RiskMatrixResponse{ClientID: client.ID, Source: source, Matrix: matrix})
+}
+
+// UpdateClientRiskMatrix handles PUT /api/v1/clients/:id/risk-matrix
+//
+// Every scored issue of the client is rated again on the new matrix. A matrix
+// too small for the scores already given is rejected with 409.
+func (h *RiskHandler) UpdateClientRiskMatrix(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	var matrix risk.Matrix
+	if err := c.ShouldBindJSON(&matrix); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := matrix.Validate(db.Enums()["issuePriority"]); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid risk matrix",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	encoded, err := json.Marshal(matrix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to encode risk matrix",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	client.RiskMatrix = ptr(string(encoded))
+	if !h.saveRiskMatrix(c, client, matrix) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RiskMatrixResponse{ClientID: client.ID, Source: "client", Matrix: matrix})
+}
+
+// DeleteClientRiskMatrix handles DELETE /api/v1/clients/:id/risk-matrix,
+// reverting the client to the default matrix.
+func (h *RiskHandler) DeleteClientRiskMatrix(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	client.RiskMatrix = nil
+	if !h.saveRiskMatrix(c, client, risk.Default) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RiskMatrixResponse{ClientID: client.ID, Source: "default", Matrix: risk.Default})
+}
+
+// GetProjectRiskHeatmap handles GET /api/v1/projects/:id/risk-heatmap
+func (h *RiskHandler) GetProjectRiskHeatmap(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	matrix, err := projectRiskMatrix(h.conn(c), &project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load risk matrix",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	query := h.conn(c).Model(&db.Issue{}).
+		Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Where("requirements.project_id = ?", project.ID)
+	response, ok := h.heatmap(c, query, matrix)
+	if !ok {
+		return
+	}
+	response.ProjectID = &project.ID
+	response.ClientID = project.ClientID
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetClientRiskHeatmap handles GET /api/v1/clients/:id/risk-heatmap,
+// covering the issues of all the client's projects.
+func (h *RiskHandler) GetClientRiskHeatmap(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	matrix, _, err := clientRiskMatrix(client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load risk matrix",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	query := h.conn(c).Model(&db.Issue{}).
+		Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Joins("JOIN projects ON requirements.project_id = projects.id").
+		Where("projects.client_id = ? AND projects.deleted_at IS NULL", client.ID)
+	response, ok := h.heatmap(c, query, matrix)
+	if !ok {
+		return
+	}
+	response.ClientID = &client.ID
+
+	c.JSON(http.StatusOK, response)
+}
+
+// heatmap counts the issues selected by the query on the matrix.
+//
+// ?basis=residual (the default) places each issue at its residual score, or
+// its inherent score while it has no residual one; ?basis=inherent uses the
+// inherent score. Resolved and closed issues are left out unless
+// ?includeClosed=true.
+func (h *RiskHandler) heatmap(c *gin.Context, query *gorm.DB, matrix risk.Matrix) (*models.RiskHeatmapResponse, bool) {
+	basis := c.DefaultQuery("basis", "residual")
+	if basis != "residual" && basis != "inherent" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid basis",
+			Message: "basis must be residual or inherent",
+			Code:    http.StatusBadRequest,
+		})
+		return nil, false
+	}
+	if c.Query("includeClosed") != "true" {
+		query = query.Where("issues.status NOT IN ?", closedIssueStatuses)
+	}
+
+	var issues []db.Issue
+	if err := query.Order("issues.id").Find(&issues).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch issues",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+
+	response := &models.RiskHeatmapResponse{
+		Basis:       basis,
+		Likelihoods: matrix.Likelihoods,
+		Impacts:     matrix.Impacts,
+		Cells:       make([]models.RiskHeatmapCell, 0, len(matrix.Likelihoods)*len(matrix.Impacts)),
+		ByRating:    map[string]int{},
+	}
+	for _, rating := range db.IssuePriorities {
+		response.ByRating[string(rating)] = 0
+	}
+	for l := range matrix.Likelihoods {
+		for i := range matrix.Impacts {
+			response.Cells = append(response.Cells, models.RiskHeatmapCell{
+				Likelihood: l + 1,
+				Impact:     i + 1,
+				Rating:     matrix.Ratings[l][i],
+				IssueIDs:   []uint{},
+			})
+		}
+	}
+
+	for _, issue := range issues {
+		likelihood, impact, rating := issue.InherentLikelihood, issue.InherentImpact, issue.InherentRating
+		if basis == "residual" && issue.ResidualRating != nil {
+			likelihood, impact, rating = issue.ResidualLikelihood, issue.ResidualImpact, issue.ResidualRating
+		}
+		// Scores outside the matrix, such as those kept by signed off
+		// projects, cannot be placed
+		if likelihood == nil || impact == nil || rating == nil ||
+			*likelihood < 1 || *likelihood > len(matrix.Likelihoods) || *impact < 1 || *impact > len(matrix.Impacts) {
+			response.Unscored++
+			continue
+		}
+
+		cell := &response.Cells[(*likelihood-1)*len(matrix.Impacts)+*impact-1]
+		cell.Count++
+		cell.IssueIDs = append(cell.IssueIDs, issue.ID)
+		response.ByRating[string(*rating)]++
+		response.Scored++
+	}
+
+	return response, true
+}
+
+// saveRiskMatrix stores the client's matrix and rates its scored issues on
+// it, in one transaction. Their SLA due dates follow their new priorities.
+// Issues of signed off projects keep their ratings, but their scores must
+// still fit the matrix.
+func (h *RiskHandler) saveRiskMatrix(c *gin.Context, client *db.Client, matrix risk.Matrix) bool {
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(client).Update("risk_matrix", client.RiskMatrix).Error; err != nil {
+			return err
+		}
+
+		days, err := clientSLAPolicy(client)
+		if err != nil {
+			return err
+		}
+
+		var issues []struct {
+			db.Issue
+			Locked bool
+		}
+		if err := tx.Model(&db.Issue{}).Select("issues.*, projects.locked_at IS NOT NULL AS locked").
+			Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+			Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+			Joins("JOIN projects ON requirements.project_id = projects.id").
+			Where("projects.client_id = ? AND issues.inherent_likelihood IS NOT NULL", client.ID).
+			Find(&issues).Error; err != nil {
+			return err
+		}
+		for i := range issues {
+			issue := &issues[i].Issue
+			if err := rateIssue(issue, matrix); err != nil {
+				return fmt.Errorf("issue %d: %w", issue.ID, err)
+			}
+			if issues[i].Locked {
+				continue
+			}
+			// A new priority can change the due date the SLA policy sets
+			applySLAPolicy(issue, days)
+			if err := tx.Model(issue).Select("inherent_rating", "residual_rating", "priority", "due_date").Updates(issue).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, errInvalidRiskScore) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Risk matrix does not fit existing scores",
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to save risk matrix",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// loadClient fetches the client named by the :id parameter and checks that
+// the user may access it.
+func (h *RiskHandler) loadClient(c *gin.Context, user *db.User) (*db.Client, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid client ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var client db.Client
+	if err := h.conn(c).First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Client not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireClientAccess(c, h.conn(c), user, client.ID) {
+		return nil, false
+	}
+	return &client, true
+}
+
+// clientRiskMatrix returns the client's matrix and where it came from:
+// "client" when configured, "default" otherwise.
+func clientRiskMatrix(client *db.Client) (risk.Matrix, string, error) {
+	if client.RiskMatrix == nil {
+		return risk.Default, "default", nil
+	}
+	var matrix risk.Matrix
+	if err := json.Unmarshal([]byte(*client.RiskMatrix), &matrix); err != nil {
+		return risk.Matrix{}, "", err
+	}
+	return matrix, "client", nil
+}
+
+// projectRiskMatrix returns the matrix of the project's client, or the
+// default matrix for projects without a client.
+func projectRiskMatrix(database *db.Database, project *db.Project) (risk.Matrix, error) {
+	if project.ClientID == nil {
+		return risk.Default, nil
+	}
+	var client db.Client
+	if err := database.First(&client, *project.ClientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return risk.Default, nil
+		}
+		return risk.Matrix{}, err
+	}
+	matrix, _, err := clientRiskMatrix(&client)
+	return matrix, err
+}
+
+// rateIssue derives the issue's ratings from its scores on the matrix and
+// its priority from the ratings: the residual rating, or the inherent one
+// while no residual score is set. An issue without scores keeps the
+// priority it was given.
+func rateIssue(issue *db.Issue, matrix risk.Matrix) error {
+	inherent := issue.InherentLikelihood != nil || issue.InherentImpact != nil
+	residual := issue.ResidualLikelihood != nil || issue.ResidualImpact != nil
+
+	switch {
+	case inherent && (issue.InherentLikelihood == nil || issue.InherentImpact == nil):
+		return fmt.Errorf("%w: inherent risk needs both a likelihood and an impact", errInvalidRiskScore)
+	case residual && (issue.ResidualLikelihood == nil || issue.ResidualImpact == nil):
+		return fmt.Errorf("%w: residual risk needs both a likelihood and an impact", errInvalidRiskScore)
+	case residual && !inherent:
+		return fmt.Errorf("%w: residual risk needs an inherent risk to reduce", errInvalidRiskScore)
+	case residual && (*issue.ResidualLikelihood > *issue.InherentLikelihood || *issue.ResidualImpact > *issue.InherentImpact):
+		return fmt.Errorf("%w: residual risk cannot exceed inherent risk", errInvalidRiskScore)
+	}
+
+	issue.InherentRating = nil
+	issue.ResidualRating = nil
+	if !inherent {
+		return nil
+	}
+
+	rating, err := matrix.Rate(*issue.InherentLikelihood, *issue.InherentImpact)
+	if err != nil {
+		return fmt.Errorf("%w: inherent %v", errInvalidRiskScore, err)
+	}
+	issue.InherentRating = (*db.IssuePriority)(&rating)
+	issue.Priority = issue.InherentRating
+
+	if residual {
+		rating, err := matrix.Rate(*issue.ResidualLikelihood, *issue.ResidualImpact)
+		if err != nil {
+			return fmt.Errorf("%w: residual %v", errInvalidRiskScore, err)
+		}
+		issue.ResidualRating = (*db.IssuePriority)(&rating)
+		issue.Priority = issue.ResidualRating
+	}
+	return nil
+}
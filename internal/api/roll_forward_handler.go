@@ -0,0 +1,402 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RollForwardHandler starts the next period's engagement from a prior
+// project.
+type RollForwardHandler struct {
+	store
+}
+
+func NewRollForwardHandler(database *db.Database) *RollForwardHandler {
+	return &RollForwardHandler{store{db: database}}
+}
+
+// rollForward is a roll-forward worked out from the source project but not
+// yet saved. Clones are keyed by the ID of the entity they were cloned from.
+type rollForward struct {
+	project      db.Project
+	members      []db.ProjectUser
+	workflows    []db.Workflow
+	requirements []*db.Requirement
+	tasks        []*db.AuditTask
+	issues       []*db.Issue
+	requirement  map[uint]*db.Requirement
+	task         map[uint]*db.AuditTask
+	response     models.RollForwardResponse
+}
+
+// RollForwardProject handles POST /api/v1/projects/:id/roll-forward
+//
+// The project is cloned into a new one for the next audit period, linked back
+// to it: its audit type, members and their roles, its workflow overrides, and
+// its requirements, nested as before, and audit tasks with their text,
+// categories and notes. The audit period moves on by a year.
+// Requirements start NOT_MET and tasks PENDING, without assignees, due dates,
+// evidence or review sign-offs. With carryForwardIssues, open issues are
+// carried forward as prior-period findings, reopened on the cloned tasks. With
+// ?preview=true nothing is saved and the response shows what would be
+// created.
+func (h *RollForwardHandler) RollForwardProject(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	// The body is optional
+	var req models.RollForwardRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+	preview := c.Query("preview") == "true"
+
+	var source db.Project
+	if err := h.conn(c).First(&source, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, source.ID) {
+		return
+	}
+
+	// The clones follow the source project's workflows, which are copied
+	initial := map[string]string{
+		workflow.EntityRequirement: string(db.RequirementStatusNotTested),
+		workflow.EntityAuditTask:   string(db.AuditTaskStatusPending),
+	}
+	if req.CarryForwardIssues {
+		initial[workflow.EntityIssue] = string(db.IssueStatusOpen)
+	}
+	for entityType, status := range initial {
+		if err := checkInitialStatus(h.conn(c), entityType, source.ID, status); err != nil {
+			writeTransitionError(c, err)
+			return
+		}
+	}
+
+	plan, err := planRollForward(h.conn(c), &source, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to plan roll-forward",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if preview {
+		plan.response.Preview = true
+		c.JSON(http.StatusOK, plan.response)
+		return
+	}
+
+	if err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		return plan.save(tx)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to roll forward project",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	project := convertToProjectResponse(&plan.project)
+	plan.response.Project = &project
+	for i, requirement := range plan.requirements {
+		plan.response.Requirements[i].ID = ptr(requirement.ID)
+	}
+	for i, task := range plan.tasks {
+		plan.response.AuditTasks[i].ID = ptr(task.ID)
+	}
+	for i, issue := range plan.issues {
+		plan.response.Issues[i].ID = ptr(issue.ID)
+	}
+
+	c.JSON(http.StatusCreated, plan.response)
+}
+
+// planRollForward reads the source project and works out its clones.
+func planRollForward(database *db.Database, source *db.Project, req models.RollForwardRequest) (*rollForward, error) {
+	name := req.Name
+	if name == "" {
+		name = source.Name + " (rolled forward)"
+	}
+
+	plan := &rollForward{
+		project: db.Project{
+			Name:         name,
+			ClientName:   source.ClientName,
+			Status:       db.ProjectStatusNew,
+			ClientID:     source.ClientID,
+			RolledFromID: ptr(source.ID),
+			AuditType:    source.AuditType,
+			PeriodStart:  nextPeriod(source.PeriodStart),
+			PeriodEnd:    nextPeriod(source.PeriodEnd),
+		},
+		requirement: map[uint]*db.Requirement{},
+		task:        map[uint]*db.AuditTask{},
+		response: models.RollForwardResponse{
+			SourceProjectID: source.ID,
+			Name:            name,
+			PeriodStart:     nextPeriod(source.PeriodStart),
+			PeriodEnd:       nextPeriod(source.PeriodEnd),
+			Members:         []models.UserResponse{},
+			Workflows:       []string{},
+			Requirements:    []models.RollForwardItem{},
+			AuditTasks:      []models.RollForwardItem{},
+			Issues:          []models.RollForwardItem{},
+		},
+	}
+
+	var members []db.User
+	if err := database.Where("id IN (SELECT user_id FROM project_users WHERE project_id = ?)", source.ID).
+		Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if err := database.Where("project_id = ?", source.ID).Find(&plan.members).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[uint]db.ProjectRole, len(plan.members))
+	for _, membership := range plan.members {
+		roles[membership.UserID] = membership.Role
+	}
+	for i := range members {
+		response := convertToUserResponse(&members[i])
+		response.ProjectRole = string(roles[members[i].ID])
+		plan.response.Members = append(plan.response.Members, response)
+	}
+
+	if err := database.Where("project_id = ?", source.ID).Order("entity_type").Find(&plan.workflows).Error; err != nil {
+		return nil, err
+	}
+	for _, stored := range plan.workflows {
+		plan.response.Workflows = append(plan.response.Workflows, stored.EntityType)
+	}
+
+	var requirements []db.Requirement
+	if err := database.Preload("AuditTasks", func(query *gorm.DB) *gorm.DB { return query.Order("id") }).
+		Where("project_id = ?", source.ID).Order("id").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	// Parents are cloned before the requirements under them
+	index := make(map[uint]int, len(requirements))
+	for i, requirement := range requirements {
+		index[requirement.ID] = i
+	}
+	order, cycle := parentFirst(len(requirements), func(i int) int {
+		if parentID := requirements[i].ParentID; parentID != nil {
+			if parent, ok := index[*parentID]; ok {
+				return parent
+			}
+		}
+		return -1
+	})
+	if cycle >= 0 {
+		return nil, fmt.Errorf("%w: requirement %d is nested under itself", errInvalidParent, requirements[cycle].ID)
+	}
+
+	for _, i := range order {
+		requirement := requirements[i]
+		clone := &db.Requirement{
+			Text:               requirement.Text,
+			Category:           requirement.Category,
+			Status:             db.RequirementStatusNotTested,
+			FrameworkControlID: requirement.FrameworkControlID,
+			Ref:                requirement.Ref,
+			Position:           requirement.Position,
+			Section:            requirement.Section,
+		}
+		if requirement.ParentID != nil {
+			clone.Parent = plan.requirement[*requirement.ParentID]
+		}
+		plan.requirements = append(plan.requirements, clone)
+		plan.requirement[requirement.ID] = clone
+		plan.response.Requirements = append(plan.response.Requirements, models.RollForwardItem{
+			SourceID:   requirement.ID,
+			Text:       requirement.Text,
+			FromStatus: string(requirement.Status),
+			ToStatus:   string(clone.Status),
+		})
+
+		for _, task := range requirement.AuditTasks {
+			taskClone := &db.AuditTask{
+				Requirement: clone,
+				Text:        task.Text,
+				Status:      db.AuditTaskStatusPending,
+				Notes:       task.Notes,
+			}
+			plan.tasks = append(plan.tasks, taskClone)
+			plan.task[task.ID] = taskClone
+			plan.response.AuditTasks = append(plan.response.AuditTasks, models.RollForwardItem{
+				SourceID:   task.ID,
+				Text:       task.Text,
+				FromStatus: string(task.Status),
+				ToStatus:   string(taskClone.Status),
+				Cleared:    clearedTaskFields(task),
+			})
+		}
+	}
+
+	if !req.CarryForwardIssues {
+		return plan, nil
+	}
+
+	var issues []db.Issue
+	if err := database.Preload("Requirements").
+		Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Where("requirements.project_id = ? AND audit_tasks.deleted_at IS NULL AND requirements.deleted_at IS NULL", source.ID).
+		Where("issues.status NOT IN ?", closedIssueStatuses).
+		Order("issues.id").Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		task, ok := plan.task[issue.AuditTaskID]
+		if !ok {
+			continue
+		}
+		clone := &db.Issue{
+			AuditTask:            task,
+			Title:                issue.Title,
+			Description:          issue.Description,
+			Priority:             issue.Priority,
+			Phase:                issue.Phase,
+			EstimateHrs:          issue.EstimateHrs,
+			Status:               db.IssueStatusOpen,
+			Type:                 issue.Type,
+			DueDate:              issue.DueDate,
+			DueDateFromSLA:       issue.DueDateFromSLA,
+			InherentLikelihood:   issue.InherentLikelihood,
+			InherentImpact:       issue.InherentImpact,
+			InherentRating:       issue.InherentRating,
+			ResidualLikelihood:   issue.ResidualLikelihood,
+			ResidualImpact:       issue.ResidualImpact,
+			ResidualRating:       issue.ResidualRating,
+			CompensatingControls: issue.CompensatingControls,
+			CarriedFromID:        ptr(issue.ID),
+		}
+		for _, requirement := range issue.Requirements {
+			if cloned, ok := plan.requirement[requirement.ID]; ok {
+				clone.Requirements = append(clone.Requirements, cloned)
+			}
+		}
+		plan.issues = append(plan.issues, clone)
+
+		var cleared []string
+		if issue.AssigneeID != nil {
+			cleared = append(cleared, "assigneeId")
+		}
+		if issue.ReviewerID != nil {
+			cleared = append(cleared, "reviewerId")
+		}
+		plan.response.Issues = append(plan.response.Issues, models.RollForwardItem{
+			SourceID:   issue.ID,
+			Text:       issue.Title,
+			FromStatus: string(issue.Status),
+			ToStatus:   string(clone.Status),
+			Cleared:    cleared,
+		})
+	}
+
+	return plan, nil
+}
+
+// nextPeriod moves an audit period date on by a year. Fieldwork and report
+// dates are planned afresh.
+func nextPeriod(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return ptr(t.AddDate(1, 0, 0))
+}
+
+// clearedTaskFields lists the fields of an audit task that its clone does
+// not keep.
+func clearedTaskFields(task *db.AuditTask) []string {
+	var cleared []string
+	if task.DueDate != nil {
+		cleared = append(cleared, "dueDate")
+	}
+	if task.AssigneeID != nil {
+		cleared = append(cleared, "assigneeId")
+	}
+	if task.ReviewerID != nil {
+		cleared = append(cleared, "reviewerId")
+	}
+	if task.PreparedByID != nil || task.ReviewedByID != nil || task.ReviewNotes != nil {
+		cleared = append(cleared, "review")
+	}
+	return cleared
+}
+
+// save creates the new project and its clones. The evidence of the source
+// tasks is not linked to the clones, since evidence is collected again for
+// the new period.
+func (plan *rollForward) save(tx *gorm.DB) error {
+	if err := tx.Create(&plan.project).Error; err != nil {
+		return err
+	}
+
+	for _, member := range plan.members {
+		if err := tx.Create(&db.ProjectUser{ProjectID: plan.project.ID, UserID: member.UserID, Role: member.Role}).Error; err != nil {
+			return err
+		}
+	}
+	for _, stored := range plan.workflows {
+		if err := tx.Create(&db.Workflow{ProjectID: ptr(plan.project.ID), EntityType: stored.EntityType, Definition: stored.Definition}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, requirement := range plan.requirements {
+		requirement.ProjectID = plan.project.ID
+		if requirement.Parent != nil {
+			requirement.ParentID = ptr(requirement.Parent.ID)
+		}
+		if err := tx.Omit("Parent").Create(requirement).Error; err != nil {
+			return err
+		}
+	}
+	for _, task := range plan.tasks {
+		task.RequirementID = task.Requirement.ID
+		if err := tx.Omit("Requirement").Create(task).Error; err != nil {
+			return err
+		}
+	}
+	for _, issue := range plan.issues {
+		issue.AuditTaskID = issue.AuditTask.ID
+		if err := tx.Omit("AuditTask", "Requirements.*").Create(issue).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"tessellate-projects/internal/attest"
 	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/storage"
+	"tessellate-projects/internal/workflow"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine, database *db.Database) {
+func SetupRoutes(router *gin.Engine, database *db.Database, objects storage.Storage, signer *attest.Signer) {
+	registerValidators()
+
 	// Create handlers
 	projectHandler := NewProjectHandler(database)
 	userHandler := NewUserHandler(database)
@@ -15,27 +20,72 @@ func SetupRoutes(router *gin.Engine, database *db.Database) {
 	requirementHandler := NewRequirementHandler(database)
 	auditTaskHandler := NewAuditTaskHandler(database)
 	issueHandler := NewIssueHandler(database)
+	riskHandler := NewRiskHandler(database)
+	slaHandler := NewSLAHandler(database)
+	workloadHandler := NewWorkloadHandler(database)
+	reviewHandler := NewReviewHandler(database)
+	signOffHandler := NewSignOffHandler(database, signer)
+	rollForwardHandler := NewRollForwardHandler(database)
+	timelineHandler := NewTimelineHandler(database)
+	projectTemplateHandler := NewProjectTemplateHandler(database)
+	metaHandler := NewMetaHandler()
+	workflowHandler := NewWorkflowHandler(database)
+	frameworkHandler := NewFrameworkHandler(database)
+	controlMappingHandler := NewControlMappingHandler(database)
+	oscalHandler := NewOSCALHandler(database)
+	evidenceHandler := NewEvidenceHandler(database, objects)
+	documentRequestHandler := NewDocumentRequestHandler(database, evidenceHandler)
+	remediationHandler := NewRemediationHandler(database, evidenceHandler)
+	batchHandler := NewBatchHandler(database, router)
 
 	// API v1 group
 	v1 := router.Group("/api/v1")
+	// Evidence uploads are the largest requests; leave room for their multipart framing
+	v1.Use(idempotency(database, idempotencyTTL(), evidenceMaxBytes()+1<<20))
 	{
-		// Projects
-		projects := v1.Group("/projects")
+		// Projects. Signed off projects are read-only, see projectLock
+		projects := v1.Group("/projects", projectLock(database, workflow.EntityProject, "id"))
 		{
 			projects.GET("", projectHandler.GetProjects)
 			projects.POST("", projectHandler.CreateProject)
 			projects.GET("/:id", projectHandler.GetProject)
 			projects.PUT("/:id", projectHandler.UpdateProject)
+			projects.PATCH("/:id", projectHandler.UpdateProject)
 			projects.DELETE("/:id", projectHandler.DeleteProject)
 			projects.POST("/:id/archive", projectHandler.ArchiveProject)
+			projects.POST("/:id/roll-forward", rollForwardHandler.RollForwardProject)
+			projects.POST("/:id/templates", projectTemplateHandler.SaveProjectAsTemplate)
+			projects.GET("/:id/transitions", workflowHandler.GetProjectTransitions)
+			projects.POST("/:id/transitions", workflowHandler.TransitionProject)
+			projects.GET("/:id/workflows/:entityType", workflowHandler.GetWorkflow)
+			projects.PUT("/:id/workflows/:entityType", workflowHandler.UpdateWorkflow)
+			projects.DELETE("/:id/workflows/:entityType", workflowHandler.DeleteWorkflow)
 
 			// Project relationships
 			projects.GET("/:id/requirements", requirementHandler.GetProjectRequirements)
 			projects.POST("/:id/requirements", requirementHandler.CreateRequirement)
+			projects.GET("/:id/requirements/tree", requirementHandler.GetRequirementTree)
+			projects.GET("/:id/compliance", requirementHandler.GetProjectCompliance)
 			projects.GET("/:id/users", userHandler.GetProjectUsers)
 			projects.POST("/:id/users/:userId", userHandler.AssignUserToProject)
 			projects.DELETE("/:id/users/:userId", userHandler.RemoveUserFromProject)
 			projects.GET("/:id/issues", issueHandler.GetProjectIssues)
+			projects.GET("/:id/risk-heatmap", riskHandler.GetProjectRiskHeatmap)
+			projects.GET("/:id/sla-compliance", slaHandler.GetProjectSLACompliance)
+			projects.GET("/:id/workload", workloadHandler.GetProjectWorkload)
+			projects.GET("/:id/timeline", timelineHandler.GetProjectTimeline)
+			projects.GET("/:id/frameworks", frameworkHandler.GetProjectFrameworks)
+			projects.POST("/:id/frameworks", frameworkHandler.ApplyFramework)
+			projects.GET("/:id/frameworks/:frameworkId/coverage", controlMappingHandler.GetProjectFrameworkCoverage)
+			projects.POST("/:id/oscal/import", oscalHandler.ImportOSCAL)
+			projects.GET("/:id/document-requests", documentRequestHandler.GetProjectDocumentRequests)
+			projects.POST("/:id/document-requests", documentRequestHandler.CreateDocumentRequest)
+			projects.GET("/:id/document-requests/tracker", documentRequestHandler.GetDocumentRequestTracker)
+			projects.GET("/:id/oscal/assessment-results", oscalHandler.ExportAssessmentResults)
+			projects.GET("/:id/oscal/poam", oscalHandler.ExportPOAM)
+			projects.GET("/:id/sign-offs", signOffHandler.GetSignOffs)
+			projects.POST("/:id/sign-offs", signOffHandler.CreateSignOff)
+			projects.GET("/:id/sign-offs/verify", signOffHandler.VerifySignOffs)
 		}
 
 		// Users
@@ -45,8 +95,11 @@ func SetupRoutes(router *gin.Engine, database *db.Database) {
 			users.POST("", userHandler.CreateUser)
 			users.GET("/:id", userHandler.GetUser)
 			users.PUT("/:id", userHandler.UpdateUser)
+			users.PATCH("/:id", userHandler.UpdateUser)
 			users.DELETE("/:id", userHandler.DeleteUser)
 			users.GET("/:id/projects", projectHandler.GetUserProjects)
+			users.GET("/:id/work", workloadHandler.GetUserWork)
+			users.GET("/:id/review-queue", reviewHandler.GetReviewQueue)
 		}
 
 		// Clients
@@ -56,46 +109,149 @@ func SetupRoutes(router *gin.Engine, database *db.Database) {
 			clients.POST("", clientHandler.CreateClient)
 			clients.GET("/:id", clientHandler.GetClient)
 			clients.PUT("/:id", clientHandler.UpdateClient)
+			clients.PATCH("/:id", clientHandler.UpdateClient)
 			clients.DELETE("/:id", clientHandler.DeleteClient)
 			clients.GET("/:id/users", userHandler.GetClientUsers)
 			clients.GET("/:id/projects", projectHandler.GetClientProjects)
+			clients.GET("/:id/evidence", evidenceHandler.GetClientEvidence)
+			clients.POST("/:id/evidence", evidenceHandler.UploadClientEvidence)
+			clients.GET("/:id/risk-matrix", riskHandler.GetClientRiskMatrix)
+			clients.PUT("/:id/risk-matrix", riskHandler.UpdateClientRiskMatrix)
+			clients.DELETE("/:id/risk-matrix", riskHandler.DeleteClientRiskMatrix)
+			clients.GET("/:id/risk-heatmap", riskHandler.GetClientRiskHeatmap)
+			clients.GET("/:id/sla-policy", slaHandler.GetClientSLAPolicy)
+			clients.PUT("/:id/sla-policy", slaHandler.UpdateClientSLAPolicy)
+			clients.DELETE("/:id/sla-policy", slaHandler.DeleteClientSLAPolicy)
 		}
 
 		// Requirements
-		requirements := v1.Group("/requirements")
+		requirements := v1.Group("/requirements", projectLock(database, workflow.EntityRequirement, "id"))
 		{
 			requirements.GET("", requirementHandler.GetRequirements)
 			requirements.GET("/:id", requirementHandler.GetRequirement)
 			requirements.PUT("/:id", requirementHandler.UpdateRequirement)
+			requirements.PATCH("/:id", requirementHandler.UpdateRequirement)
 			requirements.DELETE("/:id", requirementHandler.DeleteRequirement)
+			requirements.GET("/:id/transitions", workflowHandler.GetRequirementTransitions)
+			requirements.POST("/:id/transitions", workflowHandler.TransitionRequirement)
 			requirements.GET("/:id/audit-tasks", auditTaskHandler.GetRequirementAuditTasks)
 			requirements.POST("/:id/audit-tasks", auditTaskHandler.CreateAuditTask)
+			requirements.GET("/:id/issues", issueHandler.GetRequirementIssues)
 		}
 
 		// Audit Tasks
-		auditTasks := v1.Group("/audit-tasks")
+		auditTasks := v1.Group("/audit-tasks", projectLock(database, workflow.EntityAuditTask, "id"))
 		{
 			auditTasks.GET("", auditTaskHandler.GetAuditTasks)
 			auditTasks.GET("/:id", auditTaskHandler.GetAuditTask)
 			auditTasks.PUT("/:id", auditTaskHandler.UpdateAuditTask)
+			auditTasks.PATCH("/:id", auditTaskHandler.UpdateAuditTask)
 			auditTasks.DELETE("/:id", auditTaskHandler.DeleteAuditTask)
+			auditTasks.GET("/:id/transitions", workflowHandler.GetAuditTaskTransitions)
+			auditTasks.POST("/:id/transitions", workflowHandler.TransitionAuditTask)
 			auditTasks.GET("/:id/issues", issueHandler.GetAuditTaskIssues)
 			auditTasks.POST("/:id/issues", issueHandler.CreateIssue)
+			auditTasks.GET("/:id/evidence", evidenceHandler.GetAuditTaskEvidence)
+			auditTasks.POST("/:id/evidence", evidenceHandler.UploadEvidence)
+			auditTasks.POST("/:id/evidence/verify", evidenceHandler.VerifyAuditTaskEvidence)
+			auditTasks.POST("/:id/evidence/:evidenceId", evidenceHandler.LinkEvidence)
+			auditTasks.DELETE("/:id/evidence/:evidenceId", evidenceHandler.UnlinkEvidence)
+		}
+
+		// Evidence
+		evidence := v1.Group("/evidence")
+		{
+			evidence.GET("/:id", evidenceHandler.GetEvidence)
+			evidence.PUT("/:id", evidenceHandler.UpdateEvidence)
+			evidence.PATCH("/:id", evidenceHandler.UpdateEvidence)
+			evidence.GET("/:id/download", evidenceHandler.DownloadEvidence)
+			evidence.GET("/:id/versions", evidenceHandler.GetEvidenceVersions)
+			evidence.POST("/:id/versions", evidenceHandler.UploadEvidenceVersion)
+			evidence.GET("/:id/custody", evidenceHandler.GetEvidenceCustody)
+			evidence.POST("/:id/verify", evidenceHandler.VerifyEvidence)
+		}
+
+		// Document requests
+		documentRequests := v1.Group("/document-requests", projectLock(database, workflow.EntityDocumentRequest, "id"))
+		{
+			documentRequests.GET("/:id", documentRequestHandler.GetDocumentRequest)
+			documentRequests.PUT("/:id", documentRequestHandler.UpdateDocumentRequest)
+			documentRequests.PATCH("/:id", documentRequestHandler.UpdateDocumentRequest)
+			documentRequests.DELETE("/:id", documentRequestHandler.DeleteDocumentRequest)
+			documentRequests.GET("/:id/responses", documentRequestHandler.GetDocumentResponses)
+			documentRequests.POST("/:id/responses", documentRequestHandler.UploadDocumentResponse)
+			documentRequests.GET("/:id/transitions", workflowHandler.GetDocumentRequestTransitions)
+			documentRequests.POST("/:id/transitions", workflowHandler.TransitionDocumentRequest)
 		}
 
 		// Issues
-		issues := v1.Group("/issues")
+		issues := v1.Group("/issues", projectLock(database, workflow.EntityIssue, "id"))
 		{
 			issues.GET("", issueHandler.GetIssues)
 			issues.GET("/:id", issueHandler.GetIssue)
 			issues.PUT("/:id", issueHandler.UpdateIssue)
+			issues.PATCH("/:id", issueHandler.UpdateIssue)
 			issues.DELETE("/:id", issueHandler.DeleteIssue)
+			issues.GET("/:id/transitions", workflowHandler.GetIssueTransitions)
+			issues.POST("/:id/transitions", workflowHandler.TransitionIssue)
+			issues.GET("/:id/remediation", remediationHandler.GetRemediation)
+			issues.PUT("/:id/remediation", remediationHandler.SaveRemediation)
+			issues.PATCH("/:id/remediation", remediationHandler.SaveRemediation)
+			issues.POST("/:id/remediation/milestones", remediationHandler.CreateRemediationMilestone)
+			issues.PUT("/:id/remediation/milestones/:milestoneId", remediationHandler.UpdateRemediationMilestone)
+			issues.PATCH("/:id/remediation/milestones/:milestoneId", remediationHandler.UpdateRemediationMilestone)
+			issues.DELETE("/:id/remediation/milestones/:milestoneId", remediationHandler.DeleteRemediationMilestone)
+			issues.GET("/:id/remediation/updates", remediationHandler.GetRemediationUpdates)
+			issues.POST("/:id/remediation/updates", remediationHandler.CreateRemediationUpdate)
+			issues.GET("/:id/retests", remediationHandler.GetRetests)
+			issues.POST("/:id/retests", remediationHandler.CreateRetest)
+		}
+
+		// Compliance framework catalog
+		frameworks := v1.Group("/frameworks")
+		{
+			frameworks.GET("", frameworkHandler.GetFrameworks)
+			frameworks.POST("", frameworkHandler.CreateFramework)
+			frameworks.GET("/:id", frameworkHandler.GetFramework)
+			frameworks.PUT("/:id", frameworkHandler.UpdateFramework)
+			frameworks.PATCH("/:id", frameworkHandler.UpdateFramework)
+			frameworks.DELETE("/:id", frameworkHandler.DeleteFramework)
+			frameworks.GET("/:id/controls", frameworkHandler.GetFrameworkControls)
+			frameworks.POST("/:id/controls", frameworkHandler.CreateFrameworkControl)
+		}
+
+		// House templates for setting up projects
+		projectTemplates := v1.Group("/project-templates")
+		{
+			projectTemplates.GET("", projectTemplateHandler.GetProjectTemplates)
+			projectTemplates.POST("", projectTemplateHandler.CreateProjectTemplate)
+			projectTemplates.GET("/:id", projectTemplateHandler.GetProjectTemplate)
+			projectTemplates.DELETE("/:id", projectTemplateHandler.DeleteProjectTemplate)
+			projectTemplates.POST("/:id/instantiate", projectTemplateHandler.InstantiateProjectTemplate)
+		}
+
+		frameworkControls := v1.Group("/framework-controls")
+		{
+			frameworkControls.GET("/:id", frameworkHandler.GetFrameworkControl)
+			frameworkControls.PUT("/:id", frameworkHandler.UpdateFrameworkControl)
+			frameworkControls.PATCH("/:id", frameworkHandler.UpdateFrameworkControl)
+			frameworkControls.DELETE("/:id", frameworkHandler.DeleteFrameworkControl)
+			frameworkControls.GET("/:id/mappings", controlMappingHandler.GetControlMappings)
+			frameworkControls.POST("/:id/mappings", controlMappingHandler.CreateControlMapping)
+		}
+
+		controlMappings := v1.Group("/control-mappings")
+		{
+			controlMappings.PUT("/:id", controlMappingHandler.UpdateControlMapping)
+			controlMappings.PATCH("/:id", controlMappingHandler.UpdateControlMapping)
+			controlMappings.DELETE("/:id", controlMappingHandler.DeleteControlMapping)
 		}
 
 		// File uploads
 		uploads := v1.Group("/uploads")
 		{
-			uploads.POST("/requirements-csv/:projectId", requirementHandler.UploadRequirementsCSV)
+			uploads.POST("/requirements-csv/:projectId", projectLock(database, workflow.EntityProject, "projectId"), requirementHandler.UploadRequirementsCSV)
+			uploads.POST("/framework-controls-csv/:frameworkId", frameworkHandler.UploadFrameworkControlsCSV)
 		}
 
 		// Auth
@@ -103,6 +259,26 @@ func SetupRoutes(router *gin.Engine, database *db.Database) {
 		{
 			auth.POST("/login", userHandler.Login)
 		}
+
+		// Team calendar
+		v1.GET("/timeline", timelineHandler.GetTimeline)
+
+		// Batch operations
+		v1.POST("/batch", batchHandler.ExecuteBatch)
+
+		// Status workflows
+		workflows := v1.Group("/workflows")
+		{
+			workflows.GET("/:entityType", workflowHandler.GetWorkflow)
+			workflows.PUT("/:entityType", workflowHandler.UpdateWorkflow)
+			workflows.DELETE("/:entityType", workflowHandler.DeleteWorkflow)
+		}
+
+		// Reference data
+		meta := v1.Group("/meta")
+		{
+			meta.GET("/enums", metaHandler.GetEnums)
+		}
 	}
 
 	// API documentation endpoint
@@ -110,14 +286,20 @@ func SetupRoutes(router *gin.Engine, database *db.Database) {
 		c.JSON(200, gin.H{
 			"message": "Tessellate Projects API v1",
 			"endpoints": gin.H{
-				"projects":     "/api/v1/projects",
-				"users":        "/api/v1/users",
-				"clients":      "/api/v1/clients",
-				"requirements": "/api/v1/requirements",
-				"audit-tasks":  "/api/v1/audit-tasks",
-				"issues":       "/api/v1/issues",
-				"uploads":      "/api/v1/uploads",
-				"auth":         "/api/v1/auth",
+				"projects":          "/api/v1/projects",
+				"users":             "/api/v1/users",
+				"clients":           "/api/v1/clients",
+				"requirements":      "/api/v1/requirements",
+				"audit-tasks":       "/api/v1/audit-tasks",
+				"issues":            "/api/v1/issues",
+				"uploads":           "/api/v1/uploads",
+				"auth":              "/api/v1/auth",
+				"frameworks":        "/api/v1/frameworks",
+				"project-templates": "/api/v1/project-templates",
+				"timeline":          "/api/v1/timeline",
+				"batch":             "/api/v1/batch",
+				"workflows":         "/api/v1/workflows",
+				"meta":              "/api/v1/meta",
 			},
 		})
 	})
@@ -0,0 +1,639 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/attest"
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SignOffHandler serves the sign-off of completed projects. Sign-offs are
+// signed with the server's key; the first one locks the project.
+type SignOffHandler struct {
+	store
+	signer *attest.Signer
+}
+
+func NewSignOffHandler(database *db.Database, signer *attest.Signer) *SignOffHandler {
+	return &SignOffHandler{store: store{db: database}, signer: signer}
+}
+
+// signedPayload is what a sign-off's signature covers.
+type signedPayload struct {
+	ProjectID      uint   `json:"projectId"`
+	SignerID       uint   `json:"signerId"`
+	Role           string `json:"role"`
+	Statement      string `json:"statement"`
+	SignedAt       string `json:"signedAt"`
+	SnapshotDigest string `json:"snapshotDigest"`
+	KeyID          string `json:"keyId"`
+}
+
+func signOffPayload(signOff *db.SignOff) ([]byte, error) {
+	return attest.Canonical(signedPayload{
+		ProjectID:      signOff.ProjectID,
+		SignerID:       signOff.SignerID,
+		Role:           string(signOff.Role),
+		Statement:      signOff.Statement,
+		SignedAt:       signOff.SignedAt.UTC().Format(time.RFC3339Nano),
+		SnapshotDigest: signOff.SnapshotDigest,
+		KeyID:          signOff.KeyID,
+	})
+}
+
+// GetSignOffs handles GET /api/v1/projects/:id/sign-offs
+func (h *SignOffHandler) GetSignOffs(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	preloads, err := parseIncludes(c, "signOff")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+
+	var signOffs []*db.SignOff
+	if err := withPreloads(h.conn(c).DB, preloads, "").
+		Where("project_id = ?", project.ID).
+		Order("signed_at").
+		Find(&signOffs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch sign-offs",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]models.SignOffResponse, len(signOffs))
+	for i, signOff := range signOffs {
+		response[i] = convertToSignOffResponse(signOff)
+	}
+
+	renderResource(c, http.StatusOK, response)
+}
+
+// CreateSignOff handles POST /api/v1/projects/:id/sign-offs
+//
+// A COMPLETED project is signed off once in each role: LEAD by a lead on the
+// project, CLIENT_EXECUTIVE by a CLIENT user of the project's client. The
+// signature covers the sign-off and the digest of a canonical snapshot of the
+// project's requirements, tasks and issues. The first sign-off locks the
+// project read-only.
+func (h *SignOffHandler) CreateSignOff(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+
+	var req models.SignOffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	allowed, err := canSignAs(h.conn(c), project, user, db.SignOffRole(req.Role))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to check signer",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Insufficient permissions",
+			Message: "only a project lead can sign off as LEAD, and only a CLIENT user of the project's client as CLIENT_EXECUTIVE",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	if project.Status != db.ProjectStatusCompleted {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Project cannot be signed off",
+			Message: "only COMPLETED projects can be signed off",
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	var existing []db.SignOff
+	if err := h.conn(c).Where("project_id = ?", project.ID).Order("signed_at").Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch sign-offs",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	for _, signOff := range existing {
+		if string(signOff.Role) == req.Role || signOff.SignerID == user.ID {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "Project already signed off",
+				Message: "each role signs off once, each signer in one role",
+				Code:    http.StatusConflict,
+			})
+			return
+		}
+	}
+
+	// Later signers attest to the project in the first sign-off's layout
+	version := snapshotVersion
+	if len(existing) > 0 {
+		version = signedSnapshotVersion(&existing[0])
+	}
+	snapshot, err := buildProjectSnapshot(h.conn(c), project, version)
+	var canonical []byte
+	if err == nil {
+		canonical, err = attest.Canonical(snapshot)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to snapshot project",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	signOff := db.SignOff{
+		ProjectID:      project.ID,
+		SignerID:       user.ID,
+		Role:           db.SignOffRole(req.Role),
+		Statement:      req.Statement,
+		SignedAt:       time.Now().UTC(),
+		Snapshot:       string(canonical),
+		SnapshotDigest: attest.Digest(canonical),
+		KeyID:          h.signer.KeyID(),
+		PublicKey:      h.signer.PublicKey(),
+	}
+	// Every signer attests to the same state of the project
+	if len(existing) > 0 && existing[0].SnapshotDigest != signOff.SnapshotDigest {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Project changed since it was signed off",
+			Message: "verify the existing sign-offs before signing",
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	if err := h.saveSignOff(c, project, &signOff); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to sign off project",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	signOff.Signer = user
+	c.JSON(http.StatusCreated, convertToSignOffResponse(&signOff))
+}
+
+// saveSignOff signs and stores the sign-off and locks the project, in one
+// transaction.
+func (h *SignOffHandler) saveSignOff(c *gin.Context, project *db.Project, signOff *db.SignOff) error {
+	payload, err := signOffPayload(signOff)
+	if err != nil {
+		return err
+	}
+	signOff.Signature = h.signer.Sign(payload)
+
+	return h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(signOff).Error; err != nil {
+			return err
+		}
+		if project.LockedAt != nil {
+			return nil
+		}
+		project.LockedAt = &signOff.SignedAt
+		return tx.Model(project).Update("locked_at", project.LockedAt).Error
+	})
+}
+
+// VerifySignOffs handles GET /api/v1/projects/:id/sign-offs/verify
+//
+// Each sign-off's signature is checked against the signed payload and the
+// server's key, its snapshot against the signed digest, and the project as
+// it is now against the snapshot. Changes since the snapshot are listed.
+func (h *SignOffHandler) VerifySignOffs(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	project, ok := h.loadProject(c, user)
+	if !ok {
+		return
+	}
+
+	var signOffs []db.SignOff
+	if err := h.conn(c).Where("project_id = ?", project.ID).Order("signed_at").Find(&signOffs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to verify sign-offs",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	// Each sign-off is compared with the project in its own snapshot layout
+	type currentState struct {
+		snapshot *projectSnapshot
+		digest   string
+	}
+	states := map[int]currentState{}
+	stateAt := func(version int) (currentState, error) {
+		if state, ok := states[version]; ok {
+			return state, nil
+		}
+		snapshot, err := buildProjectSnapshot(h.conn(c), project, version)
+		if err != nil {
+			return currentState{}, err
+		}
+		canonical, err := attest.Canonical(snapshot)
+		if err != nil {
+			return currentState{}, err
+		}
+		states[version] = currentState{snapshot: snapshot, digest: attest.Digest(canonical)}
+		return states[version], nil
+	}
+
+	latest, err := stateAt(snapshotVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to verify sign-offs",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := models.SignOffVerificationResponse{
+		ProjectID:     project.ID,
+		Valid:         len(signOffs) > 0,
+		CurrentDigest: latest.digest,
+		SignOffs:      make([]models.SignOffVerification, len(signOffs)),
+	}
+	for i := range signOffs {
+		current, err := stateAt(signedSnapshotVersion(&signOffs[i]))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to verify sign-offs",
+				Code:  http.StatusInternalServerError,
+			})
+			return
+		}
+		check := verifySignOff(&signOffs[i], current.snapshot, current.digest, h.signer)
+		response.Valid = response.Valid && check.SignatureValid && check.KeyTrusted &&
+			check.SnapshotIntact && check.ProjectUnchanged
+		response.SignOffs[i] = check
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func verifySignOff(signOff *db.SignOff, current *projectSnapshot, currentDigest string, signer *attest.Signer) models.SignOffVerification {
+	check := models.SignOffVerification{
+		SignOffID:        signOff.ID,
+		Role:             string(signOff.Role),
+		SignerID:         signOff.SignerID,
+		KeyTrusted:       signOff.PublicKey == signer.PublicKey(),
+		SnapshotIntact:   attest.Digest([]byte(signOff.Snapshot)) == signOff.SnapshotDigest,
+		ProjectUnchanged: signOff.SnapshotDigest == currentDigest,
+		Changes:          []models.SnapshotChange{},
+	}
+	if payload, err := signOffPayload(signOff); err == nil {
+		check.SignatureValid = attest.Verify(signOff.PublicKey, signOff.Signature, payload)
+	}
+
+	var signed projectSnapshot
+	if err := json.Unmarshal([]byte(signOff.Snapshot), &signed); err != nil {
+		check.SnapshotIntact = false
+		return check
+	}
+	check.Changes = diffSnapshots(&signed, current)
+	return check
+}
+
+// signedSnapshotVersion returns the layout of a sign-off's snapshot. Snapshots
+// that do not say, or cannot be read, are version 1.
+func signedSnapshotVersion(signOff *db.SignOff) int {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(signOff.Snapshot), &header); err != nil || header.Version < 1 {
+		return 1
+	}
+	return header.Version
+}
+
+// loadProject loads the project named by the id path parameter, writing an
+// error response unless it exists and the user can access it.
+func (h *SignOffHandler) loadProject(c *gin.Context, user *db.User) (*db.Project, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return nil, false
+	}
+	return &project, true
+}
+
+// canSignAs reports whether the user may sign off the project in the role.
+func canSignAs(database *db.Database, project *db.Project, user *db.User, role db.SignOffRole) (bool, error) {
+	switch role {
+	case db.SignOffRoleLead:
+		var count int64
+		err := database.Model(&db.ProjectUser{}).
+			Where("project_id = ? AND user_id = ? AND role = ?", project.ID, user.ID, db.ProjectRoleLead).
+			Count(&count).Error
+		return count > 0, err
+	case db.SignOffRoleClientExecutive:
+		err := checkClientUser(database, project, user.ID)
+		if errors.Is(err, errNotClientUser) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, nil
+}
+
+// snapshotVersion is the layout new sign-offs snapshot projects in. Version
+// 2 added the requirements' rationale, parent and section flag; version 1
+// snapshots carry no version and are rebuilt without them when verified.
+const snapshotVersion = 2
+
+// projectSnapshot is the state of a project a sign-off attests to. Lists are
+// ordered by id and times are in UTC so that the same state always encodes
+// to the same canonical JSON. The project's status is left out: a signed off
+// project may still be archived.
+type projectSnapshot struct {
+	Version      int                   `json:"version,omitempty"`
+	Project      snapshotProject       `json:"project"`
+	Requirements []snapshotRequirement `json:"requirements"`
+	AuditTasks   []snapshotAuditTask   `json:"auditTasks"`
+	Issues       []snapshotIssue       `json:"issues"`
+}
+
+type snapshotProject struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	ClientID *uint  `json:"clientId"`
+}
+
+type snapshotRequirement struct {
+	ID                 uint    `json:"id"`
+	Text               string  `json:"text"`
+	Category           *string `json:"category"`
+	Status             string  `json:"status"`
+	FrameworkControlID *uint   `json:"frameworkControlId"`
+	Rationale          *string `json:"rationale,omitempty"`
+	ParentID           *uint   `json:"parentId,omitempty"`
+	Section            bool    `json:"section,omitempty"`
+}
+
+type snapshotAuditTask struct {
+	ID            uint               `json:"id"`
+	RequirementID uint               `json:"requirementId"`
+	Text          string             `json:"text"`
+	Status        string             `json:"status"`
+	Notes         *string            `json:"notes"`
+	DueDate       *time.Time         `json:"dueDate"`
+	AssigneeID    *uint              `json:"assigneeId"`
+	ReviewerID    *uint              `json:"reviewerId"`
+	PreparedByID  *uint              `json:"preparedById"`
+	PreparedAt    *time.Time         `json:"preparedAt"`
+	ReviewedByID  *uint              `json:"reviewedById"`
+	ReviewedAt    *time.Time         `json:"reviewedAt"`
+	ReviewNotes   *string            `json:"reviewNotes"`
+	Evidence      []snapshotEvidence `json:"evidence"`
+}
+
+type snapshotEvidence struct {
+	ID     uint   `json:"id"`
+	SHA256 string `json:"sha256"`
+}
+
+type snapshotIssue struct {
+	ID                   uint       `json:"id"`
+	AuditTaskID          uint       `json:"auditTaskId"`
+	RequirementIDs       []uint     `json:"requirementIds"`
+	Title                string     `json:"title"`
+	Description          *string    `json:"description"`
+	Type                 string     `json:"type"`
+	Status               string     `json:"status"`
+	Priority             *string    `json:"priority"`
+	Phase                *string    `json:"phase"`
+	EstimateHrs          *int       `json:"estimateHrs"`
+	ResolutionNote       *string    `json:"resolutionNote"`
+	DueDate              *time.Time `json:"dueDate"`
+	AssigneeID           *uint      `json:"assigneeId"`
+	ReviewerID           *uint      `json:"reviewerId"`
+	InherentLikelihood   *int       `json:"inherentLikelihood"`
+	InherentImpact       *int       `json:"inherentImpact"`
+	InherentRating       *string    `json:"inherentRating"`
+	ResidualLikelihood   *int       `json:"residualLikelihood"`
+	ResidualImpact       *int       `json:"residualImpact"`
+	ResidualRating       *string    `json:"residualRating"`
+	CompensatingControls *string    `json:"compensatingControls"`
+}
+
+// buildProjectSnapshot reads the project's requirements, audit tasks and
+// issues as they are now, in the given snapshot version's layout.
+func buildProjectSnapshot(database *db.Database, project *db.Project, version int) (*projectSnapshot, error) {
+	snapshot := &projectSnapshot{
+		Project:      snapshotProject{ID: project.ID, Name: project.Name, ClientID: project.ClientID},
+		Requirements: []snapshotRequirement{},
+		AuditTasks:   []snapshotAuditTask{},
+		Issues:       []snapshotIssue{},
+	}
+	if version > 1 {
+		snapshot.Version = version
+	}
+
+	var requirements []db.Requirement
+	if err := database.Where("project_id = ?", project.ID).Order("id").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	for _, requirement := range requirements {
+		entry := snapshotRequirement{
+			ID:                 requirement.ID,
+			Text:               requirement.Text,
+			Category:           requirement.Category,
+			Status:             string(requirement.Status),
+			FrameworkControlID: requirement.FrameworkControlID,
+		}
+		if version >= 2 {
+			entry.Rationale = requirement.Rationale
+			entry.ParentID = requirement.ParentID
+			entry.Section = requirement.Section
+		}
+		snapshot.Requirements = append(snapshot.Requirements, entry)
+	}
+
+	var tasks []db.AuditTask
+	if err := database.Preload("Evidence", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("requirement_id IN (SELECT id FROM requirements WHERE project_id = ? AND deleted_at IS NULL)", project.ID).
+		Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		entry := snapshotAuditTask{
+			ID:            task.ID,
+			RequirementID: task.RequirementID,
+			Text:          task.Text,
+			Status:        string(task.Status),
+			Notes:         task.Notes,
+			DueDate:       utcTime(task.DueDate),
+			AssigneeID:    task.AssigneeID,
+			ReviewerID:    task.ReviewerID,
+			PreparedByID:  task.PreparedByID,
+			PreparedAt:    utcTime(task.PreparedAt),
+			ReviewedByID:  task.ReviewedByID,
+			ReviewedAt:    utcTime(task.ReviewedAt),
+			ReviewNotes:   task.ReviewNotes,
+			Evidence:      make([]snapshotEvidence, len(task.Evidence)),
+		}
+		for i, evidence := range task.Evidence {
+			entry.Evidence[i] = snapshotEvidence{ID: evidence.ID, SHA256: evidence.SHA256}
+		}
+		snapshot.AuditTasks = append(snapshot.AuditTasks, entry)
+	}
+
+	var issues []db.Issue
+	if err := database.Preload("Requirements").
+		Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Where("requirements.project_id = ? AND audit_tasks.deleted_at IS NULL AND requirements.deleted_at IS NULL", project.ID).
+		Order("issues.id").Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		entry := snapshotIssue{
+			ID:                   issue.ID,
+			AuditTaskID:          issue.AuditTaskID,
+			RequirementIDs:       make([]uint, len(issue.Requirements)),
+			Title:                issue.Title,
+			Description:          issue.Description,
+			Type:                 string(issue.Type),
+			Status:               string(issue.Status),
+			Priority:             (*string)(issue.Priority),
+			Phase:                (*string)(issue.Phase),
+			EstimateHrs:          issue.EstimateHrs,
+			ResolutionNote:       issue.ResolutionNote,
+			DueDate:              utcTime(issue.DueDate),
+			AssigneeID:           issue.AssigneeID,
+			ReviewerID:           issue.ReviewerID,
+			InherentLikelihood:   issue.InherentLikelihood,
+			InherentImpact:       issue.InherentImpact,
+			InherentRating:       (*string)(issue.InherentRating),
+			ResidualLikelihood:   issue.ResidualLikelihood,
+			ResidualImpact:       issue.ResidualImpact,
+			ResidualRating:       (*string)(issue.ResidualRating),
+			CompensatingControls: issue.CompensatingControls,
+		}
+		for i, requirement := range issue.Requirements {
+			entry.RequirementIDs[i] = requirement.ID
+		}
+		sort.Slice(entry.RequirementIDs, func(i, j int) bool { return entry.RequirementIDs[i] < entry.RequirementIDs[j] })
+		snapshot.Issues = append(snapshot.Issues, entry)
+	}
+
+	return snapshot, nil
+}
+
+// diffSnapshots lists the requirements, audit tasks and issues added,
+// removed or modified between two snapshots.
+func diffSnapshots(signed, current *projectSnapshot) []models.SnapshotChange {
+	changes := []models.SnapshotChange{}
+	if !sameJSON(signed.Project, current.Project) {
+		changes = append(changes, models.SnapshotChange{Type: "project", ID: current.Project.ID, Change: "modified"})
+	}
+	changes = diffEntities(changes, "requirement", entitiesByID(signed.Requirements, func(r snapshotRequirement) uint { return r.ID }),
+		entitiesByID(current.Requirements, func(r snapshotRequirement) uint { return r.ID }))
+	changes = diffEntities(changes, "auditTask", entitiesByID(signed.AuditTasks, func(t snapshotAuditTask) uint { return t.ID }),
+		entitiesByID(current.AuditTasks, func(t snapshotAuditTask) uint { return t.ID }))
+	changes = diffEntities(changes, "issue", entitiesByID(signed.Issues, func(i snapshotIssue) uint { return i.ID }),
+		entitiesByID(current.Issues, func(i snapshotIssue) uint { return i.ID }))
+	return changes
+}
+
+func entitiesByID[T any](entities []T, id func(T) uint) map[uint]T {
+	byID := make(map[uint]T, len(entities))
+	for _, entity := range entities {
+		byID[id(entity)] = entity
+	}
+	return byID
+}
+
+func diffEntities[T any](changes []models.SnapshotChange, entityType string, signed, current map[uint]T) []models.SnapshotChange {
+	ids := make([]uint, 0, len(signed)+len(current))
+	for id := range signed {
+		ids = append(ids, id)
+	}
+	for id := range current {
+		if _, ok := signed[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		before, wasSigned := signed[id]
+		after, isCurrent := current[id]
+		switch {
+		case !wasSigned:
+			changes = append(changes, models.SnapshotChange{Type: entityType, ID: id, Change: "added"})
+		case !isCurrent:
+			changes = append(changes, models.SnapshotChange{Type: entityType, ID: id, Change: "removed"})
+		case !sameJSON(before, after):
+			changes = append(changes, models.SnapshotChange{Type: entityType, ID: id, Change: "modified"})
+		}
+	}
+	return changes
+}
+
+func sameJSON(a, b interface{}) bool {
+	left, errLeft := attest.Canonical(a)
+	right, errRight := attest.Canonical(b)
+	return errLeft == nil && errRight == nil && string(left) == string(right)
+}
@@ -0,0 +1,483 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSLADays caps the days an SLA policy may allow for a priority.
+const maxSLADays = 3650
+
+// overdueIssue matches issues still open past their due date at the bound
+// time; the second argument is closedIssueStatuses.
+const overdueIssue = "issues.due_date IS NOT NULL AND issues.due_date < ? AND issues.status NOT IN ?"
+
+// overdueAuditTask matches audit tasks not reviewed by their due date at the
+// bound time.
+const overdueAuditTask = "audit_tasks.due_date IS NOT NULL AND audit_tasks.due_date < ? AND audit_tasks.status <> '" +
+	string(db.AuditTaskStatusReviewed) + "'"
+
+// SLAHandler manages the clients' SLA policies, which set issue due dates by
+// priority, and reports how projects keep to their due dates.
+type SLAHandler struct {
+	store
+}
+
+func NewSLAHandler(database *db.Database) *SLAHandler {
+	return &SLAHandler{store{db: database}}
+}
+
+// GetClientSLAPolicy handles GET /api/v1/clients/:id/sla-policy
+func (h *SLAHandler) GetClientSLAPolicy(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	days, err := clientSLAPolicy(client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load SLA policy",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, slaPolicyResponse(client.ID, days))
+}
+
+// UpdateClientSLAPolicy handles PUT /api/v1/clients/:id/sla-policy
+//
+// The due dates of the client's open issues that follow the policy are
+// derived again from the new one.
+func (h *SLAHandler) UpdateClientSLAPolicy(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	var req models.SLAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := validateSLAPolicy(req.Days); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid SLA policy",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	encoded, err := json.Marshal(req.Days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to encode SLA policy",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	client.SLAPolicy = ptr(string(encoded))
+	if !h.saveSLAPolicy(c, client, req.Days) {
+		return
+	}
+
+	c.JSON(http.StatusOK, slaPolicyResponse(client.ID, req.Days))
+}
+
+// DeleteClientSLAPolicy handles DELETE /api/v1/clients/:id/sla-policy. Open
+// issues that followed the policy are left without a due date.
+func (h *SLAHandler) DeleteClientSLAPolicy(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	client, ok := h.loadClient(c, user)
+	if !ok {
+		return
+	}
+
+	client.SLAPolicy = nil
+	if !h.saveSLAPolicy(c, client, nil) {
+		return
+	}
+
+	c.JSON(http.StatusOK, slaPolicyResponse(client.ID, nil))
+}
+
+// GetProjectSLACompliance handles GET /api/v1/projects/:id/sla-compliance
+//
+// Counts the project's issues and audit tasks that have a due date: how many
+// are open, overdue, and closed on time or late. An item's closing time is
+// its latest transition into a closed status.
+func (h *SLAHandler) GetProjectSLACompliance(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	response, err := slaCompliance(h.conn(c), &project, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to summarize SLA compliance",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// slaCompliance builds the SLA compliance summary of the project at the
+// given time.
+func slaCompliance(database *db.Database, project *db.Project, now time.Time) (*models.SLAComplianceResponse, error) {
+	policy, err := projectSLAPolicy(database, project)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.SLAComplianceResponse{
+		ProjectID:           project.ID,
+		Policy:              policy,
+		IssuesByPriority:    map[string]models.SLAComplianceSummary{},
+		OverdueIssueIDs:     []uint{},
+		OverdueAuditTaskIDs: []uint{},
+	}
+	if response.Policy == nil {
+		response.Policy = map[string]int{}
+	}
+
+	var issues []db.Issue
+	if err := database.Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+		Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Where("requirements.project_id = ? AND issues.due_date IS NOT NULL", project.ID).
+		Order("issues.id").
+		Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	issueIDs := make([]uint, len(issues))
+	for i, issue := range issues {
+		issueIDs[i] = issue.ID
+	}
+	closedIssues, err := closedAt(database, workflow.EntityIssue, issueIDs, closedIssueStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range issues {
+		issue := &issues[i]
+		closed := !issueOpen(issue)
+		overdue := issueOverdue(issue, now)
+		closedTime, ok := closedIssues[issue.ID]
+		if !ok {
+			closedTime = issue.UpdatedAt
+		}
+
+		countSLAItem(&response.Issues, *issue.DueDate, closed, overdue, closedTime)
+		if issue.Priority != nil {
+			summary := response.IssuesByPriority[string(*issue.Priority)]
+			countSLAItem(&summary, *issue.DueDate, closed, overdue, closedTime)
+			response.IssuesByPriority[string(*issue.Priority)] = summary
+		}
+		if overdue {
+			response.OverdueIssueIDs = append(response.OverdueIssueIDs, issue.ID)
+		}
+	}
+
+	var tasks []db.AuditTask
+	if err := database.Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+		Where("requirements.project_id = ? AND audit_tasks.due_date IS NOT NULL", project.ID).
+		Order("audit_tasks.id").
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskIDs := make([]uint, len(tasks))
+	for i, task := range tasks {
+		taskIDs[i] = task.ID
+	}
+	reviewedTasks, err := closedAt(database, workflow.EntityAuditTask, taskIDs, []db.AuditTaskStatus{db.AuditTaskStatusReviewed})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		overdue := auditTaskOverdue(task, now)
+		reviewedTime, ok := reviewedTasks[task.ID]
+		if !ok {
+			reviewedTime = task.UpdatedAt
+		}
+
+		countSLAItem(&response.AuditTasks, *task.DueDate, task.Status == db.AuditTaskStatusReviewed, overdue, reviewedTime)
+		if overdue {
+			response.OverdueAuditTaskIDs = append(response.OverdueAuditTaskIDs, task.ID)
+		}
+	}
+
+	finishSLASummary(&response.Issues)
+	finishSLASummary(&response.AuditTasks)
+	for priority, summary := range response.IssuesByPriority {
+		finishSLASummary(&summary)
+		response.IssuesByPriority[priority] = summary
+	}
+
+	return response, nil
+}
+
+// saveSLAPolicy stores the client's policy and derives the due dates of its
+// open issues that follow it, in one transaction. Signed off projects are
+// left as they are.
+func (h *SLAHandler) saveSLAPolicy(c *gin.Context, client *db.Client, days map[string]int) bool {
+	err := h.conn(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(client).Update("sla_policy", client.SLAPolicy).Error; err != nil {
+			return err
+		}
+
+		var issues []db.Issue
+		if err := tx.Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+			Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+			Joins("JOIN projects ON requirements.project_id = projects.id").
+			Where("projects.client_id = ? AND projects.locked_at IS NULL AND issues.due_date_from_sla = ? AND issues.status NOT IN ?", client.ID, true, closedIssueStatuses).
+			Find(&issues).Error; err != nil {
+			return err
+		}
+		for i := range issues {
+			issue := &issues[i]
+			applySLAPolicy(issue, days)
+			if err := tx.Model(issue).Update("due_date", issue.DueDate).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to save SLA policy",
+			Code:  http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// loadClient fetches the client named by the :id parameter and checks that
+// the user may access it.
+func (h *SLAHandler) loadClient(c *gin.Context, user *db.User) (*db.Client, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid client ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var client db.Client
+	if err := h.conn(c).First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Client not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	if !requireClientAccess(c, h.conn(c), user, client.ID) {
+		return nil, false
+	}
+	return &client, true
+}
+
+// validateSLAPolicy checks that the policy only names issue priorities and
+// allows each a sensible number of days.
+func validateSLAPolicy(days map[string]int) error {
+	for priority, allowed := range days {
+		if !db.IsEnumValue("issuePriority", priority) {
+			return fmt.Errorf("unknown priority %q", priority)
+		}
+		if allowed < 1 || allowed > maxSLADays {
+			return fmt.Errorf("%s issues must be allowed between 1 and %d days", priority, maxSLADays)
+		}
+	}
+	return nil
+}
+
+func slaPolicyResponse(clientID uint, days map[string]int) models.SLAPolicyResponse {
+	if days == nil {
+		return models.SLAPolicyResponse{ClientID: clientID, Source: "none", Days: map[string]int{}}
+	}
+	return models.SLAPolicyResponse{ClientID: clientID, Source: "client", Days: days}
+}
+
+// clientSLAPolicy returns the days the client allows to fix an issue, by
+// priority, or nil when it has no policy.
+func clientSLAPolicy(client *db.Client) (map[string]int, error) {
+	if client.SLAPolicy == nil {
+		return nil, nil
+	}
+	var days map[string]int
+	if err := json.Unmarshal([]byte(*client.SLAPolicy), &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// projectSLAPolicy returns the SLA policy of the project's client, or nil for
+// projects without a client.
+func projectSLAPolicy(database *db.Database, project *db.Project) (map[string]int, error) {
+	if project.ClientID == nil {
+		return nil, nil
+	}
+	var client db.Client
+	if err := database.First(&client, *project.ClientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return clientSLAPolicy(&client)
+}
+
+// applySLAPolicy derives the issue's due date from the SLA policy unless it
+// was set by hand: the days allowed for its priority, counted from when the
+// issue was raised. An issue without a priority the policy covers has no due
+// date.
+func applySLAPolicy(issue *db.Issue, days map[string]int) {
+	if !issue.DueDateFromSLA {
+		return
+	}
+	issue.DueDate = nil
+	if issue.Priority == nil {
+		return
+	}
+	allowed, ok := days[string(*issue.Priority)]
+	if !ok {
+		return
+	}
+
+	raised := issue.CreatedAt
+	if raised.IsZero() {
+		raised = time.Now()
+	}
+	due := raised.UTC().AddDate(0, 0, allowed)
+	issue.DueDate = &due
+}
+
+// issueOpen reports whether the issue still needs work.
+func issueOpen(issue *db.Issue) bool {
+	for _, status := range closedIssueStatuses {
+		if issue.Status == status {
+			return false
+		}
+	}
+	return true
+}
+
+// issueOverdue reports whether an open issue is past its due date.
+func issueOverdue(issue *db.Issue, now time.Time) bool {
+	return issue.DueDate != nil && now.After(*issue.DueDate) && issueOpen(issue)
+}
+
+// auditTaskOverdue reports whether a task is past its due date without being
+// reviewed.
+func auditTaskOverdue(task *db.AuditTask, now time.Time) bool {
+	return task.DueDate != nil && now.After(*task.DueDate) && task.Status != db.AuditTaskStatusReviewed
+}
+
+// closedAt returns when each entity last moved into one of the statuses,
+// according to its transition history.
+func closedAt[S ~string](database *db.Database, entityType string, ids []uint, statuses []S) (map[uint]time.Time, error) {
+	closed := make(map[uint]time.Time, len(ids))
+	if len(ids) == 0 {
+		return closed, nil
+	}
+
+	var transitions []db.StatusTransition
+	if err := database.Where("entity_type = ? AND entity_id IN ? AND to_status IN ?", entityType, ids, statuses).
+		Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	for _, transition := range transitions {
+		if transition.CreatedAt.After(closed[transition.EntityID]) {
+			closed[transition.EntityID] = transition.CreatedAt
+		}
+	}
+	return closed, nil
+}
+
+// countSLAItem adds an item with a due date to the summary.
+func countSLAItem(summary *models.SLAComplianceSummary, due time.Time, closed, overdue bool, closedTime time.Time) {
+	summary.WithDueDate++
+	switch {
+	case !closed:
+		summary.Open++
+		if overdue {
+			summary.Overdue++
+		}
+	case closedTime.After(due):
+		summary.Closed++
+		summary.ClosedLate++
+	default:
+		summary.Closed++
+		summary.ClosedOnTime++
+	}
+}
+
+// finishSLASummary computes the compliance rate: items closed on time over
+// items closed on time, closed late or overdue.
+func finishSLASummary(summary *models.SLAComplianceSummary) {
+	decided := summary.ClosedOnTime + summary.ClosedLate + summary.Overdue
+	if decided == 0 {
+		return
+	}
+	rate := float64(summary.ClosedOnTime) / float64(decided)
+	summary.ComplianceRate = &rate
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+
+	"tessellate-projects/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// txContextKey marks the transaction a batch operation runs in.
+type txContextKey struct{}
+
+// rollbackContextKey marks the cleanups to run if that transaction rolls back.
+type rollbackContextKey struct{}
+
+// store gives handlers access to the database. Handlers go through conn so
+// that operations dispatched by POST /batch share the batch's transaction.
+type store struct {
+	db *db.Database
+}
+
+// conn returns the transaction bound to the request, if any, and the shared
+// connection otherwise.
+func (s store) conn(c *gin.Context) *db.Database {
+	if tx, ok := c.Request.Context().Value(txContextKey{}).(*db.Database); ok {
+		return tx
+	}
+	return s.db
+}
+
+// withTx binds a transaction to a request context, along with the list that
+// onRollback adds cleanups to.
+func withTx(ctx context.Context, tx *db.Database, cleanups *[]func()) context.Context {
+	ctx = context.WithValue(ctx, txContextKey{}, tx)
+	return context.WithValue(ctx, rollbackContextKey{}, cleanups)
+}
+
+// onRollback registers cleanup to run if the transaction bound to the request
+// rolls back after the handler has returned, such as deleting an object
+// written to storage for it. Outside a batch there is no such transaction and
+// cleanup is dropped.
+func onRollback(c *gin.Context, cleanup func()) {
+	if cleanups, ok := c.Request.Context().Value(rollbackContextKey{}).(*[]func()); ok {
+		*cleanups = append(*cleanups, cleanup)
+	}
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Fieldwork runs from its actual start, or the planned one until it has
+// started, to its actual end once started, or the planned end until then.
+// Fieldwork under way has no end yet.
+const (
+	fieldworkStartSQL = "COALESCE(projects.fieldwork_start, projects.planned_fieldwork_start)"
+	fieldworkEndSQL   = "CASE WHEN projects.fieldwork_start IS NOT NULL THEN projects.fieldwork_end ELSE projects.planned_fieldwork_end END"
+)
+
+// TimelineHandler serves project calendars.
+type TimelineHandler struct {
+	store
+}
+
+func NewTimelineHandler(database *db.Database) *TimelineHandler {
+	return &TimelineHandler{store{db: database}}
+}
+
+// GetProjectTimeline handles GET /api/v1/projects/:id/timeline
+func (h *TimelineHandler) GetProjectTimeline(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).First(&project, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	response := models.ProjectTimelineResponse{
+		ProjectID: project.ID,
+		AuditType: (*string)(project.AuditType),
+		Events:    projectEvents(&project),
+	}
+	if project.FieldworkStart != nil && project.PlannedFieldworkStart != nil {
+		days := int(math.Round(project.FieldworkStart.Sub(*project.PlannedFieldworkStart).Hours() / 24))
+		response.FieldworkDelayDays = &days
+	}
+	response.ReportOverdue = project.ReportDueDate != nil && project.ReportDueDate.Before(time.Now()) &&
+		project.Status != db.ProjectStatusCompleted && project.Status != db.ProjectStatusArchived
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTimeline handles GET /api/v1/timeline
+//
+// Lists the timeline events of the projects the user can see, earliest
+// first, for a team calendar. ?from= and ?to= (dates or RFC 3339) keep the
+// events that overlap [from, to); ?clientId=, ?auditType= and ?status=
+// narrow the projects.
+func (h *TimelineHandler) GetTimeline(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	from, to, ok := dateWindow(c, "from", "to")
+	if !ok {
+		return
+	}
+
+	query := accessibleProjects(h.conn(c).DB, user)
+	if clientID := c.Query("clientId"); clientID != "" {
+		query = query.Where("projects.client_id = ?", clientID)
+	}
+	if auditType := c.Query("auditType"); auditType != "" {
+		query = query.Where("projects.audit_type = ?", auditType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("projects.status = ?", status)
+	}
+
+	var projects []db.Project
+	if err := query.Order("projects.id").Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch projects",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	events := []models.TimelineEvent{}
+	for i := range projects {
+		for _, event := range projectEvents(&projects[i]) {
+			if overlaps(event, from, to) {
+				events = append(events, event)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+
+	c.JSON(http.StatusOK, events)
+}
+
+// projectEvents lists the dated spans of a project, in calendar order.
+// Periods with only one of their dates set are left out, except for
+// fieldwork under way.
+func projectEvents(project *db.Project) []models.TimelineEvent {
+	events := []models.TimelineEvent{}
+	add := func(eventType string, start, end *time.Time) {
+		if start == nil {
+			return
+		}
+		events = append(events, models.TimelineEvent{
+			ProjectID:   project.ID,
+			ProjectName: project.Name,
+			Type:        eventType,
+			Start:       *start,
+			End:         end,
+		})
+	}
+
+	if project.PeriodEnd != nil {
+		add("auditPeriod", project.PeriodStart, project.PeriodEnd)
+	}
+	if project.PlannedFieldworkEnd != nil {
+		add("plannedFieldwork", project.PlannedFieldworkStart, project.PlannedFieldworkEnd)
+	}
+	add("fieldwork", project.FieldworkStart, project.FieldworkEnd)
+	add("reportDue", project.ReportDueDate, nil)
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// overlaps reports whether the event falls into [from, to). Events without
+// an end run on indefinitely, except for single dates.
+func overlaps(event models.TimelineEvent, from, to *time.Time) bool {
+	if to != nil && !event.Start.Before(*to) {
+		return false
+	}
+	if from == nil {
+		return true
+	}
+	if event.End != nil {
+		return !event.End.Before(*from)
+	}
+	if event.Type == "reportDue" {
+		return !event.Start.Before(*from)
+	}
+	return true
+}
+
+// filterProjectSchedule narrows a projects query by ?auditType=, by
+// ?fieldworkFrom= and ?fieldworkTo= to projects whose fieldwork overlaps
+// [fieldworkFrom, fieldworkTo), and by ?reportDueFrom= and ?reportDueTo= to
+// reports due in [reportDueFrom, reportDueTo). It writes a 400 response when
+// a date does not parse.
+func filterProjectSchedule(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	if auditType := c.Query("auditType"); auditType != "" {
+		query = query.Where("projects.audit_type = ?", auditType)
+	}
+
+	from, to, ok := dateWindow(c, "fieldworkFrom", "fieldworkTo")
+	if !ok {
+		return nil, false
+	}
+	if from != nil || to != nil {
+		query = query.Where(fieldworkStartSQL + " IS NOT NULL")
+	}
+	if to != nil {
+		query = query.Where(fieldworkStartSQL+" < ?", *to)
+	}
+	if from != nil {
+		query = query.Where("("+fieldworkEndSQL+" IS NULL OR "+fieldworkEndSQL+" >= ?)", *from)
+	}
+
+	from, to, ok = dateWindow(c, "reportDueFrom", "reportDueTo")
+	if !ok {
+		return nil, false
+	}
+	if from != nil {
+		query = query.Where("projects.report_due_date >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("projects.report_due_date < ?", *to)
+	}
+	return query, true
+}
+
+// dateWindow parses a pair of optional date query parameters, writing a 400
+// response when either does not parse.
+func dateWindow(c *gin.Context, fromParam, toParam string) (*time.Time, *time.Time, bool) {
+	var window [2]*time.Time
+	for i, param := range []string{fromParam, toParam} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := parseDate(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid " + param,
+				Message: "expected a date (YYYY-MM-DD) or an RFC 3339 timestamp",
+				Code:    http.StatusBadRequest,
+			})
+			return nil, nil, false
+		}
+		window[i] = &t
+	}
+	return window[0], window[1], true
+}
+
+// validProjectDates writes a 400 response unless each of the project's
+// periods ends no earlier than it starts.
+func validProjectDates(c *gin.Context, project *db.Project) bool {
+	periods := []struct {
+		start, end *time.Time
+		message    string
+	}{
+		{project.PeriodStart, project.PeriodEnd, "periodEnd must not be before periodStart"},
+		{project.PlannedFieldworkStart, project.PlannedFieldworkEnd, "plannedFieldworkEnd must not be before plannedFieldworkStart"},
+		{project.FieldworkStart, project.FieldworkEnd, "fieldworkEnd must not be before fieldworkStart"},
+	}
+	for _, period := range periods {
+		if period.start != nil && period.end != nil && period.end.Before(*period.start) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: period.message,
+				Code:    http.StatusBadRequest,
+			})
+			return false
+		}
+	}
+	if project.FieldworkEnd != nil && project.FieldworkStart == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "fieldworkEnd requires fieldworkStart",
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
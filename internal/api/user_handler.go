@@ -13,26 +13,36 @@ import (
 
 // UserHandler
 type UserHandler struct {
-	db *db.Database
+	store
 }
 
 func NewUserHandler(database *db.Database) *UserHandler {
-	return &UserHandler{db: database}
+	return &UserHandler{store{db: database}}
 }
 
 func (h *UserHandler) GetUsers(c *gin.Context) {
+	preloads, err := parseIncludes(c, "user", "client")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var users []db.User
-	if err := h.db.Preload("Client").Find(&users).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch users", Code: 500})
 		return
 	}
 
 	response := make([]models.UserResponse, len(users))
 	for i, user := range users {
-		response[i] = h.convertToUserResponse(&user)
+		response[i] = convertToUserResponse(&user)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
@@ -53,7 +63,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		ClientID: req.ClientID,
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.conn(c).Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to create user",
 			Code:  http.StatusInternalServerError,
@@ -61,7 +71,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToUserResponse(&user)
+	response := convertToUserResponse(&user)
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -75,8 +85,18 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "user", "client", "projects")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var user db.User
-	if err := h.db.Preload("Client").Preload("Projects").First(&user, id).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "User not found",
 			Code:  http.StatusNotFound,
@@ -84,8 +104,8 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToUserResponse(&user)
-	c.JSON(http.StatusOK, response)
+	response := convertToUserResponse(&user)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *UserHandler) UpdateUser(c *gin.Context) {
@@ -98,18 +118,8 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
 	var user db.User
-	if err := h.db.First(&user, id).Error; err != nil {
+	if err := h.conn(c).First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "User not found",
 			Code:  http.StatusNotFound,
@@ -117,21 +127,17 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// Update fields if provided
-	if req.Name != nil {
-		user.Name = *req.Name
-	}
-	if req.Email != nil {
-		user.Email = *req.Email
-	}
-	if req.Role != nil {
-		user.Role = db.Role(*req.Role)
-	}
-	if req.ClientID != nil {
-		user.ClientID = req.ClientID
+	var req models.UpdateUserRequest
+	if !bindUpdate(c, toUpdateUserRequest(&user), &req) {
+		return
 	}
 
-	if err := h.db.Save(&user).Error; err != nil {
+	user.Name = req.Name
+	user.Email = req.Email
+	user.Role = db.Role(req.Role)
+	user.ClientID = req.ClientID
+
+	if err := h.conn(c).Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to update user",
 			Code:  http.StatusInternalServerError,
@@ -139,7 +145,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	response := h.convertToUserResponse(&user)
+	response := convertToUserResponse(&user)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -153,7 +159,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&db.User{}, id).Error; err != nil {
+	if err := h.conn(c).Delete(&db.User{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to delete user",
 			Code:  http.StatusInternalServerError,
@@ -174,8 +180,18 @@ func (h *UserHandler) GetProjectUsers(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "user", "client")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var project db.Project
-	if err := h.db.Preload("Users").Preload("Users.Client").First(&project, projectID).Error; err != nil {
+	if err := withPreloads(h.conn(c).Preload("Users"), preloads, "Users").First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -183,12 +199,26 @@ func (h *UserHandler) GetProjectUsers(c *gin.Context) {
 		return
 	}
 
+	var memberships []db.ProjectUser
+	if err := h.conn(c).Where("project_id = ?", project.ID).Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch project roles",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+	roles := make(map[uint]db.ProjectRole, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.UserID] = membership.Role
+	}
+
 	response := make([]models.UserResponse, len(project.Users))
 	for i, user := range project.Users {
-		response[i] = h.convertToUserResponse(user)
+		response[i] = convertToUserResponse(user)
+		response[i].ProjectRole = string(roles[user.ID])
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *UserHandler) AssignUserToProject(c *gin.Context) {
@@ -211,7 +241,7 @@ func (h *UserHandler) AssignUserToProject(c *gin.Context) {
 	}
 
 	var project db.Project
-	if err := h.db.First(&project, projectID).Error; err != nil {
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -220,7 +250,7 @@ func (h *UserHandler) AssignUserToProject(c *gin.Context) {
 	}
 
 	var user db.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if err := h.conn(c).First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "User not found",
 			Code:  http.StatusNotFound,
@@ -228,8 +258,25 @@ func (h *UserHandler) AssignUserToProject(c *gin.Context) {
 		return
 	}
 
-	// Add user to project
-	if err := h.db.Model(&project).Association("Users").Append(&user); err != nil {
+	// Add user to project. An optional body sets their role on it, which
+	// otherwise starts as MEMBER and is kept for existing members.
+	membership := db.ProjectUser{ProjectID: project.ID, UserID: user.ID}
+	if c.Request.ContentLength != 0 {
+		var req models.ProjectMembershipRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		membership.Role = db.ProjectRole(req.Role)
+		err = h.conn(c).Save(&membership).Error
+	} else {
+		err = h.conn(c).Where(&membership).FirstOrCreate(&membership).Error
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to assign user to project",
 			Code:  http.StatusInternalServerError,
@@ -260,7 +307,7 @@ func (h *UserHandler) RemoveUserFromProject(c *gin.Context) {
 	}
 
 	var project db.Project
-	if err := h.db.First(&project, projectID).Error; err != nil {
+	if err := h.conn(c).First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "Project not found",
 			Code:  http.StatusNotFound,
@@ -269,7 +316,7 @@ func (h *UserHandler) RemoveUserFromProject(c *gin.Context) {
 	}
 
 	var user db.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if err := h.conn(c).First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error: "User not found",
 			Code:  http.StatusNotFound,
@@ -278,7 +325,7 @@ func (h *UserHandler) RemoveUserFromProject(c *gin.Context) {
 	}
 
 	// Remove user from project
-	if err := h.db.Model(&project).Association("Users").Delete(&user); err != nil {
+	if err := h.conn(c).Model(&project).Association("Users").Delete(&user); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to remove user from project",
 			Code:  http.StatusInternalServerError,
@@ -299,8 +346,18 @@ func (h *UserHandler) GetClientUsers(c *gin.Context) {
 		return
 	}
 
+	preloads, err := parseIncludes(c, "user")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid include",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	var users []db.User
-	if err := h.db.Where("client_id = ?", clientID).Find(&users).Error; err != nil {
+	if err := withPreloads(h.conn(c).DB, preloads, "").Where("client_id = ?", clientID).Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Failed to fetch client users",
 			Code:  http.StatusInternalServerError,
@@ -310,10 +367,10 @@ func (h *UserHandler) GetClientUsers(c *gin.Context) {
 
 	response := make([]models.UserResponse, len(users))
 	for i, user := range users {
-		response[i] = h.convertToUserResponse(&user)
+		response[i] = convertToUserResponse(&user)
 	}
 
-	c.JSON(http.StatusOK, response)
+	renderResource(c, http.StatusOK, response)
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
@@ -333,7 +390,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	var user db.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := h.conn(c).Where("email = ?", req.Email).First(&user).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error: "Invalid credentials",
 			Code:  http.StatusUnauthorized,
@@ -352,52 +409,8 @@ func (h *UserHandler) Login(c *gin.Context) {
 	// For now, return a simple token (in production, use JWT)
 	response := gin.H{
 		"token": "mock-token-" + strconv.Itoa(int(user.ID)),
-		"user":  h.convertToUserResponse(&user),
+		"user":  convertToUserResponse(&user),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
-
-// Helper function to convert db.User to models.UserResponse
-func (h *UserHandler) convertToUserResponse(user *db.User) models.UserResponse {
-	response := models.UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Role:      string(user.Role),
-		ClientID:  user.ClientID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}
-
-	// Convert client if loaded
-	if user.Client != nil {
-		response.Client = &models.ClientResponse{
-			ID:           user.Client.ID,
-			Name:         user.Client.Name,
-			Industry:     user.Client.Industry,
-			ContactName:  user.Client.ContactName,
-			ContactEmail: user.Client.ContactEmail,
-			CreatedAt:    user.Client.CreatedAt,
-			UpdatedAt:    user.Client.UpdatedAt,
-		}
-	}
-
-	// Convert projects if loaded
-	if len(user.Projects) > 0 {
-		response.Projects = make([]models.ProjectResponse, len(user.Projects))
-		for i, project := range user.Projects {
-			response.Projects[i] = models.ProjectResponse{
-				ID:         project.ID,
-				Name:       project.Name,
-				ClientName: project.ClientName,
-				Status:     project.Status,
-				ClientID:   project.ClientID,
-				CreatedAt:  project.CreatedAt,
-				UpdatedAt:  project.UpdatedAt,
-			}
-		}
-	}
-
-	return response
-}
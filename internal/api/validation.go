@@ -0,0 +1,25 @@
+package api
+
+import (
+	"log"
+
+	"tessellate-projects/internal/db"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// registerValidators adds the custom binding tags used by the request models.
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		log.Fatal("Unexpected binding validator engine")
+	}
+
+	// enum=<name> accepts only the values listed for that enum in db.Enums
+	if err := v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		return db.IsEnumValue(fl.Param(), fl.Field().String())
+	}); err != nil {
+		log.Fatalf("Failed to register enum validator: %v", err)
+	}
+}
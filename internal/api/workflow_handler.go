@@ -0,0 +1,690 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+	"tessellate-projects/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// workflowEnums maps each workflow entity type onto the enum holding its statuses
+var workflowEnums = map[string]string{
+	workflow.EntityProject:         "projectStatus",
+	workflow.EntityRequirement:     "requirementStatus",
+	workflow.EntityAuditTask:       "auditTaskStatus",
+	workflow.EntityIssue:           "issueStatus",
+	workflow.EntityDocumentRequest: "documentRequestStatus",
+}
+
+// WorkflowHandler
+type WorkflowHandler struct {
+	store
+}
+
+func NewWorkflowHandler(database *db.Database) *WorkflowHandler {
+	return &WorkflowHandler{store{db: database}}
+}
+
+// GetWorkflow handles GET /api/v1/workflows/:entityType and
+// GET /api/v1/projects/:id/workflows/:entityType
+func (h *WorkflowHandler) GetWorkflow(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	entityType := c.Param("entityType")
+	if _, ok := workflowEnums[entityType]; !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Unknown workflow entity type",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	projectID, ok := h.workflowProject(c)
+	if !ok {
+		return
+	}
+	if projectID != nil && !requireProjectAccess(c, h.conn(c), user, *projectID) {
+		return
+	}
+
+	definition, source, err := resolveWorkflow(h.conn(c), projectID, entityType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WorkflowResponse{
+		EntityType: entityType,
+		ProjectID:  projectID,
+		Source:     source,
+		Definition: definition,
+	})
+}
+
+// UpdateWorkflow handles PUT /api/v1/workflows/:entityType and
+// PUT /api/v1/projects/:id/workflows/:entityType
+func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	entityType := c.Param("entityType")
+	enum, ok := workflowEnums[entityType]
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Unknown workflow entity type",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	projectID, ok := h.workflowProject(c)
+	if !ok {
+		return
+	}
+
+	var definition workflow.Definition
+	if err := c.ShouldBindJSON(&definition); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := definition.Validate(db.Enums()[enum], db.Enums()["role"]); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid workflow",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	encoded, err := json.Marshal(definition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to encode workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	var stored db.Workflow
+	err = workflowQuery(h.conn(c).DB, projectID, entityType).First(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	stored.ProjectID = projectID
+	stored.EntityType = entityType
+	stored.Definition = string(encoded)
+	if err := h.conn(c).Save(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to save workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	source := "global"
+	if projectID != nil {
+		source = "project"
+	}
+	c.JSON(http.StatusOK, models.WorkflowResponse{
+		EntityType: entityType,
+		ProjectID:  projectID,
+		Source:     source,
+		Definition: definition,
+	})
+}
+
+// DeleteWorkflow handles DELETE /api/v1/workflows/:entityType and
+// DELETE /api/v1/projects/:id/workflows/:entityType, reverting to the
+// next workflow up the chain.
+func (h *WorkflowHandler) DeleteWorkflow(c *gin.Context) {
+	if _, ok := requireRole(c, h.conn(c), db.RoleAdmin); !ok {
+		return
+	}
+
+	entityType := c.Param("entityType")
+	if _, ok := workflowEnums[entityType]; !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Unknown workflow entity type",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	projectID, ok := h.workflowProject(c)
+	if !ok {
+		return
+	}
+
+	if err := workflowQuery(h.conn(c).Unscoped(), projectID, entityType).Delete(&db.Workflow{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to delete workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workflow reset successfully"})
+}
+
+func (h *WorkflowHandler) TransitionProject(c *gin.Context) {
+	h.transition(c, workflow.EntityProject, "Project")
+}
+
+func (h *WorkflowHandler) TransitionRequirement(c *gin.Context) {
+	h.transition(c, workflow.EntityRequirement, "Requirement")
+}
+
+func (h *WorkflowHandler) TransitionAuditTask(c *gin.Context) {
+	h.transition(c, workflow.EntityAuditTask, "Audit task")
+}
+
+func (h *WorkflowHandler) TransitionIssue(c *gin.Context) {
+	h.transition(c, workflow.EntityIssue, "Issue")
+}
+
+func (h *WorkflowHandler) TransitionDocumentRequest(c *gin.Context) {
+	h.transition(c, workflow.EntityDocumentRequest, "Document request")
+}
+
+func (h *WorkflowHandler) GetProjectTransitions(c *gin.Context) {
+	h.history(c, workflow.EntityProject, "Project")
+}
+
+func (h *WorkflowHandler) GetRequirementTransitions(c *gin.Context) {
+	h.history(c, workflow.EntityRequirement, "Requirement")
+}
+
+func (h *WorkflowHandler) GetAuditTaskTransitions(c *gin.Context) {
+	h.history(c, workflow.EntityAuditTask, "Audit task")
+}
+
+func (h *WorkflowHandler) GetIssueTransitions(c *gin.Context) {
+	h.history(c, workflow.EntityIssue, "Issue")
+}
+
+func (h *WorkflowHandler) GetDocumentRequestTransitions(c *gin.Context) {
+	h.history(c, workflow.EntityDocumentRequest, "Document request")
+}
+
+// transition handles POST /api/v1/<entities>/:id/transitions
+func (h *WorkflowHandler) transition(c *gin.Context, entityType, label string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("Invalid %s ID", strings.ToLower(label)),
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+	from, err := currentStatus(h.conn(c), entityType, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: label + " not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, projectIDFor(h.conn(c), entityType, uint(id))) {
+		return
+	}
+
+	var req models.TransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var record *db.StatusTransition
+	err = h.conn(c).Transaction(func(tx *gorm.DB) error {
+		database := &db.Database{DB: tx}
+		record, err = changeStatus(database, user, entityType, uint(id), from, req.To, req.Fields)
+		if err != nil {
+			return err
+		}
+		return saveStatus(database, user, entityType, uint(id), req.To, req.Fields)
+	})
+	if err != nil {
+		writeTransitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, convertToTransitionResponse(record))
+}
+
+// history handles GET /api/v1/<entities>/:id/transitions
+func (h *WorkflowHandler) history(c *gin.Context, entityType, label string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("Invalid %s ID", strings.ToLower(label)),
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+	status, err := currentStatus(h.conn(c), entityType, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: label + " not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	projectID := projectIDFor(h.conn(c), entityType, uint(id))
+	if !requireProjectAccess(c, h.conn(c), user, projectID) {
+		return
+	}
+
+	definition, _, err := resolveWorkflow(h.conn(c), &projectID, entityType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load workflow",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	var records []db.StatusTransition
+	if err := h.conn(c).Where("entity_type = ? AND entity_id = ?", entityType, id).Order("id").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch transitions",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := models.StatusHistoryResponse{
+		Status:    status,
+		Available: definition.Available(status, string(user.Role)),
+		History:   make([]models.TransitionResponse, len(records)),
+	}
+	for i, record := range records {
+		response.History[i] = convertToTransitionResponse(&record)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// workflowScope reads the optional project ID from the route, returning nil
+// for installation-wide workflows.
+func workflowScope(c *gin.Context) (*uint, bool) {
+	if c.Param("id") == "" {
+		return nil, true
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return nil, false
+	}
+	projectID := uint(id)
+	return &projectID, true
+}
+
+// workflowProject reads the workflow scope like workflowScope and checks
+// that a project-scoped workflow names an existing project.
+func (h *WorkflowHandler) workflowProject(c *gin.Context) (*uint, bool) {
+	projectID, ok := workflowScope(c)
+	if !ok || projectID == nil {
+		return projectID, ok
+	}
+
+	var count int64
+	if err := h.conn(c).Model(&db.Project{}).Where("id = ?", *projectID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to load project",
+			Code:  http.StatusInternalServerError,
+		})
+		return nil, false
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return nil, false
+	}
+	return projectID, true
+}
+
+func workflowQuery(query *gorm.DB, projectID *uint, entityType string) *gorm.DB {
+	query = query.Where("entity_type = ?", entityType)
+	if projectID == nil {
+		return query.Where("project_id IS NULL")
+	}
+	return query.Where("project_id = ?", *projectID)
+}
+
+// resolveWorkflow returns the workflow in force for an entity type: the
+// project's override, then the installation-wide override, then the
+// built-in default. The second return value names which one was used.
+func resolveWorkflow(database *db.Database, projectID *uint, entityType string) (workflow.Definition, string, error) {
+	scopes := []*uint{nil}
+	if projectID != nil {
+		scopes = []*uint{projectID, nil}
+	}
+
+	for _, scope := range scopes {
+		var stored db.Workflow
+		err := workflowQuery(database.DB, scope, entityType).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return workflow.Definition{}, "", err
+		}
+
+		var definition workflow.Definition
+		if err := json.Unmarshal([]byte(stored.Definition), &definition); err != nil {
+			return workflow.Definition{}, "", err
+		}
+		if scope != nil {
+			return definition, "project", nil
+		}
+		return definition, "global", nil
+	}
+
+	return workflow.Defaults[entityType], "default", nil
+}
+
+// projectIDFor returns the project an entity belongs to, or 0 if it is not
+// attached to one.
+func projectIDFor(database *db.Database, entityType string, id uint) uint {
+	var projectID uint
+	switch entityType {
+	case workflow.EntityProject:
+		projectID = id
+	case workflow.EntityRequirement:
+		database.Model(&db.Requirement{}).Select("project_id").Where("id = ?", id).Scan(&projectID)
+	case workflow.EntityAuditTask:
+		database.Model(&db.AuditTask{}).Select("requirements.project_id").
+			Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+			Where("audit_tasks.id = ?", id).Scan(&projectID)
+	case workflow.EntityIssue:
+		database.Model(&db.Issue{}).Select("requirements.project_id").
+			Joins("JOIN audit_tasks ON issues.audit_task_id = audit_tasks.id").
+			Joins("JOIN requirements ON audit_tasks.requirement_id = requirements.id").
+			Where("issues.id = ?", id).Scan(&projectID)
+	case workflow.EntityDocumentRequest:
+		database.Model(&db.DocumentRequest{}).Select("project_id").Where("id = ?", id).Scan(&projectID)
+	}
+	return projectID
+}
+
+// checkInitialStatus verifies that a new entity may be created in the given status.
+func checkInitialStatus(database *db.Database, entityType string, projectID uint, status string) error {
+	definition, _, err := resolveWorkflow(database, &projectID, entityType)
+	if err != nil {
+		return err
+	}
+	if !definition.IsInitial(status) {
+		return fmt.Errorf("%w: %s is not an initial status", workflow.ErrIllegalTransition, status)
+	}
+	return nil
+}
+
+// changeStatus runs a status change through the entity's workflow and
+// records it in the transition history. Callers persist the new status.
+func changeStatus(database *db.Database, user *db.User, entityType string, id uint, from, to string, fields map[string]string) (*db.StatusTransition, error) {
+	projectID := projectIDFor(database, entityType, id)
+	definition, _, err := resolveWorkflow(database, &projectID, entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := definition.Check(from, to, string(user.Role), fields); err != nil {
+		return nil, fmt.Errorf("%s -> %s: %w", from, to, err)
+	}
+	if err := checkPreconditions(database, user, entityType, id, from, to, fields); err != nil {
+		return nil, fmt.Errorf("%s -> %s: %w", from, to, err)
+	}
+
+	record := db.StatusTransition{
+		EntityType: entityType,
+		EntityID:   id,
+		ProjectID:  projectID,
+		FromStatus: from,
+		ToStatus:   to,
+		UserID:     &user.ID,
+	}
+	if len(fields) > 0 {
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		record.Fields = ptr(string(encoded))
+	}
+
+	if err := database.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// checkPreconditions checks entity state that a workflow cannot express.
+// Whatever the configured workflow, audit tasks are reviewed by a second
+// person, a requirement is only assessed as (partially) met once its tasks
+// are reviewed and only excluded or met by a compensating control with a
+// rationale, and an issue needs an agreed remediation plan before it is
+// REMEDIATION_PLANNED and a passing retest before it is VALIDATED.
+func checkPreconditions(database *db.Database, user *db.User, entityType string, id uint, from, to string, fields map[string]string) error {
+	switch entityType {
+	case workflow.EntityAuditTask:
+		return checkReview(database, user, id, from, to)
+	case workflow.EntityRequirement:
+		return checkAssessment(database, id, db.RequirementStatus(to), fields["rationale"])
+	case workflow.EntityIssue:
+	default:
+		return nil
+	}
+
+	switch db.IssueStatus(to) {
+	case db.IssueStatusRemediationPlanned:
+		var remediation db.Remediation
+		err := database.Where("issue_id = ?", id).First(&remediation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &workflow.PreconditionError{Reason: "the issue has no management response"}
+		}
+		if err != nil {
+			return err
+		}
+		if remediation.Agreement == db.ManagementAgreementDisagree {
+			return &workflow.PreconditionError{Reason: "management disagrees with the issue"}
+		}
+		if remediation.OwnerID == nil || remediation.TargetDate == nil {
+			return &workflow.PreconditionError{Reason: "the remediation needs an owner and a target date"}
+		}
+	case db.IssueStatusValidated:
+		var retest db.Retest
+		err := database.Where("issue_id = ?", id).Order("id DESC").First(&retest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &workflow.PreconditionError{Reason: "the issue has not been retested"}
+		}
+		if err != nil {
+			return err
+		}
+		if retest.Result != db.RetestResultPassed {
+			return &workflow.PreconditionError{Reason: "the latest retest failed"}
+		}
+	}
+	return nil
+}
+
+// currentStatus loads the status column of a workflow-managed entity.
+func currentStatus(database *db.Database, entityType string, id uint) (string, error) {
+	var status string
+	var err error
+	switch entityType {
+	case workflow.EntityProject:
+		var project db.Project
+		err = database.First(&project, id).Error
+		status = string(project.Status)
+	case workflow.EntityRequirement:
+		var requirement db.Requirement
+		err = database.First(&requirement, id).Error
+		status = string(requirement.Status)
+	case workflow.EntityAuditTask:
+		var task db.AuditTask
+		err = database.First(&task, id).Error
+		status = string(task.Status)
+	case workflow.EntityIssue:
+		var issue db.Issue
+		err = database.First(&issue, id).Error
+		status = string(issue.Status)
+	case workflow.EntityDocumentRequest:
+		var request db.DocumentRequest
+		err = database.First(&request, id).Error
+		status = string(request.Status)
+	default:
+		err = fmt.Errorf("unknown entity type %q", entityType)
+	}
+	return status, err
+}
+
+// saveStatus persists a status reached through the transitions endpoint,
+// along with any transition fields the entity keeps.
+func saveStatus(database *db.Database, user *db.User, entityType string, id uint, status string, fields map[string]string) error {
+	updates := map[string]interface{}{"status": status}
+
+	var model interface{}
+	switch entityType {
+	case workflow.EntityProject:
+		model = &db.Project{}
+	case workflow.EntityRequirement:
+		model = &db.Requirement{}
+		if rationale := fields["rationale"]; rationale != "" {
+			updates["rationale"] = rationale
+		}
+	case workflow.EntityAuditTask:
+		task := db.AuditTask{}
+		if err := database.First(&task, id).Error; err != nil {
+			return err
+		}
+		applyReview(&task, user, status, fields, time.Now().UTC())
+		updates["prepared_by_id"] = task.PreparedByID
+		updates["prepared_at"] = task.PreparedAt
+		updates["reviewer_id"] = task.ReviewerID
+		updates["reviewed_by_id"] = task.ReviewedByID
+		updates["reviewed_at"] = task.ReviewedAt
+		updates["review_notes"] = task.ReviewNotes
+		model = &db.AuditTask{}
+	case workflow.EntityIssue:
+		model = &db.Issue{}
+		if note := fields["resolutionNote"]; note != "" {
+			updates["resolution_note"] = note
+		}
+	case workflow.EntityDocumentRequest:
+		model = &db.DocumentRequest{}
+		if status == string(db.DocumentRequestStatusRejected) {
+			updates["review_note"] = fields["reason"]
+		}
+		if status == string(db.DocumentRequestStatusSubmitted) {
+			updates["submitted_at"] = time.Now().UTC()
+		}
+	default:
+		return fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	return database.Model(model).Where("id = ?", id).Updates(updates).Error
+}
+
+// writeTransitionError maps workflow errors onto HTTP responses.
+func writeTransitionError(c *gin.Context, err error) {
+	var missing *workflow.MissingFieldsError
+	var precondition *workflow.PreconditionError
+	switch {
+	case errors.As(err, &precondition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Transition precondition not met",
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		})
+	case errors.As(err, &missing):
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Missing transition fields",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		})
+	case errors.Is(err, workflow.ErrRoleNotAllowed):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Transition not permitted for role",
+			Message: err.Error(),
+			Code:    http.StatusForbidden,
+		})
+	case errors.Is(err, workflow.ErrIllegalTransition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Illegal status transition",
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to change status",
+			Code:  http.StatusInternalServerError,
+		})
+	}
+}
+
+// convertToTransitionResponse converts db.StatusTransition to models.TransitionResponse
+func convertToTransitionResponse(record *db.StatusTransition) models.TransitionResponse {
+	response := models.TransitionResponse{
+		ID:         record.ID,
+		EntityType: record.EntityType,
+		EntityID:   record.EntityID,
+		From:       record.FromStatus,
+		To:         record.ToStatus,
+		UserID:     record.UserID,
+		CreatedAt:  record.CreatedAt,
+	}
+	if record.Fields != nil {
+		json.Unmarshal([]byte(*record.Fields), &response.Fields)
+	}
+	return response
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tessellate-projects/internal/db"
+	"tessellate-projects/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNotProjectStaff is returned when a task or issue is assigned to someone
+// who does not work on its project.
+var errNotProjectStaff = errors.New("invalid assignee")
+
+// dueDateOrder lists work by due date, undated items last.
+const dueDateOrder = "due_date IS NULL, due_date, id"
+
+// WorkloadHandler reports who works on which audit tasks and issues.
+type WorkloadHandler struct {
+	store
+}
+
+func NewWorkloadHandler(database *db.Database) *WorkloadHandler {
+	return &WorkloadHandler{store{db: database}}
+}
+
+// GetUserWork handles GET /api/v1/users/:id/work
+//
+// Users see their own queue; admins see anyone's.
+func (h *WorkloadHandler) GetUserWork(c *gin.Context) {
+	user, ok := requireUser(c, h.conn(c))
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid user ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+	if user.ID != uint(id) && user.Role != db.RoleAdmin {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "Insufficient permissions",
+			Code:  http.StatusForbidden,
+		})
+		return
+	}
+
+	var worker db.User
+	if err := h.conn(c).First(&worker, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "User not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+
+	response, err := userWork(h.conn(c), worker.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to fetch assigned work",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetProjectWorkload handles GET /api/v1/projects/:id/workload
+func (h *WorkloadHandler) GetProjectWorkload(c *gin.Context) {
+	user, ok := requireRole(c, h.conn(c), db.RoleAdmin, db.RoleConsultant)
+	if !ok {
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid project ID",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var project db.Project
+	if err := h.conn(c).Preload("Users").First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Project not found",
+			Code:  http.StatusNotFound,
+		})
+		return
+	}
+	if !requireProjectAccess(c, h.conn(c), user, project.ID) {
+		return
+	}
+
+	response, err := projectWorkload(h.conn(c), &project, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Failed to summarize workload",
+			Code:  http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// userWork collects the open tasks and issues assigned to the user, or
+// waiting on their review, grouped by project.
+func userWork(database *db.Database, userID uint, now time.Time) (*models.UserWorkResponse, error) {
+	var tasks, reviewTasks []db.AuditTask
+	var issues, reviewIssues []db.Issue
+	err := database.Where("assignee_id = ? AND status <> ?", userID, db.AuditTaskStatusReviewed).
+		Order(dueDateOrder).Find(&tasks).Error
+	if err == nil {
+		err = database.Where("reviewer_id = ? AND status <> ?", userID, db.AuditTaskStatusReviewed).
+			Order(dueDateOrder).Find(&reviewTasks).Error
+	}
+	if err == nil {
+		err = database.Preload("Requirements").
+			Where("assignee_id = ? AND status NOT IN ?", userID, closedIssueStatuses).
+			Order(dueDateOrder).Find(&issues).Error
+	}
+	if err == nil {
+		err = database.Preload("Requirements").
+			Where("reviewer_id = ? AND status NOT IN ?", userID, closedIssueStatuses).
+			Order(dueDateOrder).Find(&reviewIssues).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	taskIDs := make([]uint, 0, len(tasks)+len(reviewTasks))
+	for _, task := range append(tasks, reviewTasks...) {
+		taskIDs = append(taskIDs, task.ID)
+	}
+	issueIDs := make([]uint, 0, len(issues)+len(reviewIssues))
+	for _, issue := range append(issues, reviewIssues...) {
+		issueIDs = append(issueIDs, issue.ID)
+	}
+	taskProjects, err := itemProjects(database, "audit_tasks", taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	issueProjects, err := itemProjects(database, "issues", issueIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserWorkResponse{
+		UserID:         userID,
+		OpenAuditTasks: len(tasks),
+		OpenIssues:     len(issues),
+		ToReview:       len(reviewTasks) + len(reviewIssues),
+		Projects:       []models.UserWorkProject{},
+	}
+	byProject := map[uint]*models.UserWorkProject{}
+	group := func(projectID uint) *models.UserWorkProject {
+		if work, ok := byProject[projectID]; ok {
+			return work
+		}
+		work := &models.UserWorkProject{
+			ProjectID:        projectID,
+			AuditTasks:       []models.AuditTaskResponse{},
+			Issues:           []models.IssueResponse{},
+			ReviewAuditTasks: []models.AuditTaskResponse{},
+			ReviewIssues:     []models.IssueResponse{},
+		}
+		byProject[projectID] = work
+		return work
+	}
+
+	for i := range tasks {
+		work := group(taskProjects[tasks[i].ID])
+		work.AuditTasks = append(work.AuditTasks, convertToAuditTaskResponse(&tasks[i]))
+		if auditTaskOverdue(&tasks[i], now) {
+			response.Overdue++
+		}
+	}
+	for i := range reviewTasks {
+		work := group(taskProjects[reviewTasks[i].ID])
+		work.ReviewAuditTasks = append(work.ReviewAuditTasks, convertToAuditTaskResponse(&reviewTasks[i]))
+	}
+	for i := range issues {
+		work := group(issueProjects[issues[i].ID])
+		work.Issues = append(work.Issues, convertToIssueResponse(&issues[i]))
+		if issueOverdue(&issues[i], now) {
+			response.Overdue++
+		}
+		if issues[i].EstimateHrs != nil {
+			response.EstimateHrs += *issues[i].EstimateHrs
+		}
+	}
+	for i := range reviewIssues {
+		work := group(issueProjects[reviewIssues[i].ID])
+		work.ReviewIssues = append(work.ReviewIssues, convertToIssueResponse(&reviewIssues[i]))
+	}
+
+	projectIDs := make([]uint, 0, len(byProject))
+	for id := range byProject {
+		projectIDs = append(projectIDs, id)
+	}
+	sort.Slice(projectIDs, func(i, j int) bool { return projectIDs[i] < projectIDs[j] })
+
+	var projects []db.Project
+	if len(projectIDs) > 0 {
+		if err := database.Find(&projects, projectIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	names := make(map[uint]string, len(projects))
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+	for _, id := range projectIDs {
+		work := byProject[id]
+		work.ProjectName = names[id]
+		response.Projects = append(response.Projects, *work)
+	}
+
+	return response, nil
+}
+
+// itemProjects maps audit task or issue IDs to the project each was raised
+// under.
+func itemProjects(database *db.Database, table string, ids []uint) (map[uint]uint, error) {
+	projects := make(map[uint]uint, len(ids))
+	if len(ids) == 0 {
+		return projects, nil
+	}
+
+	query := database.Table(table).Select(table+".id AS id, requirements.project_id AS project_id").
+		Where(table+".id IN ?", ids)
+	if table == "issues" {
+		query = query.Joins("JOIN audit_tasks ON audit_tasks.id = issues.audit_task_id")
+	}
+	query = query.Joins("JOIN requirements ON requirements.id = audit_tasks.requirement_id")
+
+	var rows []struct {
+		ID        uint
+		ProjectID uint
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		projects[row.ID] = row.ProjectID
+	}
+	return projects, nil
+}
+
+// projectWorkload breaks the project's open tasks and issues down by the
+// staff assigned to them.
+func projectWorkload(database *db.Database, project *db.Project, now time.Time) (*models.ProjectWorkloadResponse, error) {
+	var tasks []db.AuditTask
+	err := database.Joins("JOIN requirements ON requirements.id = audit_tasks.requirement_id").
+		Where("requirements.project_id = ? AND audit_tasks.status <> ?", project.ID, db.AuditTaskStatusReviewed).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []db.Issue
+	err = database.Where(projectIssues, project.ID, project.ID).
+		Where("status NOT IN ?", closedIssueStatuses).
+		Find(&issues).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[uint]*models.WorkloadEntry{}
+	entry := func(userID uint) *models.WorkloadEntry {
+		if e, ok := entries[userID]; ok {
+			return e
+		}
+		e := &models.WorkloadEntry{UserID: ptr(userID)}
+		entries[userID] = e
+		return e
+	}
+	for _, user := range project.Users {
+		if user.Role == db.RoleAdmin || user.Role == db.RoleConsultant {
+			entry(user.ID)
+		}
+	}
+
+	response := &models.ProjectWorkloadResponse{ProjectID: project.ID}
+	for i := range tasks {
+		task := &tasks[i]
+		e := &response.Unassigned
+		if task.AssigneeID != nil {
+			e = entry(*task.AssigneeID)
+		}
+		e.OpenAuditTasks++
+		if auditTaskOverdue(task, now) {
+			e.Overdue++
+		}
+		if task.ReviewerID != nil {
+			entry(*task.ReviewerID).ToReview++
+		}
+	}
+	for i := range issues {
+		issue := &issues[i]
+		e := &response.Unassigned
+		if issue.AssigneeID != nil {
+			e = entry(*issue.AssigneeID)
+		}
+		e.OpenIssues++
+		if issue.EstimateHrs != nil {
+			e.EstimateHrs += *issue.EstimateHrs
+		} else {
+			e.UnestimatedIssues++
+		}
+		if issueOverdue(issue, now) {
+			e.Overdue++
+		}
+		if issue.ReviewerID != nil {
+			entry(*issue.ReviewerID).ToReview++
+		}
+	}
+
+	userIDs := make([]uint, 0, len(entries))
+	for id := range entries {
+		userIDs = append(userIDs, id)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	var users []db.User
+	if len(userIDs) > 0 {
+		if err := database.Find(&users, userIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]*db.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	response.Members = make([]models.WorkloadEntry, 0, len(userIDs))
+	for _, id := range userIDs {
+		e := entries[id]
+		if user, ok := byID[id]; ok {
+			converted := convertToUserResponse(user)
+			e.User = &converted
+		}
+		response.Members = append(response.Members, *e)
+	}
+
+	return response, nil
+}
+
+// checkProjectStaff returns errNotProjectStaff unless the user is an admin
+// or a consultant assigned to the project.
+func checkProjectStaff(database *db.Database, projectID, userID uint) error {
+	var user db.User
+	err := database.First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: user %d not found", errNotProjectStaff, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role != db.RoleAdmin && user.Role != db.RoleConsultant {
+		return fmt.Errorf("%w: user %d is not an admin or consultant", errNotProjectStaff, userID)
+	}
+
+	allowed, err := canAccessProject(database, &user, projectID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%w: user %d is not assigned to the project", errNotProjectStaff, userID)
+	}
+	return nil
+}
+
+// checkAssignment validates the assignee and reviewer of a task or issue.
+// Only the people being newly assigned are checked, so that an item keeps
+// its assignee after they leave the project. It writes a 422 response when
+// the assignment is invalid.
+func checkAssignment(c *gin.Context, database *db.Database, projectID uint, assigneeID, reviewerID, oldAssigneeID, oldReviewerID *uint) bool {
+	if assigneeID != nil && reviewerID != nil && *assigneeID == *reviewerID {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "Invalid assignment",
+			Message: "the reviewer must be someone other than the assignee",
+			Code:    http.StatusUnprocessableEntity,
+		})
+		return false
+	}
+
+	for _, change := range []struct{ id, old *uint }{{assigneeID, oldAssigneeID}, {reviewerID, oldReviewerID}} {
+		if change.id == nil || sameID(change.id, change.old) {
+			continue
+		}
+		err := checkProjectStaff(database, projectID, *change.id)
+		if errors.Is(err, errNotProjectStaff) {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "Invalid assignment",
+				Message: err.Error(),
+				Code:    http.StatusUnprocessableEntity,
+			})
+			return false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Failed to check assignment",
+				Code:  http.StatusInternalServerError,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// sameID reports whether two optional IDs are both unset or equal.
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,122 @@
+// Package attest signs attestations, such as a project sign-off, with an
+// ed25519 key held by the server, and canonicalizes the data they cover so
+// that later changes can be detected.
+package attest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Signer holds the server's signing key.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner builds a signer from a 32-byte ed25519 seed.
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("attest: signing key must be a %d-byte seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+	return &Signer{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// FromEnv loads the signing key from SIGNING_KEY, a base64 encoded seed, or
+// else from the file at SIGNING_KEY_PATH ("signing.key" by default). A key
+// file that does not exist yet is generated.
+func FromEnv() (*Signer, error) {
+	if encoded := os.Getenv("SIGNING_KEY"); encoded != "" {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("attest: decode SIGNING_KEY: %w", err)
+		}
+		return NewSigner(seed)
+	}
+
+	path := os.Getenv("SIGNING_KEY_PATH")
+	if path == "" {
+		path = "signing.key"
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(seed) + "\n"
+		if err := os.WriteFile(path, []byte(encoded), 0o600); err != nil {
+			return nil, fmt.Errorf("attest: write %s: %w", path, err)
+		}
+		return NewSigner(seed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("attest: read %s: %w", path, err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("attest: decode %s: %w", path, err)
+	}
+	return NewSigner(seed)
+}
+
+// PublicKey returns the base64 encoded public key.
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// KeyID is a short fingerprint of the public key.
+func (s *Signer) KeyID() string {
+	sum := sha256.Sum256(s.key.Public().(ed25519.PublicKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign returns the base64 encoded signature of message.
+func (s *Signer) Sign(message []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, message))
+}
+
+// Verify reports whether signature is a valid signature of message by
+// publicKey, both base64 encoded.
+func Verify(publicKey, signature string, message []byte) bool {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(key), message, sig)
+}
+
+// Canonical encodes v as canonical JSON: object keys sorted, no insignificant
+// whitespace. Callers keep lists in a stable order.
+func Canonical(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Round-trip through generic values so keys are sorted whatever the
+	// field order of the structs
+	var generic interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// Digest returns the hex SHA-256 digest of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
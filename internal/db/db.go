@@ -31,24 +31,92 @@ func InitDB() *Database {
 
     log.Println("Database connected!")
 
-    // Auto-migrate schema for all models
-    err = DB.AutoMigrate(
+    schema := []interface{}{
         &User{},
         &Project{},
+        &ProjectUser{},
         &Requirement{},
         &AuditTask{},
         &Issue{},
         &Client{},
-    )
+        &Workflow{},
+        &StatusTransition{},
+        &IdempotencyRecord{},
+        &Framework{},
+        &FrameworkControl{},
+        &ControlMapping{},
+        &Evidence{},
+        &CustodyEvent{},
+        &DocumentRequest{},
+        &Remediation{},
+        &RemediationMilestone{},
+        &RemediationUpdate{},
+        &Retest{},
+        &SignOff{},
+        &ProjectTemplate{},
+    }
+
+    // Memberships carry the user's role on the project
+    for _, join := range []struct {
+        model interface{}
+        field string
+    }{{&Project{}, "Users"}, {&User{}, "Projects"}} {
+        if err := DB.SetupJoinTable(join.model, join.field, &ProjectUser{}); err != nil {
+            log.Fatalf("Failed to set up project memberships: %v", err)
+        }
+    }
+
+    // Bring legacy rows in line with the enum constraints before migrating.
+    // Stale constraints go first so rows can take newly added values.
+    if err := dropStaleCheckConstraints(DB.DB, schema...); err != nil {
+        log.Fatalf("Failed to refresh check constraints: %v", err)
+    }
+    if err := retireCompletedAuditTasks(DB.DB); err != nil {
+        log.Fatalf("Failed to retire completed audit tasks: %v", err)
+    }
+    if err := scopeIdempotencyKeys(DB.DB); err != nil {
+        log.Fatalf("Failed to scope idempotency keys: %v", err)
+    }
+    if err := migrateRequirementStatuses(DB.DB); err != nil {
+        log.Fatalf("Failed to migrate requirement statuses: %v", err)
+    }
+    if err := normalizeEnumColumns(DB.DB); err != nil {
+        log.Fatalf("Failed to normalize enum columns: %v", err)
+    }
+
+    // Issues were linked to a single requirement through their task before
+    // the link table existed
+    linkIssues := !DB.Migrator().HasTable("issue_requirements")
+    // Issues predating due dates follow the SLA policy
+    scheduleIssues := DB.Migrator().HasTable(&Issue{}) && !DB.Migrator().HasColumn(&Issue{}, "DueDateFromSLA")
+
+    // Auto-migrate schema for all models
+    err = DB.AutoMigrate(schema...)
     if err != nil {
         log.Fatalf("Failed to migrate database: %v", err)
     }
+    if err := moveEvidenceToClients(DB.DB); err != nil {
+        log.Fatalf("Failed to move evidence to client libraries: %v", err)
+    }
+    if err := backfillEvidenceCustody(DB.DB); err != nil {
+        log.Fatalf("Failed to backfill evidence custody: %v", err)
+    }
+    if linkIssues {
+        if err := linkIssuesToRequirements(DB.DB); err != nil {
+            log.Fatalf("Failed to link issues to requirements: %v", err)
+        }
+    }
+    if scheduleIssues {
+        if err := DB.Model(&Issue{}).Where("due_date IS NULL").UpdateColumn("due_date_from_sla", true).Error; err != nil {
+            log.Fatalf("Failed to schedule issues by SLA: %v", err)
+        }
+    }
 
     // Seed sample User data if DB is empty
     var userCount int64
     DB.Model(&User{}).Count(&userCount)
     if userCount == 0 {
-        DB.Create(&User{Name: "Alice", Email: "alice@example.com", Role: "CONSULTANT"})
+        DB.Create(&User{Name: "Alice", Email: "alice@example.com", Role: RoleConsultant})
     }
 
     // Seed sample Project data if DB is empty
@@ -70,7 +138,7 @@ func InitDB() *Database {
         DB.Create(&Requirement{
             Text:     "Must support single sign-on",
             Category: ptr("Authentication"),
-            Status:   "DRAFT",
+            Status:   RequirementStatusNotTested,
         })
     }
 
@@ -80,7 +148,7 @@ func InitDB() *Database {
         DB.Create(&AuditTask{
             Text:   "Check login audit",
             Notes:  ptr("Review all login-related requirements"),
-            Status: "PENDING",
+            Status: AuditTaskStatusPending,
         })
     }
 
@@ -90,6 +158,8 @@ func InitDB() *Database {
         DB.Create(&Issue{
             Title:       "Login fails on Safari",
             Description: ptr("Users report login page broken in Safari"),
+            Status:      IssueStatusOpen,
+            Type:        IssueTypeDefect,
         })
     }
     return DB
@@ -98,7 +168,8 @@ func InitDB() *Database {
 // Add custom query methods
 func (db *Database) GetRequirementsByClientID(clientID string) ([]Requirement, error) {
     var reqs []Requirement
-    err := db.Where("client_id = ?", clientID).Find(&reqs).Error
+    err := db.Joins("JOIN projects ON projects.id = requirements.project_id").
+        Where("projects.client_id = ?", clientID).Find(&reqs).Error
     return reqs, err
 }
 
@@ -108,10 +179,16 @@ func (db *Database) GetAllRequirements() ([]Requirement, error) {
     return reqs, err
 }
 
-// GetRequirementsByStatus returns all requirements with the given status.
+// GetRequirementsByStatus returns the requirements of a client's projects
+// with the given status, which may be spelled as ParseRequirementStatus
+// accepts.
 func (db *Database) GetRequirementsByStatus(clientID string, status string) ([]Requirement, error) {
     var reqs []Requirement
-    err := db.Where("client_id = ? AND status = ?", clientID, status).Find(&reqs).Error
+    if parsed, ok := ParseRequirementStatus(status); ok {
+        status = string(parsed)
+    }
+    err := db.Joins("JOIN projects ON projects.id = requirements.project_id").
+        Where("projects.client_id = ? AND requirements.status = ?", clientID, status).Find(&reqs).Error
     return reqs, err
 }
 
@@ -0,0 +1,189 @@
+package db
+
+import "strings"
+
+// Every enumerated column is listed here once so the binding validator, the
+// data migrations and the /meta/enums endpoint all agree on the allowed
+// values. The CHECK constraints in models.go must be kept in step.
+
+var Roles = []Role{RoleAdmin, RoleConsultant, RoleClient}
+
+var RequirementStatuses = []RequirementStatus{
+    RequirementStatusMet,
+    RequirementStatusNotMet,
+    RequirementStatusPartiallyMet,
+    RequirementStatusNotApplicable,
+    RequirementStatusNotTested,
+    RequirementStatusCompensatingControl,
+}
+
+var ProjectStatuses = []ProjectStatus{
+    ProjectStatusNew,
+    ProjectStatusInProgress,
+    ProjectStatusCompleted,
+    ProjectStatusArchived,
+}
+
+var AuditTaskStatuses = []AuditTaskStatus{
+    AuditTaskStatusPending,
+    AuditTaskStatusInProgress,
+    AuditTaskStatusPrepared,
+    AuditTaskStatusInReview,
+    AuditTaskStatusReviewed,
+}
+
+var ProjectRoles = []ProjectRole{
+    ProjectRoleMember,
+    ProjectRoleReviewer,
+    ProjectRoleLead,
+}
+
+var IssueStatuses = []IssueStatus{
+    IssueStatusOpen,
+    IssueStatusInProgress,
+    IssueStatusRemediationPlanned,
+    IssueStatusRemediated,
+    IssueStatusValidated,
+    IssueStatusResolved,
+    IssueStatusClosed,
+}
+
+var IssueTypes = []IssueType{
+    IssueTypeDefect,
+    IssueTypeFinding,
+    IssueTypeObservation,
+    IssueTypeRecommendation,
+}
+
+var IssuePriorities = []IssuePriority{
+    IssuePriorityLow,
+    IssuePriorityMedium,
+    IssuePriorityHigh,
+    IssuePriorityCritical,
+}
+
+var IssuePhases = []IssuePhase{
+    IssuePhasePlanning,
+    IssuePhaseFieldwork,
+    IssuePhaseReporting,
+    IssuePhaseRemediation,
+}
+
+var MappingStrengths = []MappingStrength{
+    MappingStrengthFull,
+    MappingStrengthPartial,
+}
+
+var CustodyActions = []CustodyAction{
+    CustodyActionUploaded,
+    CustodyActionViewed,
+    CustodyActionDownloaded,
+    CustodyActionSuperseded,
+    CustodyActionVerified,
+    CustodyActionLinked,
+    CustodyActionUnlinked,
+}
+
+var DocumentRequestStatuses = []DocumentRequestStatus{
+    DocumentRequestStatusRequested,
+    DocumentRequestStatusSubmitted,
+    DocumentRequestStatusAccepted,
+    DocumentRequestStatusRejected,
+}
+
+var ManagementAgreements = []ManagementAgreement{
+    ManagementAgreementAgree,
+    ManagementAgreementPartiallyAgree,
+    ManagementAgreementDisagree,
+}
+
+var RetestResults = []RetestResult{
+    RetestResultPassed,
+    RetestResultFailed,
+}
+
+var AuditTypes = []AuditType{
+    AuditTypeSOC2TypeI,
+    AuditTypeSOC2TypeII,
+    AuditTypeISO27001,
+    AuditTypePCIDSS,
+    AuditTypeHIPAA,
+    AuditTypeInternalAudit,
+    AuditTypeOther,
+}
+
+var SignOffRoles = []SignOffRole{
+    SignOffRoleLead,
+    SignOffRoleClientExecutive,
+}
+
+// Enums returns the allowed values of every enum, keyed by the name used in
+// `binding:"enum=<name>"` tags and exposed by GET /api/v1/meta/enums.
+func Enums() map[string][]string {
+    return map[string][]string{
+        "role":                  enumStrings(Roles),
+        "requirementStatus":     enumStrings(RequirementStatuses),
+        "projectStatus":         enumStrings(ProjectStatuses),
+        "auditTaskStatus":       enumStrings(AuditTaskStatuses),
+        "projectRole":           enumStrings(ProjectRoles),
+        "issueStatus":           enumStrings(IssueStatuses),
+        "issueType":             enumStrings(IssueTypes),
+        "issuePriority":         enumStrings(IssuePriorities),
+        "issuePhase":            enumStrings(IssuePhases),
+        "mappingStrength":       enumStrings(MappingStrengths),
+        "custodyAction":         enumStrings(CustodyActions),
+        "documentRequestStatus": enumStrings(DocumentRequestStatuses),
+        "managementAgreement":   enumStrings(ManagementAgreements),
+        "retestResult":          enumStrings(RetestResults),
+        "auditType":             enumStrings(AuditTypes),
+        "signOffRole":           enumStrings(SignOffRoles),
+    }
+}
+
+// IsEnumValue reports whether value is one of the allowed values of the named enum.
+func IsEnumValue(enum, value string) bool {
+    for _, v := range Enums()[enum] {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// requirementStatusAliases maps other spellings of assessment results, as
+// found in legacy rows and imported spreadsheets, to requirement statuses.
+var requirementStatusAliases = map[string]RequirementStatus{
+    "N/A":          RequirementStatusNotApplicable,
+    "NA":           RequirementStatusNotApplicable,
+    "PARTIAL":      RequirementStatusPartiallyMet,
+    "PARTLY_MET":   RequirementStatusPartiallyMet,
+    "UNTESTED":     RequirementStatusNotTested,
+    "DRAFT":        RequirementStatusNotTested,
+    "COMPENSATED":  RequirementStatusCompensatingControl,
+    "COMPENSATING": RequirementStatusCompensatingControl,
+}
+
+// ParseRequirementStatus reads a requirement status regardless of case,
+// spaces or hyphens ("Partially met", "not-tested"), also accepting the
+// aliases above. It reports false when raw names no status.
+func ParseRequirementStatus(raw string) (RequirementStatus, bool) {
+    key := strings.ToUpper(strings.TrimSpace(raw))
+    key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+    if status, ok := requirementStatusAliases[key]; ok {
+        return status, true
+    }
+    for _, status := range RequirementStatuses {
+        if string(status) == key {
+            return status, true
+        }
+    }
+    return "", false
+}
+
+func enumStrings[T ~string](values []T) []string {
+    out := make([]string, len(values))
+    for i, v := range values {
+        out[i] = string(v)
+    }
+    return out
+}
@@ -0,0 +1,354 @@
+package db
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "strings"
+
+    "tessellate-projects/internal/workflow"
+
+    "gorm.io/gorm"
+)
+
+// enumColumn ties a table column to the enum that governs it.
+type enumColumn struct {
+    table    string
+    column   string
+    enum     string
+    fallback *string // value for rows that cannot be salvaged; nil clears the column
+}
+
+var enumColumns = []enumColumn{
+    {"users", "role", "role", ptr(string(RoleClient))},
+    {"projects", "status", "projectStatus", ptr(string(ProjectStatusNew))},
+    {"projects", "audit_type", "auditType", nil},
+    {"project_templates", "audit_type", "auditType", nil},
+    {"requirements", "status", "requirementStatus", ptr(string(RequirementStatusNotTested))},
+    {"audit_tasks", "status", "auditTaskStatus", ptr(string(AuditTaskStatusPending))},
+    {"project_users", "role", "projectRole", ptr(string(ProjectRoleMember))},
+    {"issues", "status", "issueStatus", ptr(string(IssueStatusOpen))},
+    {"issues", "type", "issueType", ptr(string(IssueTypeDefect))},
+    {"issues", "priority", "issuePriority", nil},
+    {"issues", "phase", "issuePhase", nil},
+    {"issues", "inherent_rating", "issuePriority", nil},
+    {"issues", "residual_rating", "issuePriority", nil},
+    {"document_requests", "status", "documentRequestStatus", ptr(string(DocumentRequestStatusRequested))},
+    {"remediations", "agreement", "managementAgreement", ptr(string(ManagementAgreementAgree))},
+    {"retests", "result", "retestResult", ptr(string(RetestResultFailed))},
+    {"sign_offs", "role", "signOffRole", ptr(string(SignOffRoleLead))},
+}
+
+// normalizeEnumColumns rewrites legacy free-text values so existing rows
+// satisfy the enum CHECK constraints before AutoMigrate adds them. Values
+// that only differ in case or surrounding whitespace are kept; anything else
+// falls back to the column's default.
+func normalizeEnumColumns(db *gorm.DB) error {
+    for _, col := range enumColumns {
+        if !db.Migrator().HasColumn(col.table, col.column) {
+            continue
+        }
+        values := Enums()[col.enum]
+
+        if err := db.Exec(
+            fmt.Sprintf("UPDATE %s SET %s = UPPER(TRIM(%s)) WHERE %s NOT IN ? AND UPPER(TRIM(%s)) IN ?",
+                col.table, col.column, col.column, col.column, col.column),
+            values, values,
+        ).Error; err != nil {
+            return err
+        }
+
+        var err error
+        if col.fallback != nil {
+            err = db.Exec(
+                fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s IS NULL OR %s NOT IN ?", col.table, col.column, col.column, col.column),
+                *col.fallback, values,
+            ).Error
+        } else {
+            err = db.Exec(
+                fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s NOT IN ?", col.table, col.column, col.column),
+                values,
+            ).Error
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// dropStaleCheckConstraints removes CHECK constraints whose definition no
+// longer matches the model tags, so AutoMigrate recreates them with the
+// current set of allowed values.
+func dropStaleCheckConstraints(db *gorm.DB, models ...interface{}) error {
+    for _, model := range models {
+        stmt := &gorm.Statement{DB: db}
+        if err := stmt.Parse(model); err != nil {
+            return err
+        }
+        if !db.Migrator().HasTable(stmt.Schema.Table) {
+            continue
+        }
+
+        var ddl string
+        if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = ? AND tbl_name = ?", "table", stmt.Schema.Table).Scan(&ddl).Error; err != nil {
+            return err
+        }
+
+        for name, chk := range stmt.Schema.ParseCheckConstraints() {
+            if !db.Migrator().HasConstraint(model, name) || strings.Contains(ddl, "CHECK ("+chk.Constraint+")") {
+                continue
+            }
+            if err := db.Migrator().DropConstraint(model, name); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// backfillEvidenceCustody starts a custody chain for evidence uploaded before
+// custody tracking existed, so every item can be verified. The uploader of
+// those files was not recorded, so the event has no user.
+func backfillEvidenceCustody(db *gorm.DB) error {
+    var evidence []Evidence
+    if err := db.Where("NOT EXISTS (SELECT 1 FROM custody_events WHERE custody_events.evidence_id = evidences.id)").
+        Find(&evidence).Error; err != nil {
+        return err
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        for i := range evidence {
+            details := "Recorded when custody tracking was enabled; uploader unknown"
+            if _, err := AppendCustodyEvent(tx, &evidence[i], CustodyActionUploaded, nil, &details); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// moveEvidenceToClients turns evidence that was attached to a single audit
+// task into library evidence: the task becomes the first link and the
+// evidence is filed under the client of the task's project. Evidence whose
+// task has no client keeps client 0, which only admins can see.
+func moveEvidenceToClients(db *gorm.DB) error {
+    if !db.Migrator().HasColumn("evidences", "audit_task_id") {
+        return nil
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`INSERT INTO audit_task_evidence (audit_task_id, evidence_id)
+            SELECT audit_task_id, id FROM evidences
+            WHERE audit_task_id IS NOT NULL AND audit_task_id <> 0
+            AND audit_task_id IN (SELECT id FROM audit_tasks)
+            AND NOT EXISTS (SELECT 1 FROM audit_task_evidence link
+                WHERE link.audit_task_id = evidences.audit_task_id AND link.evidence_id = evidences.id)`).Error; err != nil {
+            return err
+        }
+        if err := tx.Exec(`UPDATE evidences SET client_id = COALESCE((
+            SELECT projects.client_id FROM audit_tasks
+            JOIN requirements ON requirements.id = audit_tasks.requirement_id
+            JOIN projects ON projects.id = requirements.project_id
+            WHERE audit_tasks.id = evidences.audit_task_id), 0)
+            WHERE client_id IS NULL OR client_id = 0`).Error; err != nil {
+            return err
+        }
+        if tx.Migrator().HasConstraint("evidences", "fk_audit_tasks_evidence") {
+            if err := tx.Migrator().DropConstraint("evidences", "fk_audit_tasks_evidence"); err != nil {
+                return err
+            }
+        }
+        if tx.Migrator().HasIndex("evidences", "idx_evidences_audit_task_id") {
+            if err := tx.Migrator().DropIndex("evidences", "idx_evidences_audit_task_id"); err != nil {
+                return err
+            }
+        }
+        if err := tx.Migrator().DropColumn(&Evidence{}, "audit_task_id"); err != nil {
+            return err
+        }
+        // SQLite drops columns by rebuilding the table, which loses its indexes
+        return tx.AutoMigrate(&Evidence{})
+    })
+}
+
+// scopeIdempotencyKeys drops the index that made idempotency keys unique
+// across all users. Stored responses from before keys were scoped have no
+// owner, so they are discarded rather than replayed to whoever sends the key.
+func scopeIdempotencyKeys(db *gorm.DB) error {
+    if !db.Migrator().HasIndex("idempotency_records", "idx_idempotency_records_key") {
+        return nil
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec("DELETE FROM idempotency_records").Error; err != nil {
+            return err
+        }
+        return tx.Migrator().DropIndex("idempotency_records", "idx_idempotency_records_key")
+    })
+}
+
+// linkIssuesToRequirements links every issue to the requirement of the audit
+// task it was found on, and drops the foreign key of the old one issue per
+// task relationship. It runs once, when the issue_requirements table is first
+// created, so links removed later are not restored.
+func linkIssuesToRequirements(db *gorm.DB) error {
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`INSERT INTO issue_requirements (issue_id, requirement_id)
+            SELECT issues.id, audit_tasks.requirement_id FROM issues
+            JOIN audit_tasks ON audit_tasks.id = issues.audit_task_id
+            WHERE audit_tasks.requirement_id IN (SELECT id FROM requirements)`).Error; err != nil {
+            return err
+        }
+        if !tx.Migrator().HasConstraint("issues", "fk_audit_tasks_issue") {
+            return nil
+        }
+        if err := tx.Migrator().DropConstraint("issues", "fk_audit_tasks_issue"); err != nil {
+            return err
+        }
+        // SQLite drops constraints by rebuilding the table, which loses its indexes
+        return tx.AutoMigrate(&Issue{})
+    })
+}
+
+// migrateRequirementStatuses rewrites requirement statuses written in
+// another spelling, such as "Partially met" or "N/A", to the status they
+// name. normalizeEnumColumns would otherwise reset them to NOT_TESTED.
+// Sections are not assessed, so any result stored on one is reset as well.
+func migrateRequirementStatuses(db *gorm.DB) error {
+    if !db.Migrator().HasTable("requirements") {
+        return nil
+    }
+
+    var raw []string
+    if err := db.Table("requirements").Where("status NOT IN ?", enumStrings(RequirementStatuses)).
+        Distinct().Pluck("status", &raw).Error; err != nil {
+        return err
+    }
+    for _, value := range raw {
+        status, ok := ParseRequirementStatus(value)
+        if !ok {
+            continue
+        }
+        if err := db.Exec("UPDATE requirements SET status = ? WHERE status = ?", status, value).Error; err != nil {
+            return err
+        }
+    }
+    if db.Migrator().HasColumn("requirements", "section") {
+        return db.Exec("UPDATE requirements SET status = ? WHERE section AND status <> ?",
+            RequirementStatusNotTested, RequirementStatusNotTested).Error
+    }
+    return nil
+}
+
+// retireCompletedAuditTasks moves tasks that were COMPLETED before four-eyes
+// review existed to PREPARED, so their testing is reviewed before it counts.
+// Audit task workflow overrides built around COMPLETED are rewritten to end
+// in REVIEWED instead. An override that cannot be rewritten stops startup
+// with an error rather than being dropped.
+func retireCompletedAuditTasks(db *gorm.DB) error {
+    if !db.Migrator().HasTable("audit_tasks") {
+        return nil
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec("UPDATE audit_tasks SET status = ? WHERE status = ?",
+            AuditTaskStatusPrepared, "COMPLETED").Error; err != nil {
+            return err
+        }
+        if !tx.Migrator().HasTable(&Workflow{}) {
+            return nil
+        }
+
+        var stored []Workflow
+        if err := tx.Where("entity_type = ?", "auditTask").Find(&stored).Error; err != nil {
+            return err
+        }
+        for _, wf := range stored {
+            var definition workflow.Definition
+            if err := json.Unmarshal([]byte(wf.Definition), &definition); err != nil {
+                return fmt.Errorf("audit task workflow %d is not valid JSON: %w", wf.ID, err)
+            }
+            if !retireCompleted(&definition) {
+                continue
+            }
+            if err := definition.Validate(enumStrings(AuditTaskStatuses), enumStrings(Roles)); err != nil {
+                return fmt.Errorf("audit task workflow %d uses the retired COMPLETED status and cannot be rewritten to REVIEWED (%w); fix or delete it, then restart", wf.ID, err)
+            }
+
+            encoded, err := json.Marshal(definition)
+            if err != nil {
+                return err
+            }
+            if err := tx.Model(&wf).Update("definition", string(encoded)).Error; err != nil {
+                return err
+            }
+            log.Printf("Rewrote audit task workflow %d to use REVIEWED in place of the retired COMPLETED status", wf.ID)
+        }
+        return nil
+    })
+}
+
+// retireCompleted renames the COMPLETED state of an audit task workflow to
+// REVIEWED, reporting whether the workflow used it. Tasks that were
+// COMPLETED are now PREPARED, so a workflow without that state gains it,
+// with transitions on to REVIEWED and back to IN_PROGRESS.
+func retireCompleted(d *workflow.Definition) bool {
+    const completed, reviewed = "COMPLETED", string(AuditTaskStatusReviewed)
+    rename := func(state string) string {
+        if state == completed {
+            return reviewed
+        }
+        return state
+    }
+    renameAll := func(states []string) ([]string, bool) {
+        var out []string
+        found := false
+        for _, state := range states {
+            found = found || state == completed
+            if state = rename(state); !containsString(out, state) {
+                out = append(out, state)
+            }
+        }
+        return out, found
+    }
+
+    var usedState, usedInitial bool
+    d.States, usedState = renameAll(d.States)
+    d.InitialStates, usedInitial = renameAll(d.InitialStates)
+
+    var reviewRoles []string
+    seen := map[string]bool{}
+    transitions := d.Transitions[:0]
+    used := usedState || usedInitial
+    for _, t := range d.Transitions {
+        if t.To == completed && reviewRoles == nil {
+            reviewRoles = t.Roles
+        }
+        used = used || t.From == completed || t.To == completed
+        t.From, t.To = rename(t.From), rename(t.To)
+        if key := t.From + "->" + t.To; t.From != t.To && !seen[key] {
+            seen[key] = true
+            transitions = append(transitions, t)
+        }
+    }
+    d.Transitions = transitions
+    if !used {
+        return false
+    }
+
+    prepared, inProgress := string(AuditTaskStatusPrepared), string(AuditTaskStatusInProgress)
+    if !containsString(d.States, prepared) {
+        d.States = append(d.States, prepared)
+        d.Transitions = append(d.Transitions, workflow.Transition{From: prepared, To: reviewed, Roles: reviewRoles})
+        if containsString(d.States, inProgress) {
+            d.Transitions = append(d.Transitions, workflow.Transition{From: prepared, To: inProgress, Roles: reviewRoles})
+        }
+    }
+    return true
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -12,11 +14,150 @@ const (
     RoleClient     Role = "CLIENT"
 )
 
+// RequirementStatus is the result of assessing a requirement. A
+// requirement that does not apply needs a justification, and one met by a
+// compensating control a description of it, in its rationale.
 type RequirementStatus string
 
 const (
-	RequirementStatusMet    RequirementStatus = "MET"
-	RequirementStatusNotMet RequirementStatus = "NOT_MET"
+	RequirementStatusMet                 RequirementStatus = "MET"
+	RequirementStatusNotMet              RequirementStatus = "NOT_MET"
+	RequirementStatusPartiallyMet        RequirementStatus = "PARTIALLY_MET"
+	RequirementStatusNotApplicable       RequirementStatus = "NOT_APPLICABLE"
+	RequirementStatusNotTested           RequirementStatus = "NOT_TESTED"
+	RequirementStatusCompensatingControl RequirementStatus = "COMPENSATING_CONTROL"
+)
+
+type ProjectStatus string
+
+const (
+    ProjectStatusNew        ProjectStatus = "NEW"
+    ProjectStatusInProgress ProjectStatus = "IN_PROGRESS"
+    ProjectStatusCompleted  ProjectStatus = "COMPLETED"
+    ProjectStatusArchived   ProjectStatus = "ARCHIVED"
+)
+
+type AuditTaskStatus string
+
+const (
+    AuditTaskStatusPending    AuditTaskStatus = "PENDING"
+    AuditTaskStatusInProgress AuditTaskStatus = "IN_PROGRESS"
+    AuditTaskStatusPrepared   AuditTaskStatus = "PREPARED"
+    AuditTaskStatusInReview   AuditTaskStatus = "IN_REVIEW"
+    AuditTaskStatusReviewed   AuditTaskStatus = "REVIEWED"
+)
+
+// ProjectRole is what a user does on a project they are a member of.
+type ProjectRole string
+
+const (
+    ProjectRoleMember   ProjectRole = "MEMBER"
+    ProjectRoleReviewer ProjectRole = "REVIEWER"
+    ProjectRoleLead     ProjectRole = "LEAD"
+)
+
+type IssueStatus string
+
+const (
+    IssueStatusOpen               IssueStatus = "OPEN"
+    IssueStatusInProgress         IssueStatus = "IN_PROGRESS"
+    IssueStatusRemediationPlanned IssueStatus = "REMEDIATION_PLANNED"
+    IssueStatusRemediated         IssueStatus = "REMEDIATED"
+    IssueStatusValidated          IssueStatus = "VALIDATED"
+    IssueStatusResolved           IssueStatus = "RESOLVED"
+    IssueStatusClosed             IssueStatus = "CLOSED"
+)
+
+type IssueType string
+
+const (
+    IssueTypeDefect         IssueType = "DEFECT"
+    IssueTypeFinding        IssueType = "FINDING"
+    IssueTypeObservation    IssueType = "OBSERVATION"
+    IssueTypeRecommendation IssueType = "RECOMMENDATION"
+)
+
+type IssuePriority string
+
+const (
+    IssuePriorityLow      IssuePriority = "LOW"
+    IssuePriorityMedium   IssuePriority = "MEDIUM"
+    IssuePriorityHigh     IssuePriority = "HIGH"
+    IssuePriorityCritical IssuePriority = "CRITICAL"
+)
+
+type IssuePhase string
+
+const (
+    IssuePhasePlanning    IssuePhase = "PLANNING"
+    IssuePhaseFieldwork   IssuePhase = "FIELDWORK"
+    IssuePhaseReporting   IssuePhase = "REPORTING"
+    IssuePhaseRemediation IssuePhase = "REMEDIATION"
+)
+
+type MappingStrength string
+
+const (
+    MappingStrengthFull    MappingStrength = "FULL"
+    MappingStrengthPartial MappingStrength = "PARTIAL"
+)
+
+type CustodyAction string
+
+const (
+    CustodyActionUploaded   CustodyAction = "UPLOADED"
+    CustodyActionViewed     CustodyAction = "VIEWED"
+    CustodyActionDownloaded CustodyAction = "DOWNLOADED"
+    CustodyActionSuperseded CustodyAction = "SUPERSEDED"
+    CustodyActionVerified   CustodyAction = "VERIFIED"
+    CustodyActionLinked     CustodyAction = "LINKED"
+    CustodyActionUnlinked   CustodyAction = "UNLINKED"
+)
+
+type DocumentRequestStatus string
+
+const (
+    DocumentRequestStatusRequested DocumentRequestStatus = "REQUESTED"
+    DocumentRequestStatusSubmitted DocumentRequestStatus = "SUBMITTED"
+    DocumentRequestStatusAccepted  DocumentRequestStatus = "ACCEPTED"
+    DocumentRequestStatusRejected  DocumentRequestStatus = "REJECTED"
+)
+
+// ManagementAgreement is the client's position on an issue.
+type ManagementAgreement string
+
+const (
+    ManagementAgreementAgree          ManagementAgreement = "AGREE"
+    ManagementAgreementPartiallyAgree ManagementAgreement = "PARTIALLY_AGREE"
+    ManagementAgreementDisagree       ManagementAgreement = "DISAGREE"
+)
+
+type RetestResult string
+
+const (
+    RetestResultPassed RetestResult = "PASSED"
+    RetestResultFailed RetestResult = "FAILED"
+)
+
+// AuditType is the kind of engagement a project is.
+type AuditType string
+
+const (
+    AuditTypeSOC2TypeI     AuditType = "SOC2_TYPE_I"
+    AuditTypeSOC2TypeII    AuditType = "SOC2_TYPE_II"
+    AuditTypeISO27001      AuditType = "ISO_27001"
+    AuditTypePCIDSS        AuditType = "PCI_DSS"
+    AuditTypeHIPAA         AuditType = "HIPAA"
+    AuditTypeInternalAudit AuditType = "INTERNAL_AUDIT"
+    AuditTypeOther         AuditType = "OTHER"
+)
+
+// SignOffRole is the capacity in which someone signs off a project.
+type SignOffRole string
+
+const (
+    SignOffRoleLead            SignOffRole = "LEAD"
+    SignOffRoleClientExecutive SignOffRole = "CLIENT_EXECUTIVE"
 )
 
 type User struct {
@@ -24,7 +165,7 @@ type User struct {
     Name     string
     Email    string
     Password string
-    Role     Role
+    Role     Role `gorm:"check:chk_users_role,role IN ('ADMIN','CONSULTANT','CLIENT')"`
     Projects []*Project `gorm:"many2many:project_users"`
     ClientID *uint
     Client   *Client
@@ -34,21 +175,60 @@ type Project struct {
     gorm.Model
     Name         string
     ClientName   string
-    Status       string `gorm:"type:VARCHAR(20);default:'NEW'"`
+    Status       ProjectStatus `gorm:"type:VARCHAR(20);default:'NEW';check:chk_projects_status,status IN ('NEW','IN_PROGRESS','COMPLETED','ARCHIVED')"`
     Users        []*User `gorm:"many2many:project_users"`
     ClientID     *uint
     Client       *Client
     Requirements []*Requirement
+    LockedAt     *time.Time // set by the first sign-off; the project is read-only from then on
+    RolledFromID *uint `gorm:"index"` // the prior period's project this one was rolled forward from
+    RolledFrom   *Project
+    AuditType    *AuditType `gorm:"type:VARCHAR(20);check:chk_projects_audit_type,audit_type IN ('SOC2_TYPE_I','SOC2_TYPE_II','ISO_27001','PCI_DSS','HIPAA','INTERNAL_AUDIT','OTHER')"`
+
+    // The period under audit, when fieldwork was planned and actually took
+    // place, and when the report is due
+    PeriodStart           *time.Time
+    PeriodEnd             *time.Time
+    PlannedFieldworkStart *time.Time
+    PlannedFieldworkEnd   *time.Time
+    FieldworkStart        *time.Time
+    FieldworkEnd          *time.Time
+    ReportDueDate         *time.Time `gorm:"index"`
+
+    TemplateID *uint `gorm:"index"` // the project template it was set up from
+    Template   *ProjectTemplate
+}
+
+// ProjectUser is a user's membership of a project. Only reviewers and leads
+// may review audit tasks.
+type ProjectUser struct {
+    ProjectID uint        `gorm:"primaryKey"`
+    UserID    uint        `gorm:"primaryKey"`
+    Role      ProjectRole `gorm:"default:'MEMBER';check:chk_project_users_role,role IN ('MEMBER','REVIEWER','LEAD')"`
 }
 
+// Requirement is a criterion a project is assessed against. Requirements
+// nest, e.g. domain, control and sub-control, and are ordered by Position
+// among their siblings. Sections are headings that group requirements and
+// are not assessed themselves.
 type Requirement struct {
     gorm.Model
-    ProjectID  uint
-    Project    *Project
-    Text       string
-    Category   *string
-    Status     RequirementStatus
-    AuditTasks []*AuditTask
+    ProjectID          uint
+    Project            *Project
+    Text               string
+    Category           *string
+    Status             RequirementStatus `gorm:"check:chk_requirements_status,status IN ('MET','NOT_MET','PARTIALLY_MET','NOT_APPLICABLE','NOT_TESTED','COMPENSATING_CONTROL')"`
+    Rationale          *string // why the requirement was assessed as it was
+    AuditTasks         []*AuditTask
+    Issues             []*Issue `gorm:"many2many:issue_requirements"`
+    FrameworkControlID *uint    `gorm:"index"` // set when created from the framework catalog
+    FrameworkControl   *FrameworkControl
+    Ref                *string // the requirement's own identifier, e.g. CC6.1
+    ParentID           *uint   `gorm:"index"`
+    Parent             *Requirement
+    Children           []*Requirement `gorm:"foreignKey:ParentID"`
+    Position           int            `gorm:"not null;default:0"`
+    Section            bool           `gorm:"not null;default:false"`
 }
 
 type AuditTask struct {
@@ -56,22 +236,145 @@ type AuditTask struct {
     RequirementID uint
     Requirement   *Requirement
     Text          string
-    Status        string
+    Status        AuditTaskStatus `gorm:"check:chk_audit_tasks_status,status IN ('PENDING','IN_PROGRESS','PREPARED','IN_REVIEW','REVIEWED')"`
     Notes         *string
-    Issue         *Issue
+    DueDate       *time.Time
+    AssigneeID    *uint `gorm:"index"`
+    Assignee      *User
+    ReviewerID    *uint `gorm:"index"`
+    Reviewer      *User
+    Issues        []*Issue
+    Evidence      []*Evidence `gorm:"many2many:audit_task_evidence"`
+
+    // Four-eyes review: who prepared the testing and who signed it off
+    PreparedByID *uint
+    PreparedBy   *User
+    PreparedAt   *time.Time
+    ReviewedByID *uint
+    ReviewedBy   *User
+    ReviewedAt   *time.Time
+    ReviewNotes  *string
 }
 
+// Issue is a problem found while performing an audit task. The task is where
+// it was found; Requirements lists every requirement it causes to fail, which
+// may span several of the project's requirements.
 type Issue struct {
     gorm.Model
-    AuditTaskID  uint
-    AuditTask    *AuditTask
-    Title        string
-    Description  *string
-    Priority     *string
-    Phase        *string
-    EstimateHrs  *int
-    Status       string
-    Type         string
+    AuditTaskID    uint `gorm:"index"`
+    AuditTask      *AuditTask
+    Requirements   []*Requirement `gorm:"many2many:issue_requirements"`
+    Title          string
+    Description    *string
+    Priority       *IssuePriority `gorm:"check:chk_issues_priority,priority IN ('LOW','MEDIUM','HIGH','CRITICAL')"`
+    Phase          *IssuePhase    `gorm:"check:chk_issues_phase,phase IN ('PLANNING','FIELDWORK','REPORTING','REMEDIATION')"`
+    EstimateHrs    *int
+    Status         IssueStatus `gorm:"check:chk_issues_status,status IN ('OPEN','IN_PROGRESS','REMEDIATION_PLANNED','REMEDIATED','VALIDATED','RESOLVED','CLOSED')"`
+    Type           IssueType   `gorm:"check:chk_issues_type,type IN ('DEFECT','FINDING','OBSERVATION','RECOMMENDATION')"`
+    ResolutionNote *string
+
+    // DueDate is set by hand, or derived from the client's SLA policy for the
+    // issue's priority while DueDateFromSLA is set
+    DueDate        *time.Time
+    DueDateFromSLA bool
+
+    // Staff working on the issue and checking the work
+    AssigneeID *uint `gorm:"index"`
+    Assignee   *User
+    ReviewerID *uint `gorm:"index"`
+    Reviewer   *User
+
+    // Risk scores on the client's risk matrix. Inherent risk ignores the
+    // compensating controls, residual risk takes them into account. Ratings
+    // are derived from the scores, and Priority from the residual rating, or
+    // the inherent one while no residual score is set.
+    InherentLikelihood   *int
+    InherentImpact       *int
+    InherentRating       *IssuePriority `gorm:"check:chk_issues_inherent_rating,inherent_rating IN ('LOW','MEDIUM','HIGH','CRITICAL')"`
+    ResidualLikelihood   *int
+    ResidualImpact       *int
+    ResidualRating       *IssuePriority `gorm:"check:chk_issues_residual_rating,residual_rating IN ('LOW','MEDIUM','HIGH','CRITICAL')"`
+    CompensatingControls *string
+
+    Remediation *Remediation
+    Retests     []*Retest
+
+    // A prior-period finding carried forward by a roll-forward points back
+    // at the issue it was carried from
+    CarriedFromID *uint `gorm:"index"`
+    CarriedFrom   *Issue
+}
+
+// Remediation is the management response to an issue: whether the client
+// agrees, what it will do about it, who owns the fix and by when. Progress is
+// tracked through milestones and updates.
+type Remediation struct {
+    gorm.Model
+    IssueID            uint `gorm:"uniqueIndex"`
+    Issue              *Issue
+    Agreement          ManagementAgreement `gorm:"type:VARCHAR(20);check:chk_remediations_agreement,agreement IN ('AGREE','PARTIALLY_AGREE','DISAGREE')"`
+    ManagementResponse string
+    ActionPlan         *string
+    OwnerID            *uint // a CLIENT user of the project's client
+    Owner              *User
+    TargetDate         *time.Time
+    RespondedByID      uint
+    RespondedBy        *User
+    Milestones         []*RemediationMilestone
+    Updates            []*RemediationUpdate
+}
+
+type RemediationMilestone struct {
+    gorm.Model
+    RemediationID uint `gorm:"index"`
+    Title         string
+    DueDate       *time.Time
+    CompletedAt   *time.Time
+}
+
+// RemediationUpdate is a progress report on a remediation. Updates are
+// append-only.
+type RemediationUpdate struct {
+    gorm.Model
+    RemediationID   uint `gorm:"index"`
+    AuthorID        uint
+    Author          *User
+    Note            string
+    PercentComplete *int
+}
+
+// Retest records a consultant re-testing an issue after remediation, with
+// the results filed as evidence. A passing retest validates the fix.
+type Retest struct {
+    gorm.Model
+    IssueID       uint `gorm:"index"`
+    Issue         *Issue
+    Result        RetestResult `gorm:"type:VARCHAR(20);check:chk_retests_result,result IN ('PASSED','FAILED')"`
+    Notes         *string
+    EvidenceID    uint
+    Evidence      *Evidence
+    PerformedByID uint
+    PerformedBy   *User
+}
+
+// SignOff is a signed attestation that a project is complete. The signature
+// covers the signer, role, statement and time, and the digest of a canonical
+// snapshot of the project's requirements, tasks and issues. The snapshot is
+// kept so that later changes can be pinpointed.
+type SignOff struct {
+    gorm.Model
+    ProjectID      uint `gorm:"index"`
+    Project        *Project
+    SignerID       uint
+    Signer         *User
+    Role           SignOffRole `gorm:"type:VARCHAR(20);check:chk_sign_offs_role,role IN ('LEAD','CLIENT_EXECUTIVE')"`
+    Statement      string
+    SignedAt       time.Time
+    Snapshot       string // canonical JSON
+    SnapshotDigest string // hex SHA-256 of Snapshot
+    KeyID          string
+    PublicKey      string // base64 ed25519 public key
+    Signature      string // base64 ed25519 signature of the signed payload
 }
 
 type Client struct {
@@ -82,6 +385,166 @@ type Client struct {
     ContactEmail *string
     Users        []*User
     Projects     []*Project
+    RiskMatrix   *string // JSON encoded risk.Matrix; nil uses risk.Default
+    SLAPolicy    *string // JSON encoded days to fix an issue, by priority; nil sets no due dates
+}
+
+// Evidence is a file collected during an audit, such as a screenshot or a
+// configuration export. It belongs to a client's evidence library and can
+// support any number of audit tasks across that client's projects. The
+// content lives in object storage under StorageKey; SHA256 is the hex digest
+// of the content as uploaded.
+type Evidence struct {
+    gorm.Model
+    ClientID      uint `gorm:"index"`
+    Client        *Client
+    AuditTasks    []*AuditTask `gorm:"many2many:audit_task_evidence"`
+    FileName      string
+    ContentType   string
+    Size          int64
+    SHA256        string
+    StorageKey    string
+    CollectedByID uint
+    CollectedBy   *User
+    CollectedAt   time.Time
+    Source        *string // where the evidence came from, e.g. a system or a URL
+    // The period the evidence speaks for, e.g. the quarter an access review
+    // covers. Evidence is stale once ExpiresOn has passed
+    ValidFrom     *time.Time
+    ExpiresOn     *time.Time `gorm:"index"`
+    // Evidence is write-once: a changed file is uploaded as a new version
+    // pointing back at the one it supersedes
+    Version           int         `gorm:"default:1"`
+    PreviousVersionID *uint       `gorm:"uniqueIndex"` // a version is superseded at most once
+    Successors        []*Evidence `gorm:"foreignKey:PreviousVersionID"` // the superseding version, if any
+    CustodyEvents     []*CustodyEvent
+}
+
+// CustodyEvent is an entry in an evidence item's append-only chain of
+// custody. Each event's Hash covers its own fields and the previous event's
+// hash; the first event chains from the evidence content hash, so altering
+// either the log or the recorded content breaks the chain.
+type CustodyEvent struct {
+    ID           uint          `gorm:"primarykey"`
+    EvidenceID   uint          `gorm:"uniqueIndex:idx_custody_events_sequence"`
+    Sequence     int           `gorm:"uniqueIndex:idx_custody_events_sequence"`
+    Action       CustodyAction `gorm:"check:chk_custody_events_action,action IN ('UPLOADED','VIEWED','DOWNLOADED','SUPERSEDED','VERIFIED','LINKED','UNLINKED')"`
+    UserID       *uint
+    User         *User
+    OccurredAt   time.Time
+    Details      *string
+    PreviousHash string
+    Hash         string
+}
+
+// DocumentRequest is a prepared-by-client (PBC) item: a document the audit
+// team needs from the client for a project. Client users answer it by
+// uploading files, which are filed as evidence in the client's library.
+type DocumentRequest struct {
+    gorm.Model
+    ProjectID     uint `gorm:"index"`
+    Project       *Project
+    Description   string
+    Requirements  []*Requirement `gorm:"many2many:document_request_requirements"`
+    AssigneeID    *uint // a CLIENT user of the project's client
+    Assignee      *User
+    RequestedByID uint
+    RequestedBy   *User
+    DueDate       *time.Time
+    Status        DocumentRequestStatus `gorm:"type:VARCHAR(20);default:'REQUESTED';check:chk_document_requests_status,status IN ('REQUESTED','SUBMITTED','ACCEPTED','REJECTED')"`
+    SubmittedAt   *time.Time
+    ReviewNote    *string // why the last submission was rejected
+    Evidence      []*Evidence `gorm:"many2many:document_request_evidence"`
+}
+
+// ProjectTemplate is one version of a house template for setting up
+// engagements. Versions are never changed: saving a template under an
+// existing name adds its next version.
+type ProjectTemplate struct {
+    gorm.Model
+    Name            string `gorm:"index:idx_project_templates_name_version"`
+    Version         int    `gorm:"index:idx_project_templates_name_version"`
+    Description     *string
+    AuditType       *AuditType `gorm:"type:VARCHAR(20);check:chk_project_templates_audit_type,audit_type IN ('SOC2_TYPE_I','SOC2_TYPE_II','ISO_27001','PCI_DSS','HIPAA','INTERNAL_AUDIT','OTHER')"`
+    ProjectName     string // name of the projects it sets up, may contain {{variables}}
+    Content         string // JSON encoded requirements, audit tasks, team and document requests
+    SourceProjectID *uint  // set when it was saved from a project
+    SourceProject   *Project
+    CreatedByID     uint
+    CreatedBy       *User
+}
+
+// Framework is a versioned compliance framework in the control catalog,
+// e.g. SOC 2 2017 or ISO 27001 2022.
+type Framework struct {
+    gorm.Model
+    Name        string `gorm:"index:idx_frameworks_name_version"`
+    Version     string `gorm:"index:idx_frameworks_name_version"`
+    Description *string
+    Controls    []*FrameworkControl
+}
+
+// FrameworkControl is a single control of a framework. ControlID is the
+// framework's own identifier, e.g. CC6.1 or A.5.15.
+type FrameworkControl struct {
+    gorm.Model
+    FrameworkID uint `gorm:"index"`
+    Framework   *Framework
+    ControlID   string
+    Title       string
+    Description *string
+    Category    *string
+}
+
+// ControlMapping links two controls from different frameworks that are
+// satisfied by the same work. Mappings apply in both directions.
+type ControlMapping struct {
+    gorm.Model
+    SourceControlID uint `gorm:"index"`
+    SourceControl   *FrameworkControl
+    TargetControlID uint `gorm:"index"`
+    TargetControl   *FrameworkControl
+    Strength        MappingStrength `gorm:"check:chk_control_mappings_strength,strength IN ('FULL','PARTIAL')"`
+}
+
+// Workflow stores a status workflow override for an entity type, either for
+// a single project or, when ProjectID is nil, for the whole installation.
+type Workflow struct {
+    gorm.Model
+    ProjectID  *uint  `gorm:"index:idx_workflows_scope"`
+    EntityType string `gorm:"index:idx_workflows_scope"`
+    Definition string // JSON encoded workflow.Definition
+}
+
+// StatusTransition records a status change made through a workflow.
+type StatusTransition struct {
+    gorm.Model
+    EntityType string `gorm:"index:idx_status_transitions_entity"`
+    EntityID   uint   `gorm:"index:idx_status_transitions_entity"`
+    ProjectID  uint
+    FromStatus string
+    ToStatus   string
+    UserID     *uint
+    User       *User
+    Fields     *string // JSON encoded transition fields, e.g. a reason
+}
+
+// IdempotencyRecord remembers the response to a POST sent with an
+// Idempotency-Key header so retries can be replayed. Rows are removed
+// outright once they expire, so it does not use soft deletes. Keys are
+// scoped to the user who sent them; UserID is 0 for unauthenticated requests.
+type IdempotencyRecord struct {
+    ID           uint   `gorm:"primarykey"`
+    UserID       uint   `gorm:"uniqueIndex:idx_idempotency_user_key"`
+    Key          string `gorm:"uniqueIndex:idx_idempotency_user_key"`
+    Method       string
+    Path         string
+    RequestHash  string
+    StatusCode   int // 0 while the original request is still running
+    ContentType  string
+    ResponseBody []byte
+    CreatedAt    time.Time
+    ExpiresAt    time.Time `gorm:"index"`
 }
 
 type AuthPayload struct {
@@ -1,34 +1,55 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"tessellate-projects/internal/risk"
+	"tessellate-projects/internal/workflow"
+)
 
 // API Response models - these are what we return to clients
 // They're separate from database models for better API design
 
 type ProjectResponse struct {
-	ID           uint                    `json:"id"`
-	Name         string                  `json:"name"`
-	ClientName   string                  `json:"clientName"`
-	Status       string                  `json:"status"`
-	ClientID     *uint                   `json:"clientId,omitempty"`
-	Client       *ClientResponse         `json:"client,omitempty"`
-	Users        []UserResponse          `json:"users,omitempty"`
-	Requirements []RequirementResponse   `json:"requirements,omitempty"`
-	Issues       []IssueResponse         `json:"issues,omitempty"`
-	CreatedAt    time.Time               `json:"createdAt"`
-	UpdatedAt    time.Time               `json:"updatedAt"`
+	ID                    uint                     `json:"id"`
+	Name                  string                   `json:"name"`
+	ClientName            string                   `json:"clientName"`
+	Status                string                   `json:"status"`
+	ClientID              *uint                    `json:"clientId,omitempty"`
+	Client                *ClientResponse          `json:"client,omitempty"`
+	Users                 []UserResponse           `json:"users,omitempty"`
+	Requirements          []RequirementResponse    `json:"requirements,omitempty"`
+	Issues                []IssueResponse          `json:"issues,omitempty"`
+	LockedAt              *time.Time               `json:"lockedAt,omitempty"`
+	RolledFromID          *uint                    `json:"rolledFromId,omitempty"`
+	RolledFrom            *ProjectResponse         `json:"rolledFrom,omitempty"`
+	AuditType             *string                  `json:"auditType,omitempty"`
+	PeriodStart           *time.Time               `json:"periodStart,omitempty"`
+	PeriodEnd             *time.Time               `json:"periodEnd,omitempty"`
+	PlannedFieldworkStart *time.Time               `json:"plannedFieldworkStart,omitempty"`
+	PlannedFieldworkEnd   *time.Time               `json:"plannedFieldworkEnd,omitempty"`
+	FieldworkStart        *time.Time               `json:"fieldworkStart,omitempty"`
+	FieldworkEnd          *time.Time               `json:"fieldworkEnd,omitempty"`
+	ReportDueDate         *time.Time               `json:"reportDueDate,omitempty"`
+	TemplateID            *uint                    `json:"templateId,omitempty"`
+	Template              *ProjectTemplateResponse `json:"template,omitempty"`
+	CreatedAt             time.Time                `json:"createdAt"`
+	UpdatedAt             time.Time                `json:"updatedAt"`
 }
 
 type UserResponse struct {
-	ID        uint             `json:"id"`
-	Name      string           `json:"name"`
-	Email     string           `json:"email"`
-	Role      string           `json:"role"`
-	ClientID  *uint            `json:"clientId,omitempty"`
-	Client    *ClientResponse  `json:"client,omitempty"`
-	Projects  []ProjectResponse `json:"projects,omitempty"`
-	CreatedAt time.Time        `json:"createdAt"`
-	UpdatedAt time.Time        `json:"updatedAt"`
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	// ProjectRole is the user's role on the project being listed
+	ProjectRole string            `json:"projectRole,omitempty"`
+	ClientID    *uint             `json:"clientId,omitempty"`
+	Client      *ClientResponse   `json:"client,omitempty"`
+	Projects    []ProjectResponse `json:"projects,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
 }
 
 type ClientResponse struct {
@@ -44,67 +65,375 @@ type ClientResponse struct {
 }
 
 type RequirementResponse struct {
-	ID         uint                  `json:"id"`
-	ProjectID  uint                  `json:"projectId"`
-	Text       string                `json:"text"`
-	Category   *string               `json:"category,omitempty"`
-	Status     string                `json:"status"`
-	AuditTasks []AuditTaskResponse   `json:"auditTasks,omitempty"`
-	CreatedAt  time.Time             `json:"createdAt"`
-	UpdatedAt  time.Time             `json:"updatedAt"`
+	ID                 uint                      `json:"id"`
+	ProjectID          uint                      `json:"projectId"`
+	Project            *ProjectResponse          `json:"project,omitempty"`
+	Text               string                    `json:"text"`
+	Category           *string                   `json:"category,omitempty"`
+	Status             string                    `json:"status"`
+	Rationale          *string                   `json:"rationale,omitempty"`
+	AuditTasks         []AuditTaskResponse       `json:"auditTasks,omitempty"`
+	Issues             []IssueResponse           `json:"issues,omitempty"`
+	FrameworkControlID *uint                     `json:"frameworkControlId,omitempty"`
+	FrameworkControl   *FrameworkControlResponse `json:"frameworkControl,omitempty"`
+	Ref                *string                   `json:"ref,omitempty"`
+	ParentID           *uint                     `json:"parentId,omitempty"`
+	Parent             *RequirementResponse      `json:"parent,omitempty"`
+	Children           []RequirementResponse     `json:"children,omitempty"`
+	Position           int                       `json:"position"`
+	Section            bool                      `json:"section"`
+	// RolledUpStatus is the status of the requirement and everything under
+	// it, set in the requirements tree
+	RolledUpStatus string    `json:"rolledUpStatus,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
 }
 
 type AuditTaskResponse struct {
-	ID            uint            `json:"id"`
-	RequirementID uint            `json:"requirementId"`
-	Text          string          `json:"text"`
-	Status        string          `json:"status"`
-	Notes         *string         `json:"notes,omitempty"`
-	Issue         *IssueResponse  `json:"issue,omitempty"`
-	CreatedAt     time.Time       `json:"createdAt"`
-	UpdatedAt     time.Time       `json:"updatedAt"`
+	ID            uint                 `json:"id"`
+	RequirementID uint                 `json:"requirementId"`
+	Requirement   *RequirementResponse `json:"requirement,omitempty"`
+	Text          string               `json:"text"`
+	Status        string               `json:"status"`
+	Notes         *string              `json:"notes,omitempty"`
+	DueDate       *time.Time           `json:"dueDate,omitempty"`
+	Overdue       bool                 `json:"overdue"`
+	AssigneeID    *uint                `json:"assigneeId,omitempty"`
+	Assignee      *UserResponse        `json:"assignee,omitempty"`
+	ReviewerID    *uint                `json:"reviewerId,omitempty"`
+	Reviewer      *UserResponse        `json:"reviewer,omitempty"`
+	PreparedByID  *uint                `json:"preparedById,omitempty"`
+	PreparedBy    *UserResponse        `json:"preparedBy,omitempty"`
+	PreparedAt    *time.Time           `json:"preparedAt,omitempty"`
+	ReviewedByID  *uint                `json:"reviewedById,omitempty"`
+	ReviewedBy    *UserResponse        `json:"reviewedBy,omitempty"`
+	ReviewedAt    *time.Time           `json:"reviewedAt,omitempty"`
+	ReviewNotes   *string              `json:"reviewNotes,omitempty"`
+	Issues        []IssueResponse      `json:"issues,omitempty"`
+	Evidence      []EvidenceResponse   `json:"evidence,omitempty"`
+	StaleEvidence bool                 `json:"staleEvidence"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	UpdatedAt     time.Time            `json:"updatedAt"`
+}
+
+type EvidenceResponse struct {
+	ID                uint                `json:"id"`
+	ClientID          uint                `json:"clientId"`
+	Client            *ClientResponse     `json:"client,omitempty"`
+	AuditTasks        []AuditTaskResponse `json:"auditTasks,omitempty"`
+	FileName          string              `json:"fileName"`
+	ContentType       string              `json:"contentType"`
+	Size              int64               `json:"size"`
+	SHA256            string              `json:"sha256"`
+	CollectedByID     uint                `json:"collectedById"`
+	CollectedBy       *UserResponse       `json:"collectedBy,omitempty"`
+	CollectedAt       time.Time           `json:"collectedAt"`
+	Source            *string             `json:"source,omitempty"`
+	ValidFrom         *time.Time          `json:"validFrom,omitempty"`
+	ExpiresOn         *time.Time          `json:"expiresOn,omitempty"`
+	Stale             bool                `json:"stale"`
+	Version           int                 `json:"version"`
+	PreviousVersionID *uint               `json:"previousVersionId,omitempty"`
+	SupersededByID    *uint               `json:"supersededById,omitempty"`
+	CreatedAt         time.Time           `json:"createdAt"`
+	UpdatedAt         time.Time           `json:"updatedAt"`
+}
+
+type CustodyEventResponse struct {
+	ID           uint          `json:"id"`
+	EvidenceID   uint          `json:"evidenceId"`
+	Sequence     int           `json:"sequence"`
+	Action       string        `json:"action"`
+	UserID       *uint         `json:"userId,omitempty"`
+	User         *UserResponse `json:"user,omitempty"`
+	OccurredAt   time.Time     `json:"occurredAt"`
+	Details      *string       `json:"details,omitempty"`
+	PreviousHash string        `json:"previousHash"`
+	Hash         string        `json:"hash"`
+}
+
+// CustodyLogResponse is an evidence item's chain of custody. BrokenAtSequence
+// is the first event whose hash does not chain from its predecessor.
+type CustodyLogResponse struct {
+	EvidenceID       uint                   `json:"evidenceId"`
+	ChainValid       bool                   `json:"chainValid"`
+	BrokenAtSequence *int                   `json:"brokenAtSequence,omitempty"`
+	Events           []CustodyEventResponse `json:"events"`
+}
+
+// EvidenceVerificationResponse compares an evidence item's stored content
+// and custody chain with what was recorded at upload.
+type EvidenceVerificationResponse struct {
+	EvidenceID       uint      `json:"evidenceId"`
+	FileName         string    `json:"fileName"`
+	Version          int       `json:"version"`
+	Verified         bool      `json:"verified"`
+	ContentFound     bool      `json:"contentFound"`
+	ExpectedSHA256   string    `json:"expectedSha256"`
+	ActualSHA256     *string   `json:"actualSha256,omitempty"`
+	ExpectedSize     int64     `json:"expectedSize"`
+	ActualSize       *int64    `json:"actualSize,omitempty"`
+	ChainValid       bool      `json:"chainValid"`
+	BrokenAtSequence *int      `json:"brokenAtSequence,omitempty"`
+	Problems         []string  `json:"problems"`
+	VerifiedAt       time.Time `json:"verifiedAt"`
+}
+
+type DocumentRequestResponse struct {
+	ID             uint               `json:"id"`
+	ProjectID      uint               `json:"projectId"`
+	Project        *ProjectResponse   `json:"project,omitempty"`
+	Description    string             `json:"description"`
+	RequirementIDs []uint             `json:"requirementIds"`
+	AssigneeID     *uint              `json:"assigneeId,omitempty"`
+	Assignee       *UserResponse      `json:"assignee,omitempty"`
+	RequestedByID  uint               `json:"requestedById"`
+	RequestedBy    *UserResponse      `json:"requestedBy,omitempty"`
+	DueDate        *time.Time         `json:"dueDate,omitempty"`
+	Status         string             `json:"status"`
+	Overdue        bool               `json:"overdue"`
+	SubmittedAt    *time.Time         `json:"submittedAt,omitempty"`
+	ReviewNote     *string            `json:"reviewNote,omitempty"`
+	Evidence       []EvidenceResponse `json:"evidence,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
+
+// DocumentRequestTrackerResponse summarises a project's document requests.
+// Outstanding requests are those waiting on the client: requested, or
+// rejected and not yet resubmitted.
+type DocumentRequestTrackerResponse struct {
+	ProjectID        uint                      `json:"projectId"`
+	Total            int                       `json:"total"`
+	ByStatus         map[string]int            `json:"byStatus"`
+	Outstanding      int                       `json:"outstanding"`
+	Overdue          int                       `json:"overdue"`
+	AwaitingReview   int                       `json:"awaitingReview"`
+	Assignees        []DocumentRequestAssignee `json:"assignees"`
+	OutstandingItems []DocumentRequestResponse `json:"outstandingItems"`
+}
+
+type DocumentRequestAssignee struct {
+	AssigneeID  *uint         `json:"assigneeId"`
+	Assignee    *UserResponse `json:"assignee,omitempty"`
+	Outstanding int           `json:"outstanding"`
+	Overdue     int           `json:"overdue"`
+	NextDueDate *time.Time    `json:"nextDueDate,omitempty"`
 }
 
 type IssueResponse struct {
-	ID          uint      `json:"id"`
-	AuditTaskID uint      `json:"auditTaskId"`
-	Title       string    `json:"title"`
-	Description *string   `json:"description,omitempty"`
-	Priority    *string   `json:"priority,omitempty"`
-	Phase       *string   `json:"phase,omitempty"`
-	EstimateHrs *int      `json:"estimateHrs,omitempty"`
-	Status      string    `json:"status"`
-	Type        string    `json:"type"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID                   uint                 `json:"id"`
+	AuditTaskID          uint                 `json:"auditTaskId"`
+	AuditTask            *AuditTaskResponse   `json:"auditTask,omitempty"`
+	RequirementIDs       []uint               `json:"requirementIds"`
+	Title                string               `json:"title"`
+	Description          *string              `json:"description,omitempty"`
+	Priority             *string              `json:"priority,omitempty"`
+	Phase                *string              `json:"phase,omitempty"`
+	EstimateHrs          *int                 `json:"estimateHrs,omitempty"`
+	Status               string               `json:"status"`
+	Type                 string               `json:"type"`
+	ResolutionNote       *string              `json:"resolutionNote,omitempty"`
+	DueDate              *time.Time           `json:"dueDate,omitempty"`
+	DueDateFromSLA       bool                 `json:"dueDateFromSla"`
+	Overdue              bool                 `json:"overdue"`
+	AssigneeID           *uint                `json:"assigneeId,omitempty"`
+	Assignee             *UserResponse        `json:"assignee,omitempty"`
+	ReviewerID           *uint                `json:"reviewerId,omitempty"`
+	Reviewer             *UserResponse        `json:"reviewer,omitempty"`
+	InherentRisk         *RiskScoreResponse   `json:"inherentRisk,omitempty"`
+	ResidualRisk         *RiskScoreResponse   `json:"residualRisk,omitempty"`
+	CompensatingControls *string              `json:"compensatingControls,omitempty"`
+	Remediation          *RemediationResponse `json:"remediation,omitempty"`
+	Retests              []RetestResponse     `json:"retests,omitempty"`
+	CarriedFromID        *uint                `json:"carriedFromId,omitempty"`
+	CarriedFrom          *IssueResponse       `json:"carriedFrom,omitempty"`
+	CreatedAt            time.Time            `json:"createdAt"`
+	UpdatedAt            time.Time            `json:"updatedAt"`
+}
+
+// RemediationResponse is the management response to an issue and the plan
+// to fix it. Milestones are always included.
+type RemediationResponse struct {
+	ID                 uint                           `json:"id"`
+	IssueID            uint                           `json:"issueId"`
+	Agreement          string                         `json:"agreement"`
+	ManagementResponse string                         `json:"managementResponse"`
+	ActionPlan         *string                        `json:"actionPlan,omitempty"`
+	OwnerID            *uint                          `json:"ownerId,omitempty"`
+	Owner              *UserResponse                  `json:"owner,omitempty"`
+	TargetDate         *time.Time                     `json:"targetDate,omitempty"`
+	RespondedByID      uint                           `json:"respondedById"`
+	RespondedBy        *UserResponse                  `json:"respondedBy,omitempty"`
+	Milestones         []RemediationMilestoneResponse `json:"milestones"`
+	Updates            []RemediationUpdateResponse    `json:"updates,omitempty"`
+	CreatedAt          time.Time                      `json:"createdAt"`
+	UpdatedAt          time.Time                      `json:"updatedAt"`
+}
+
+type RemediationMilestoneResponse struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	DueDate     *time.Time `json:"dueDate,omitempty"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Overdue     bool       `json:"overdue"`
+}
+
+type RemediationUpdateResponse struct {
+	ID              uint          `json:"id"`
+	AuthorID        uint          `json:"authorId"`
+	Author          *UserResponse `json:"author,omitempty"`
+	Note            string        `json:"note"`
+	PercentComplete *int          `json:"percentComplete,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+}
+
+type RetestResponse struct {
+	ID            uint              `json:"id"`
+	IssueID       uint              `json:"issueId"`
+	Result        string            `json:"result"`
+	Notes         *string           `json:"notes,omitempty"`
+	EvidenceID    uint              `json:"evidenceId"`
+	Evidence      *EvidenceResponse `json:"evidence,omitempty"`
+	PerformedByID uint              `json:"performedById"`
+	PerformedBy   *UserResponse     `json:"performedBy,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+}
+
+// RiskScoreResponse is a position on the risk matrix and its rating.
+type RiskScoreResponse struct {
+	Likelihood int    `json:"likelihood"`
+	Impact     int    `json:"impact"`
+	Score      int    `json:"score"`
+	Rating     string `json:"rating"`
+}
+
+type FrameworkResponse struct {
+	ID          uint                       `json:"id"`
+	Name        string                     `json:"name"`
+	Version     string                     `json:"version"`
+	Description *string                    `json:"description,omitempty"`
+	Controls    []FrameworkControlResponse `json:"controls,omitempty"`
+	CreatedAt   time.Time                  `json:"createdAt"`
+	UpdatedAt   time.Time                  `json:"updatedAt"`
+}
+
+type FrameworkControlResponse struct {
+	ID          uint               `json:"id"`
+	FrameworkID uint               `json:"frameworkId"`
+	Framework   *FrameworkResponse `json:"framework,omitempty"`
+	ControlID   string             `json:"controlId"`
+	Title       string             `json:"title"`
+	Description *string            `json:"description,omitempty"`
+	Category    *string            `json:"category,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// FrameworkCoverageResponse reports how much of a framework a project covers.
+// A control counts as met when every requirement created from it is MET.
+type FrameworkCoverageResponse struct {
+	FrameworkID     uint   `json:"frameworkId"`
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	TotalControls   int    `json:"totalControls"`
+	AppliedControls int    `json:"appliedControls"`
+	MetControls     int    `json:"metControls"`
+	// NotApplicableControls were assessed as not applicable to the project
+	// and are left out of the coverage
+	NotApplicableControls int     `json:"notApplicableControls"`
+	Coverage              float64 `json:"coverage"` // percentage of the applicable controls that are met
+}
+
+type ControlMappingResponse struct {
+	ID              uint                      `json:"id"`
+	SourceControlID uint                      `json:"sourceControlId"`
+	SourceControl   *FrameworkControlResponse `json:"sourceControl,omitempty"`
+	TargetControlID uint                      `json:"targetControlId"`
+	TargetControl   *FrameworkControlResponse `json:"targetControl,omitempty"`
+	Strength        string                    `json:"strength"`
+	CreatedAt       time.Time                 `json:"createdAt"`
+	UpdatedAt       time.Time                 `json:"updatedAt"`
+}
+
+// MappedCoverageResponse reports a project's coverage of a framework,
+// counting controls met through mappings from the controls the project was
+// actually assessed against.
+type MappedCoverageResponse struct {
+	FrameworkCoverageResponse
+	PartiallyMetControls int                       `json:"partiallyMetControls"`
+	Gaps                 []ControlCoverageResponse `json:"gaps"`
+}
+
+// ControlCoverageResponse describes how a single control is covered.
+// Status is MET, PARTIALLY_MET, NOT_MET, NOT_ADDRESSED or NOT_APPLICABLE.
+type ControlCoverageResponse struct {
+	FrameworkControlID uint                     `json:"frameworkControlId"`
+	ControlID          string                   `json:"controlId"`
+	Title              string                   `json:"title"`
+	Status             string                   `json:"status"`
+	Applied            bool                     `json:"applied"`
+	Sources            []CoverageSourceResponse `json:"sources,omitempty"`
+}
+
+// CoverageSourceResponse is a project control mapped onto a control of the
+// framework being analysed.
+type CoverageSourceResponse struct {
+	FrameworkControlID uint   `json:"frameworkControlId"`
+	ControlID          string `json:"controlId"`
+	FrameworkID        uint   `json:"frameworkId"`
+	Strength           string `json:"strength"`
+	Met                bool   `json:"met"`
 }
 
 // Request models for creating/updating
 type CreateProjectRequest struct {
-	Name       string `json:"name" binding:"required"`
-	ClientName string `json:"clientName" binding:"required"`
-	ClientID   *uint  `json:"clientId,omitempty"`
+	Name                  string     `json:"name" binding:"required"`
+	ClientName            string     `json:"clientName" binding:"required"`
+	ClientID              *uint      `json:"clientId,omitempty"`
+	AuditType             *string    `json:"auditType,omitempty" binding:"omitempty,enum=auditType"`
+	PeriodStart           *time.Time `json:"periodStart,omitempty"`
+	PeriodEnd             *time.Time `json:"periodEnd,omitempty"`
+	PlannedFieldworkStart *time.Time `json:"plannedFieldworkStart,omitempty"`
+	PlannedFieldworkEnd   *time.Time `json:"plannedFieldworkEnd,omitempty"`
+	FieldworkStart        *time.Time `json:"fieldworkStart,omitempty"`
+	FieldworkEnd          *time.Time `json:"fieldworkEnd,omitempty"`
+	ReportDueDate         *time.Time `json:"reportDueDate,omitempty"`
 }
 
+// The Update*Request models are full replacement documents: PUT bodies must
+// carry every required field, and an omitted or null optional field clears
+// the stored value. PATCH requests are applied to the current document and
+// then validated against the same rules.
+
 type UpdateProjectRequest struct {
-	Name       *string `json:"name,omitempty"`
-	ClientName *string `json:"clientName,omitempty"`
-	Status     *string `json:"status,omitempty"`
-	ClientID   *uint   `json:"clientId,omitempty"`
+	Name                  string     `json:"name" binding:"required"`
+	ClientName            string     `json:"clientName" binding:"required"`
+	Status                string     `json:"status" binding:"required,enum=projectStatus"`
+	ClientID              *uint      `json:"clientId"`
+	AuditType             *string    `json:"auditType" binding:"omitempty,enum=auditType"`
+	PeriodStart           *time.Time `json:"periodStart"`
+	PeriodEnd             *time.Time `json:"periodEnd"`
+	PlannedFieldworkStart *time.Time `json:"plannedFieldworkStart"`
+	PlannedFieldworkEnd   *time.Time `json:"plannedFieldworkEnd"`
+	FieldworkStart        *time.Time `json:"fieldworkStart"`
+	FieldworkEnd          *time.Time `json:"fieldworkEnd"`
+	ReportDueDate         *time.Time `json:"reportDueDate"`
 }
 
 type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Role     string `json:"role" binding:"required,oneof=ADMIN CONSULTANT CLIENT"`
+	Role     string `json:"role" binding:"required,enum=role"`
 	ClientID *uint  `json:"clientId,omitempty"`
 }
 
 type UpdateUserRequest struct {
-	Name     *string `json:"name,omitempty"`
-	Email    *string `json:"email,omitempty"`
-	Role     *string `json:"role,omitempty"`
-	ClientID *uint   `json:"clientId,omitempty"`
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Role     string `json:"role" binding:"required,enum=role"`
+	ClientID *uint  `json:"clientId"`
 }
 
 type CreateClientRequest struct {
@@ -115,23 +444,590 @@ type CreateClientRequest struct {
 }
 
 type UpdateClientRequest struct {
-	Name         *string `json:"name,omitempty"`
-	Industry     *string `json:"industry,omitempty"`
-	ContactName  *string `json:"contactName,omitempty"`
-	ContactEmail *string `json:"contactEmail,omitempty"`
+	Name         string  `json:"name" binding:"required"`
+	Industry     *string `json:"industry"`
+	ContactName  *string `json:"contactName"`
+	ContactEmail *string `json:"contactEmail" binding:"omitempty,email"`
 }
 
+// CreateRequirementRequest adds a requirement, under ParentID if set.
+// Without a Position it goes after its siblings.
 type CreateRequirementRequest struct {
 	ProjectID uint    `json:"projectId" binding:"required"`
 	Text      string  `json:"text" binding:"required"`
 	Category  *string `json:"category,omitempty"`
-	Status    *string `json:"status,omitempty"`
+	Status    *string `json:"status,omitempty" binding:"omitempty,enum=requirementStatus"`
+	Rationale *string `json:"rationale,omitempty"`
+	Ref       *string `json:"ref,omitempty"`
+	ParentID  *uint   `json:"parentId,omitempty"`
+	Position  *int    `json:"position,omitempty"`
+	Section   bool    `json:"section,omitempty"`
 }
 
 type UpdateRequirementRequest struct {
-	Text     *string `json:"text,omitempty"`
-	Category *string `json:"category,omitempty"`
-	Status   *string `json:"status,omitempty"`
+	Text      string  `json:"text" binding:"required"`
+	Category  *string `json:"category"`
+	Status    string  `json:"status" binding:"required,enum=requirementStatus"`
+	Rationale *string `json:"rationale"`
+	Ref       *string `json:"ref"`
+	ParentID  *uint   `json:"parentId"`
+	Position  int     `json:"position"`
+	Section   bool    `json:"section"`
+}
+
+type CreateAuditTaskRequest struct {
+	Text    string     `json:"text" binding:"required"`
+	Status  *string    `json:"status,omitempty" binding:"omitempty,enum=auditTaskStatus"`
+	Notes   *string    `json:"notes,omitempty"`
+	DueDate *time.Time `json:"dueDate,omitempty"`
+	// The assignee and reviewer must be staff on the project, and not the
+	// same person
+	AssigneeID *uint `json:"assigneeId,omitempty"`
+	ReviewerID *uint `json:"reviewerId,omitempty"`
+}
+
+type UpdateAuditTaskRequest struct {
+	Text       string     `json:"text" binding:"required"`
+	Status     string     `json:"status" binding:"required,enum=auditTaskStatus"`
+	Notes      *string    `json:"notes"`
+	DueDate    *time.Time `json:"dueDate"`
+	AssigneeID *uint      `json:"assigneeId"`
+	ReviewerID *uint      `json:"reviewerId"`
+	// ReviewNotes are required to send a task under review back for rework
+	ReviewNotes *string `json:"reviewNotes"`
+}
+
+type CreateIssueRequest struct {
+	Title       string  `json:"title" binding:"required"`
+	Description *string `json:"description,omitempty"`
+	Priority    *string `json:"priority,omitempty" binding:"omitempty,enum=issuePriority"`
+	Phase       *string `json:"phase,omitempty" binding:"omitempty,enum=issuePhase"`
+	EstimateHrs *int    `json:"estimateHrs,omitempty" binding:"omitempty,min=0"`
+	Status      *string `json:"status,omitempty" binding:"omitempty,enum=issueStatus"`
+	Type        *string `json:"type,omitempty" binding:"omitempty,enum=issueType"`
+	// RequirementIDs defaults to the requirement of the audit task
+	RequirementIDs       []uint  `json:"requirementIds,omitempty"`
+	InherentLikelihood   *int    `json:"inherentLikelihood,omitempty" binding:"omitempty,min=1"`
+	InherentImpact       *int    `json:"inherentImpact,omitempty" binding:"omitempty,min=1"`
+	ResidualLikelihood   *int    `json:"residualLikelihood,omitempty" binding:"omitempty,min=1"`
+	ResidualImpact       *int    `json:"residualImpact,omitempty" binding:"omitempty,min=1"`
+	CompensatingControls *string `json:"compensatingControls,omitempty"`
+	// DueDate defaults to the one set by the client's SLA policy
+	DueDate    *time.Time `json:"dueDate,omitempty"`
+	AssigneeID *uint      `json:"assigneeId,omitempty"`
+	ReviewerID *uint      `json:"reviewerId,omitempty"`
+}
+
+type UpdateIssueRequest struct {
+	Title       string  `json:"title" binding:"required"`
+	Description *string `json:"description"`
+	Priority    *string `json:"priority" binding:"omitempty,enum=issuePriority"`
+	Phase       *string `json:"phase" binding:"omitempty,enum=issuePhase"`
+	EstimateHrs *int    `json:"estimateHrs" binding:"omitempty,min=0"`
+	Status      string  `json:"status" binding:"required,enum=issueStatus"`
+	Type        string  `json:"type" binding:"required,enum=issueType"`
+	// ResolutionNote is required by the default workflow when closing an issue
+	ResolutionNote       *string `json:"resolutionNote"`
+	RequirementIDs       []uint  `json:"requirementIds" binding:"required,min=1"`
+	InherentLikelihood   *int    `json:"inherentLikelihood" binding:"omitempty,min=1"`
+	InherentImpact       *int    `json:"inherentImpact" binding:"omitempty,min=1"`
+	ResidualLikelihood   *int    `json:"residualLikelihood" binding:"omitempty,min=1"`
+	ResidualImpact       *int    `json:"residualImpact" binding:"omitempty,min=1"`
+	CompensatingControls *string `json:"compensatingControls"`
+	// Clearing DueDate hands it back to the client's SLA policy
+	DueDate    *time.Time `json:"dueDate"`
+	AssigneeID *uint      `json:"assigneeId"`
+	ReviewerID *uint      `json:"reviewerId"`
+}
+
+type CreateFrameworkRequest struct {
+	Name        string                          `json:"name" binding:"required"`
+	Version     string                          `json:"version" binding:"required"`
+	Description *string                         `json:"description,omitempty"`
+	Controls    []CreateFrameworkControlRequest `json:"controls,omitempty" binding:"dive"`
+}
+
+type UpdateFrameworkRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Version     string  `json:"version" binding:"required"`
+	Description *string `json:"description"`
+}
+
+type CreateFrameworkControlRequest struct {
+	ControlID   string  `json:"controlId" binding:"required"`
+	Title       string  `json:"title" binding:"required"`
+	Description *string `json:"description,omitempty"`
+	Category    *string `json:"category,omitempty"`
+}
+
+type UpdateFrameworkControlRequest struct {
+	ControlID   string  `json:"controlId" binding:"required"`
+	Title       string  `json:"title" binding:"required"`
+	Description *string `json:"description"`
+	Category    *string `json:"category"`
+}
+
+type CreateControlMappingRequest struct {
+	TargetControlID uint   `json:"targetControlId" binding:"required"`
+	Strength        string `json:"strength" binding:"required,enum=mappingStrength"`
+}
+
+type UpdateControlMappingRequest struct {
+	Strength string `json:"strength" binding:"required,enum=mappingStrength"`
+}
+
+// UpdateEvidenceRequest changes an evidence item's descriptive metadata. The
+// file itself is write-once and is changed by uploading a new version.
+type UpdateEvidenceRequest struct {
+	Source    *string    `json:"source"`
+	ValidFrom *time.Time `json:"validFrom"`
+	ExpiresOn *time.Time `json:"expiresOn"`
+}
+
+type CreateDocumentRequestRequest struct {
+	Description    string     `json:"description" binding:"required"`
+	RequirementIDs []uint     `json:"requirementIds,omitempty"`
+	AssigneeID     *uint      `json:"assigneeId,omitempty"`
+	DueDate        *time.Time `json:"dueDate,omitempty"`
+}
+
+// UpdateDocumentRequestRequest replaces a document request's details. Its
+// status changes through the transitions endpoint.
+type UpdateDocumentRequestRequest struct {
+	Description    string     `json:"description" binding:"required"`
+	RequirementIDs []uint     `json:"requirementIds"`
+	AssigneeID     *uint      `json:"assigneeId"`
+	DueDate        *time.Time `json:"dueDate"`
+}
+
+// RemediationRequest records or replaces the management response to an
+// issue. The owner must be a CLIENT user of the project's client.
+type RemediationRequest struct {
+	Agreement          string     `json:"agreement" binding:"required,enum=managementAgreement"`
+	ManagementResponse string     `json:"managementResponse" binding:"required"`
+	ActionPlan         *string    `json:"actionPlan"`
+	OwnerID            *uint      `json:"ownerId"`
+	TargetDate         *time.Time `json:"targetDate"`
+}
+
+type CreateRemediationMilestoneRequest struct {
+	Title   string     `json:"title" binding:"required"`
+	DueDate *time.Time `json:"dueDate,omitempty"`
+}
+
+// UpdateRemediationMilestoneRequest replaces a milestone. Setting completed
+// records when the milestone was completed.
+type UpdateRemediationMilestoneRequest struct {
+	Title     string     `json:"title" binding:"required"`
+	DueDate   *time.Time `json:"dueDate"`
+	Completed bool       `json:"completed"`
+}
+
+type CreateRemediationUpdateRequest struct {
+	Note            string `json:"note" binding:"required"`
+	PercentComplete *int   `json:"percentComplete,omitempty" binding:"omitempty,min=0,max=100"`
+}
+
+type ApplyFrameworkRequest struct {
+	FrameworkID uint `json:"frameworkId" binding:"required"`
+}
+
+type TransitionRequest struct {
+	To     string            `json:"to" binding:"required"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type TransitionResponse struct {
+	ID         uint              `json:"id"`
+	EntityType string            `json:"entityType"`
+	EntityID   uint              `json:"entityId"`
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	UserID     *uint             `json:"userId,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+type StatusHistoryResponse struct {
+	Status    string                `json:"status"`
+	Available []workflow.Transition `json:"available"`
+	History   []TransitionResponse  `json:"history"`
+}
+
+type RiskMatrixResponse struct {
+	ClientID uint        `json:"clientId"`
+	Source   string      `json:"source"` // "client" or "default"
+	Matrix   risk.Matrix `json:"matrix"`
+}
+
+// RiskHeatmapResponse counts issues in each cell of the risk matrix. Cells
+// are listed for every likelihood and impact pair, lowest first.
+type RiskHeatmapResponse struct {
+	ProjectID   *uint             `json:"projectId,omitempty"`
+	ClientID    *uint             `json:"clientId,omitempty"`
+	Basis       string            `json:"basis"`
+	Likelihoods []string          `json:"likelihoods"`
+	Impacts     []string          `json:"impacts"`
+	Cells       []RiskHeatmapCell `json:"cells"`
+	ByRating    map[string]int    `json:"byRating"`
+	Scored      int               `json:"scored"`
+	Unscored    int               `json:"unscored"`
+}
+
+// SignOffRequest signs off a project in the given role. LEAD is signed by
+// the project lead, CLIENT_EXECUTIVE by a user of the project's client.
+type SignOffRequest struct {
+	Role      string `json:"role" binding:"required,enum=signOffRole"`
+	Statement string `json:"statement" binding:"required"`
+}
+
+type SignOffResponse struct {
+	ID             uint          `json:"id"`
+	ProjectID      uint          `json:"projectId"`
+	SignerID       uint          `json:"signerId"`
+	Signer         *UserResponse `json:"signer,omitempty"`
+	Role           string        `json:"role"`
+	Statement      string        `json:"statement"`
+	SignedAt       time.Time     `json:"signedAt"`
+	SnapshotDigest string        `json:"snapshotDigest"`
+	KeyID          string        `json:"keyId"`
+	PublicKey      string        `json:"publicKey"`
+	Signature      string        `json:"signature"`
+}
+
+// SignOffVerificationResponse reports whether a project's sign-offs still
+// hold. Valid is set when the project has been signed off and every check of
+// every sign-off passes.
+type SignOffVerificationResponse struct {
+	ProjectID     uint                  `json:"projectId"`
+	Valid         bool                  `json:"valid"`
+	CurrentDigest string                `json:"currentDigest"`
+	SignOffs      []SignOffVerification `json:"signOffs"`
+}
+
+// SignOffVerification checks one sign-off: the signature matches what was
+// signed, it was made with the server's current key, the stored snapshot
+// matches its digest, and the project still matches the snapshot. Changes
+// lists what differs from the snapshot.
+type SignOffVerification struct {
+	SignOffID        uint             `json:"signOffId"`
+	Role             string           `json:"role"`
+	SignerID         uint             `json:"signerId"`
+	SignatureValid   bool             `json:"signatureValid"`
+	KeyTrusted       bool             `json:"keyTrusted"`
+	SnapshotIntact   bool             `json:"snapshotIntact"`
+	ProjectUnchanged bool             `json:"projectUnchanged"`
+	Changes          []SnapshotChange `json:"changes"`
+}
+
+// SnapshotChange is an entity added, removed or modified since a snapshot.
+type SnapshotChange struct {
+	Type   string `json:"type"`
+	ID     uint   `json:"id"`
+	Change string `json:"change"`
+}
+
+// TimelineEvent is a span of an engagement's calendar, or a single date when
+// End is not set. Type is one of auditPeriod, plannedFieldwork, fieldwork and
+// reportDue. Fieldwork that has started but not finished has no End.
+type TimelineEvent struct {
+	ProjectID   uint       `json:"projectId"`
+	ProjectName string     `json:"projectName"`
+	Type        string     `json:"type"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`
+}
+
+// ProjectTimelineResponse lays out a project's dates. FieldworkDelayDays is
+// how many days after the planned start fieldwork actually started, negative
+// when it started early.
+type ProjectTimelineResponse struct {
+	ProjectID          uint            `json:"projectId"`
+	AuditType          *string         `json:"auditType,omitempty"`
+	FieldworkDelayDays *int            `json:"fieldworkDelayDays,omitempty"`
+	ReportOverdue      bool            `json:"reportOverdue"`
+	Events             []TimelineEvent `json:"events"`
+}
+
+// ProjectTemplateContent is what a project template sets up: requirements
+// with their audit tasks, the team and their project roles, and the document
+// requests (PBC items) for the client. Text may contain {{variables}}, which
+// are filled in when the template is instantiated. Requirements nest under
+// the requirement named by ParentRef, and document requests name the
+// requirements they support by their refs.
+type ProjectTemplateContent struct {
+	Requirements     []TemplateRequirement     `json:"requirements" binding:"dive"`
+	Team             []TemplateMember          `json:"team" binding:"dive"`
+	DocumentRequests []TemplateDocumentRequest `json:"documentRequests" binding:"dive"`
+}
+
+type TemplateRequirement struct {
+	Ref                string              `json:"ref,omitempty"`
+	Text               string              `json:"text" binding:"required"`
+	Category           *string             `json:"category,omitempty"`
+	FrameworkControlID *uint               `json:"frameworkControlId,omitempty"`
+	ParentRef          string              `json:"parentRef,omitempty"`
+	Section            bool                `json:"section,omitempty"`
+	AuditTasks         []TemplateAuditTask `json:"auditTasks" binding:"dive"`
+}
+
+type TemplateAuditTask struct {
+	Text  string  `json:"text" binding:"required"`
+	Notes *string `json:"notes,omitempty"`
+}
+
+// TemplateMember is a default member of the team and their project role.
+type TemplateMember struct {
+	UserID uint   `json:"userId" binding:"required"`
+	Role   string `json:"role" binding:"required,enum=projectRole"`
+}
+
+type TemplateDocumentRequest struct {
+	Description     string   `json:"description" binding:"required"`
+	RequirementRefs []string `json:"requirementRefs,omitempty"`
+}
+
+// CreateProjectTemplateRequest authors a template. ProjectName is the name
+// of the projects it sets up and defaults to "{{clientName}} <name>".
+type CreateProjectTemplateRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description,omitempty"`
+	AuditType   *string `json:"auditType,omitempty" binding:"omitempty,enum=auditType"`
+	ProjectName string  `json:"projectName,omitempty"`
+	ProjectTemplateContent
+}
+
+// SaveProjectTemplateRequest saves a project's setup as a template.
+type SaveProjectTemplateRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description,omitempty"`
+	ProjectName string  `json:"projectName,omitempty"`
+}
+
+// ProjectTemplateResponse is one version of a template. Variables lists the
+// {{variables}} its text uses.
+type ProjectTemplateResponse struct {
+	ID               uint                      `json:"id"`
+	Name             string                    `json:"name"`
+	Version          int                       `json:"version"`
+	Description      *string                   `json:"description,omitempty"`
+	AuditType        *string                   `json:"auditType,omitempty"`
+	ProjectName      string                    `json:"projectName"`
+	SourceProjectID  *uint                     `json:"sourceProjectId,omitempty"`
+	SourceProject    *ProjectResponse          `json:"sourceProject,omitempty"`
+	CreatedByID      uint                      `json:"createdById"`
+	CreatedBy        *UserResponse             `json:"createdBy,omitempty"`
+	Variables        []string                  `json:"variables"`
+	Requirements     []TemplateRequirement     `json:"requirements"`
+	Team             []TemplateMember          `json:"team"`
+	DocumentRequests []TemplateDocumentRequest `json:"documentRequests"`
+	CreatedAt        time.Time                 `json:"createdAt"`
+}
+
+// InstantiateTemplateRequest sets up a project for a client from a template.
+// Besides clientName, periodStart, periodEnd, period and year, which are
+// derived from the request, Variables supplies the template's own
+// {{variables}}. Name overrides the template's project name.
+type InstantiateTemplateRequest struct {
+	ClientID              uint              `json:"clientId" binding:"required"`
+	Name                  string            `json:"name,omitempty"`
+	AuditType             *string           `json:"auditType,omitempty" binding:"omitempty,enum=auditType"`
+	PeriodStart           *time.Time        `json:"periodStart,omitempty"`
+	PeriodEnd             *time.Time        `json:"periodEnd,omitempty"`
+	PlannedFieldworkStart *time.Time        `json:"plannedFieldworkStart,omitempty"`
+	PlannedFieldworkEnd   *time.Time        `json:"plannedFieldworkEnd,omitempty"`
+	ReportDueDate         *time.Time        `json:"reportDueDate,omitempty"`
+	Variables             map[string]string `json:"variables,omitempty"`
+}
+
+// InstantiateTemplateResponse is the project set up from a template and
+// counts of what was created in it.
+type InstantiateTemplateResponse struct {
+	Project          ProjectResponse `json:"project"`
+	Members          int             `json:"members"`
+	Requirements     int             `json:"requirements"`
+	AuditTasks       int             `json:"auditTasks"`
+	DocumentRequests int             `json:"documentRequests"`
+}
+
+// RollForwardRequest clones a project into a new one for the next audit
+// period. Name defaults to the source project's name with "(rolled forward)"
+// appended.
+type RollForwardRequest struct {
+	Name               string `json:"name,omitempty"`
+	CarryForwardIssues bool   `json:"carryForwardIssues,omitempty"`
+}
+
+// RollForwardResponse describes what a roll-forward creates from the source
+// project. Project and the items' IDs are set once it has been committed, and
+// left out of a preview.
+type RollForwardResponse struct {
+	SourceProjectID uint              `json:"sourceProjectId"`
+	Preview         bool              `json:"preview"`
+	Name            string            `json:"name"`
+	PeriodStart     *time.Time        `json:"periodStart,omitempty"`
+	PeriodEnd       *time.Time        `json:"periodEnd,omitempty"`
+	Project         *ProjectResponse  `json:"project,omitempty"`
+	Members         []UserResponse    `json:"members"`
+	Workflows       []string          `json:"workflows"`
+	Requirements    []RollForwardItem `json:"requirements"`
+	AuditTasks      []RollForwardItem `json:"auditTasks"`
+	Issues          []RollForwardItem `json:"issues"`
+}
+
+// RollForwardItem is one requirement, audit task or issue cloned from the
+// source project, with its status before and after and the fields that are
+// not carried over.
+type RollForwardItem struct {
+	SourceID   uint     `json:"sourceId"`
+	ID         *uint    `json:"id,omitempty"`
+	Text       string   `json:"text"`
+	FromStatus string   `json:"fromStatus"`
+	ToStatus   string   `json:"toStatus"`
+	Cleared    []string `json:"cleared,omitempty"`
+}
+
+// ProjectMembershipRequest sets a user's role on a project.
+type ProjectMembershipRequest struct {
+	Role string `json:"role" binding:"required,enum=projectRole"`
+}
+
+// ReviewQueueResponse lists the audit tasks a reviewer is reviewing and the
+// prepared ones they may pick up.
+type ReviewQueueResponse struct {
+	UserID   uint                `json:"userId"`
+	InReview []AuditTaskResponse `json:"inReview"`
+	Ready    []AuditTaskResponse `json:"ready"`
+}
+
+// UserWorkResponse is a user's queue of open audit tasks and issues across
+// projects: the ones assigned to them and the ones waiting on their review.
+type UserWorkResponse struct {
+	UserID         uint              `json:"userId"`
+	OpenAuditTasks int               `json:"openAuditTasks"`
+	OpenIssues     int               `json:"openIssues"`
+	ToReview       int               `json:"toReview"`
+	Overdue        int               `json:"overdue"`
+	EstimateHrs    int               `json:"estimateHrs"`
+	Projects       []UserWorkProject `json:"projects"`
+}
+
+type UserWorkProject struct {
+	ProjectID        uint                `json:"projectId"`
+	ProjectName      string              `json:"projectName"`
+	AuditTasks       []AuditTaskResponse `json:"auditTasks"`
+	Issues           []IssueResponse     `json:"issues"`
+	ReviewAuditTasks []AuditTaskResponse `json:"reviewAuditTasks"`
+	ReviewIssues     []IssueResponse     `json:"reviewIssues"`
+}
+
+// ProjectWorkloadResponse breaks a project's open work down by assignee.
+type ProjectWorkloadResponse struct {
+	ProjectID  uint            `json:"projectId"`
+	Members    []WorkloadEntry `json:"members"`
+	Unassigned WorkloadEntry   `json:"unassigned"`
+}
+
+// WorkloadEntry counts one person's open work on a project. EstimateHrs
+// sums the estimates of their open issues; UnestimatedIssues counts those
+// without one.
+type WorkloadEntry struct {
+	UserID            *uint         `json:"userId,omitempty"`
+	User              *UserResponse `json:"user,omitempty"`
+	OpenAuditTasks    int           `json:"openAuditTasks"`
+	OpenIssues        int           `json:"openIssues"`
+	EstimateHrs       int           `json:"estimateHrs"`
+	UnestimatedIssues int           `json:"unestimatedIssues"`
+	Overdue           int           `json:"overdue"`
+	ToReview          int           `json:"toReview"`
+}
+
+// SLAPolicyRequest sets the number of days allowed to fix an issue of each
+// priority. Issues of a priority left out get no due date from the policy.
+type SLAPolicyRequest struct {
+	Days map[string]int `json:"days" binding:"required"`
+}
+
+type SLAPolicyResponse struct {
+	ClientID uint           `json:"clientId"`
+	Source   string         `json:"source"` // "client" or "none"
+	Days     map[string]int `json:"days"`
+}
+
+// SLAComplianceResponse reports how a project's issues and audit tasks with
+// due dates are doing against them.
+type SLAComplianceResponse struct {
+	ProjectID           uint                            `json:"projectId"`
+	Policy              map[string]int                  `json:"policy"`
+	Issues              SLAComplianceSummary            `json:"issues"`
+	IssuesByPriority    map[string]SLAComplianceSummary `json:"issuesByPriority"`
+	AuditTasks          SLAComplianceSummary            `json:"auditTasks"`
+	OverdueIssueIDs     []uint                          `json:"overdueIssueIds"`
+	OverdueAuditTaskIDs []uint                          `json:"overdueAuditTaskIds"`
+}
+
+// SLAComplianceSummary counts items with a due date. Closed items are closed
+// issues or reviewed tasks. ComplianceRate is the share of items that met
+// their due date among those that met or missed it, and is left out while
+// none has.
+type SLAComplianceSummary struct {
+	WithDueDate    int      `json:"withDueDate"`
+	Open           int      `json:"open"`
+	Overdue        int      `json:"overdue"`
+	Closed         int      `json:"closed"`
+	ClosedOnTime   int      `json:"closedOnTime"`
+	ClosedLate     int      `json:"closedLate"`
+	ComplianceRate *float64 `json:"complianceRate,omitempty"`
+}
+
+// ProjectComplianceResponse counts a project's requirements by assessment
+// result. Compliance is the percentage of applicable requirements that are
+// met, directly or through a compensating control; NOT_APPLICABLE ones are
+// left out of it.
+type ProjectComplianceResponse struct {
+	ProjectID              uint           `json:"projectId"`
+	TotalRequirements      int            `json:"totalRequirements"`
+	ApplicableRequirements int            `json:"applicableRequirements"`
+	MetRequirements        int            `json:"metRequirements"`
+	ByStatus               map[string]int `json:"byStatus"`
+	Compliance             *float64       `json:"compliance,omitempty"`
+}
+
+type RiskHeatmapCell struct {
+	Likelihood int    `json:"likelihood"`
+	Impact     int    `json:"impact"`
+	Rating     string `json:"rating"`
+	Count      int    `json:"count"`
+	IssueIDs   []uint `json:"issueIds"`
+}
+
+type WorkflowResponse struct {
+	EntityType string              `json:"entityType"`
+	ProjectID  *uint               `json:"projectId,omitempty"`
+	Source     string              `json:"source"`
+	Definition workflow.Definition `json:"definition"`
+}
+
+type BatchOperation struct {
+	Method string          `json:"method" binding:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path   string          `json:"path" binding:"required"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=100,dive"`
+}
+
+type BatchOperationResult struct {
+	Index  int             `json:"index"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed   bool                   `json:"committed"`
+	FailedIndex *int                   `json:"failedIndex,omitempty"`
+	Results     []BatchOperationResult `json:"results"`
 }
 
 // Error response
@@ -139,4 +1035,4 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"code"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,134 @@
+package oscal
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// AssessmentResults reports the outcome of an assessment.
+type AssessmentResults struct {
+	XMLName    xml.Name    `json:"-" xml:"http://csrc.nist.gov/ns/oscal/1.0 assessment-results"`
+	UUID       string      `json:"uuid" xml:"uuid,attr"`
+	Metadata   Metadata    `json:"metadata" xml:"metadata"`
+	ImportAP   ImportRef   `json:"import-ap" xml:"import-ap"`
+	Results    []Result    `json:"results" xml:"result"`
+	BackMatter *BackMatter `json:"back-matter,omitempty" xml:"back-matter"`
+}
+
+// ImportRef points at the document an assessment artifact builds on.
+type ImportRef struct {
+	Href string `json:"href" xml:"href,attr"`
+}
+
+// Result is one assessment of a set of controls.
+type Result struct {
+	UUID             string           `json:"uuid" xml:"uuid,attr"`
+	Title            string           `json:"title" xml:"title"`
+	Description      Markup           `json:"description" xml:"description"`
+	Props            []Property       `json:"props,omitempty" xml:"prop"`
+	Start            time.Time        `json:"start" xml:"start"`
+	End              *time.Time       `json:"end,omitempty" xml:"end,omitempty"`
+	ReviewedControls ReviewedControls `json:"reviewed-controls" xml:"reviewed-controls"`
+	Observations     []Observation    `json:"observations,omitempty" xml:"observation"`
+	Risks            []Risk           `json:"risks,omitempty" xml:"risk"`
+	Findings         []Finding        `json:"findings,omitempty" xml:"finding"`
+}
+
+// ReviewedControls lists the controls an assessment covered.
+type ReviewedControls struct {
+	ControlSelections []ControlSelection `json:"control-selections" xml:"control-selection"`
+}
+
+type ControlSelection struct {
+	IncludeAll      *struct{}      `json:"include-all,omitempty" xml:"include-all"`
+	IncludeControls []ControlIDRef `json:"include-controls,omitempty" xml:"include-control"`
+}
+
+type ControlIDRef struct {
+	ControlID string `json:"control-id" xml:"control-id,attr"`
+}
+
+// Observation records what an assessor did and saw, here one per audit task.
+type Observation struct {
+	UUID        string     `json:"uuid" xml:"uuid,attr"`
+	Title       string     `json:"title,omitempty" xml:"title,omitempty"`
+	Description Markup     `json:"description" xml:"description"`
+	Props       []Property `json:"props,omitempty" xml:"prop"`
+	Methods     []string   `json:"methods" xml:"method"`
+	Collected   time.Time  `json:"collected" xml:"collected"`
+	Remarks     Markup     `json:"remarks,omitempty" xml:"remarks,omitempty"`
+}
+
+// Finding is the conclusion reached for one control objective.
+type Finding struct {
+	UUID                string               `json:"uuid" xml:"uuid,attr"`
+	Title               string               `json:"title" xml:"title"`
+	Description         Markup               `json:"description" xml:"description"`
+	Props               []Property           `json:"props,omitempty" xml:"prop"`
+	Target              FindingTarget        `json:"target" xml:"target"`
+	RelatedObservations []RelatedObservation `json:"related-observations,omitempty" xml:"related-observation"`
+	RelatedRisks        []RelatedRisk        `json:"related-risks,omitempty" xml:"related-risk"`
+}
+
+// FindingTarget is the control statement or objective a finding is about.
+type FindingTarget struct {
+	Type     string          `json:"type" xml:"type,attr"`
+	TargetID string          `json:"target-id" xml:"target-id,attr"`
+	Title    string          `json:"title,omitempty" xml:"title,omitempty"`
+	Status   ObjectiveStatus `json:"status" xml:"status"`
+}
+
+// ObjectiveStatus is "satisfied" or "not-satisfied", with "pass", "fail" or
+// "other" as the optional reason.
+type ObjectiveStatus struct {
+	State   string `json:"state" xml:"state,attr"`
+	Reason  string `json:"reason,omitempty" xml:"reason,attr,omitempty"`
+	Remarks Markup `json:"remarks,omitempty" xml:"remarks,omitempty"`
+}
+
+type RelatedObservation struct {
+	ObservationUUID string `json:"observation-uuid" xml:"observation-uuid,attr"`
+}
+
+type RelatedRisk struct {
+	RiskUUID string `json:"risk-uuid" xml:"risk-uuid,attr"`
+}
+
+// Risk is an identified weakness, here one per issue.
+type Risk struct {
+	UUID                string               `json:"uuid" xml:"uuid,attr"`
+	Title               string               `json:"title" xml:"title"`
+	Description         Markup               `json:"description" xml:"description"`
+	Statement           Markup               `json:"statement" xml:"statement"`
+	Props               []Property           `json:"props,omitempty" xml:"prop"`
+	Status              string               `json:"status" xml:"status"`
+	RelatedObservations []RelatedObservation `json:"related-observations,omitempty" xml:"related-observation"`
+}
+
+// PlanOfActionAndMilestones (POA&M) tracks the remediation of risks.
+type PlanOfActionAndMilestones struct {
+	XMLName      xml.Name      `json:"-" xml:"http://csrc.nist.gov/ns/oscal/1.0 plan-of-action-and-milestones"`
+	UUID         string        `json:"uuid" xml:"uuid,attr"`
+	Metadata     Metadata      `json:"metadata" xml:"metadata"`
+	SystemID     *SystemID     `json:"system-id,omitempty" xml:"system-id"`
+	Observations []Observation `json:"observations,omitempty" xml:"observation"`
+	Risks        []Risk        `json:"risks,omitempty" xml:"risk"`
+	PoamItems    []PoamItem    `json:"poam-items" xml:"poam-item"`
+	BackMatter   *BackMatter   `json:"back-matter,omitempty" xml:"back-matter"`
+}
+
+// SystemID identifies the system a POA&M is for when no SSP is imported.
+type SystemID struct {
+	IdentifierType string `json:"identifier-type,omitempty" xml:"identifier-type,attr,omitempty"`
+	ID             string `json:"id" xml:",chardata"`
+}
+
+// PoamItem is one planned remediation.
+type PoamItem struct {
+	UUID                string               `json:"uuid" xml:"uuid,attr"`
+	Title               string               `json:"title" xml:"title"`
+	Description         Markup               `json:"description" xml:"description"`
+	Props               []Property           `json:"props,omitempty" xml:"prop"`
+	RelatedObservations []RelatedObservation `json:"related-observations,omitempty" xml:"related-observation"`
+	RelatedRisks        []RelatedRisk        `json:"related-risks,omitempty" xml:"related-risk"`
+}
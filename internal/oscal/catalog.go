@@ -0,0 +1,480 @@
+package oscal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Catalog is an OSCAL control catalog.
+type Catalog struct {
+	XMLName    xml.Name    `json:"-" xml:"http://csrc.nist.gov/ns/oscal/1.0 catalog"`
+	UUID       string      `json:"uuid" xml:"uuid,attr"`
+	Metadata   Metadata    `json:"metadata" xml:"metadata"`
+	Params     []Parameter `json:"params,omitempty" xml:"param"`
+	Controls   []Control   `json:"controls,omitempty" xml:"control"`
+	Groups     []Group     `json:"groups,omitempty" xml:"group"`
+	BackMatter *BackMatter `json:"back-matter,omitempty" xml:"back-matter"`
+}
+
+// Group organizes the controls of a catalog, e.g. a control family.
+type Group struct {
+	ID       string      `json:"id,omitempty" xml:"id,attr,omitempty"`
+	Class    string      `json:"class,omitempty" xml:"class,attr,omitempty"`
+	Title    string      `json:"title" xml:"title"`
+	Params   []Parameter `json:"params,omitempty" xml:"param"`
+	Props    []Property  `json:"props,omitempty" xml:"prop"`
+	Parts    []Part      `json:"parts,omitempty" xml:"part"`
+	Groups   []Group     `json:"groups,omitempty" xml:"group"`
+	Controls []Control   `json:"controls,omitempty" xml:"control"`
+}
+
+// Control is a catalog control. Nested controls are enhancements.
+type Control struct {
+	ID       string      `json:"id" xml:"id,attr"`
+	Class    string      `json:"class,omitempty" xml:"class,attr,omitempty"`
+	Title    string      `json:"title" xml:"title"`
+	Params   []Parameter `json:"params,omitempty" xml:"param"`
+	Props    []Property  `json:"props,omitempty" xml:"prop"`
+	Parts    []Part      `json:"parts,omitempty" xml:"part"`
+	Controls []Control   `json:"controls,omitempty" xml:"control"`
+}
+
+// Label returns the control's display label, e.g. AC-2(1), falling back to
+// its ID.
+func (c *Control) Label() string {
+	for _, prop := range c.Props {
+		if prop.Name == "label" && prop.Value != "" {
+			return prop.Value
+		}
+	}
+	return c.ID
+}
+
+// Parameter is a value a control's prose refers to, set by the organization
+// or by a profile.
+type Parameter struct {
+	ID         string      `json:"id" xml:"id,attr"`
+	Class      string      `json:"class,omitempty" xml:"class,attr,omitempty"`
+	Label      string      `json:"label,omitempty" xml:"label,omitempty"`
+	Guidelines []Guideline `json:"guidelines,omitempty" xml:"guideline"`
+	Values     []string    `json:"values,omitempty" xml:"value"`
+	Select     *Selection  `json:"select,omitempty" xml:"select"`
+}
+
+type Guideline struct {
+	Prose Markup `json:"prose" xml:"prose"`
+}
+
+// Selection restricts a parameter to a set of choices.
+type Selection struct {
+	HowMany string   `json:"how-many,omitempty" xml:"how-many,attr,omitempty"`
+	Choice  []string `json:"choice,omitempty" xml:"choice"`
+}
+
+// Part is a named piece of control text, e.g. the statement or guidance.
+// Statement parts nest to form lettered items.
+type Part struct {
+	ID    string     `json:"id,omitempty"`
+	Name  string     `json:"name"`
+	NS    string     `json:"ns,omitempty"`
+	Class string     `json:"class,omitempty"`
+	Title string     `json:"title,omitempty"`
+	Props []Property `json:"props,omitempty"`
+	Prose string     `json:"prose,omitempty"`
+	Parts []Part     `json:"parts,omitempty"`
+}
+
+// UnmarshalXML reads a part, whose prose is inline block markup mixed with
+// its title, props and sub-parts.
+func (p *Part) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "id":
+			p.ID = attr.Value
+		case "name":
+			p.Name = attr.Value
+		case "ns":
+			p.NS = attr.Value
+		case "class":
+			p.Class = attr.Value
+		}
+	}
+
+	var prose []string
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "title":
+				if err := d.DecodeElement(&p.Title, &t); err != nil {
+					return err
+				}
+			case "prop":
+				var prop Property
+				if err := d.DecodeElement(&prop, &t); err != nil {
+					return err
+				}
+				p.Props = append(p.Props, prop)
+			case "part":
+				var part Part
+				if err := d.DecodeElement(&part, &t); err != nil {
+					return err
+				}
+				p.Parts = append(p.Parts, part)
+			case "link":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				text, err := readProse(d, t)
+				if err != nil {
+					return err
+				}
+				if text != "" {
+					prose = append(prose, text)
+				}
+			}
+		case xml.EndElement:
+			p.Prose = strings.Join(prose, "\n\n")
+			return nil
+		}
+	}
+}
+
+// MarshalXML writes the part with its prose as paragraphs.
+func (p Part) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = nil
+	for _, attr := range [][2]string{{"id", p.ID}, {"name", p.Name}, {"ns", p.NS}, {"class", p.Class}} {
+		if attr[1] != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr[0]}, Value: attr[1]})
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if p.Title != "" {
+		if err := e.EncodeElement(p.Title, xml.StartElement{Name: xml.Name{Local: "title"}}); err != nil {
+			return err
+		}
+	}
+	for _, prop := range p.Props {
+		if err := e.EncodeElement(prop, xml.StartElement{Name: xml.Name{Local: "prop"}}); err != nil {
+			return err
+		}
+	}
+	for _, paragraph := range strings.Split(p.Prose, "\n\n") {
+		if paragraph == "" {
+			continue
+		}
+		if err := e.EncodeElement(paragraph, xml.StartElement{Name: xml.Name{Local: "p"}}); err != nil {
+			return err
+		}
+	}
+	for _, part := range p.Parts {
+		if err := e.EncodeElement(part, xml.StartElement{Name: xml.Name{Local: "part"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Profile selects and tailors controls from one or more catalogs, e.g. a
+// FedRAMP baseline over NIST SP 800-53.
+type Profile struct {
+	XMLName    xml.Name    `json:"-" xml:"http://csrc.nist.gov/ns/oscal/1.0 profile"`
+	UUID       string      `json:"uuid" xml:"uuid,attr"`
+	Metadata   Metadata    `json:"metadata" xml:"metadata"`
+	Imports    []Import    `json:"imports" xml:"import"`
+	Modify     *Modify     `json:"modify,omitempty" xml:"modify"`
+	BackMatter *BackMatter `json:"back-matter,omitempty" xml:"back-matter"`
+}
+
+// Import pulls controls from the catalog or profile at Href.
+type Import struct {
+	Href            string          `json:"href" xml:"href,attr"`
+	IncludeAll      *struct{}       `json:"include-all,omitempty" xml:"include-all"`
+	IncludeControls []SelectControl `json:"include-controls,omitempty" xml:"include-controls"`
+	ExcludeControls []SelectControl `json:"exclude-controls,omitempty" xml:"exclude-controls"`
+}
+
+// SelectControl selects controls by ID. With child controls "yes", the
+// enhancements of the selected controls are selected too.
+type SelectControl struct {
+	WithChildControls string   `json:"with-child-controls,omitempty" xml:"with-child-controls,attr,omitempty"`
+	WithIDs           []string `json:"with-ids,omitempty" xml:"with-id"`
+}
+
+// Modify tailors the imported controls.
+type Modify struct {
+	SetParameters []SetParameter `json:"set-parameters,omitempty" xml:"set-parameter"`
+}
+
+// SetParameter overrides the value or label of a parameter.
+type SetParameter struct {
+	ParamID string   `json:"param-id" xml:"param-id,attr"`
+	Label   string   `json:"label,omitempty" xml:"label,omitempty"`
+	Values  []string `json:"values,omitempty" xml:"value"`
+}
+
+// ResolvedControl is a control selected for import, with the title of the
+// group it was found in and its prose rendered with parameter values.
+type ResolvedControl struct {
+	ID        string
+	Label     string
+	Title     string
+	Group     string
+	Statement string
+}
+
+// AllControls returns every control of the catalog, enhancements included,
+// in document order. Statements still contain parameter placeholders.
+func (c *Catalog) AllControls() []ResolvedControl {
+	var out []ResolvedControl
+	walkControls(c.Controls, "", &out)
+	for _, group := range c.Groups {
+		walkGroup(group, &out)
+	}
+	return out
+}
+
+func walkGroup(group Group, out *[]ResolvedControl) {
+	walkControls(group.Controls, group.Title, out)
+	for _, child := range group.Groups {
+		walkGroup(child, out)
+	}
+}
+
+func walkControls(controls []Control, group string, out *[]ResolvedControl) {
+	for _, control := range controls {
+		*out = append(*out, ResolvedControl{
+			ID:        control.ID,
+			Label:     control.Label(),
+			Title:     control.Title,
+			Group:     group,
+			Statement: statementTemplate(control.Parts),
+		})
+		walkControls(control.Controls, group, out)
+	}
+}
+
+func collectParams(params map[string]Parameter, list []Parameter) {
+	for _, param := range list {
+		params[param.ID] = param
+	}
+}
+
+// Resolve applies a profile to the catalogs it imports. catalogs are keyed by
+// the name they were uploaded under; an import's href is matched against
+// those names, against "#<uuid>" back-matter references to a catalog's UUID,
+// and finally, when only one catalog was supplied, to that catalog.
+func (p *Profile) Resolve(catalogs map[string]*Catalog) ([]ResolvedControl, error) {
+	var out []ResolvedControl
+	seen := map[string]bool{}
+	params := map[string]Parameter{}
+
+	for i, imp := range p.Imports {
+		catalog := findCatalog(imp.Href, catalogs)
+		if catalog == nil {
+			return nil, fmt.Errorf("imports[%d]: catalog %q was not supplied", i, imp.Href)
+		}
+
+		collectCatalogParams(catalog, params)
+		selected := selectControls(catalog, imp)
+		for _, control := range catalog.AllControls() {
+			if selected[control.ID] && !seen[control.ID] {
+				seen[control.ID] = true
+				out = append(out, control)
+			}
+		}
+	}
+
+	if p.Modify != nil {
+		for _, set := range p.Modify.SetParameters {
+			param := params[set.ParamID]
+			param.ID = set.ParamID
+			if set.Label != "" {
+				param.Label = set.Label
+			}
+			if len(set.Values) > 0 {
+				param.Values = set.Values
+				param.Select = nil
+			}
+			params[set.ParamID] = param
+		}
+	}
+
+	renderStatements(out, params)
+	return out, nil
+}
+
+// ResolvedControls returns the catalog's controls with their statements
+// rendered using the catalog's own parameter values.
+func (c *Catalog) ResolvedControls() []ResolvedControl {
+	params := map[string]Parameter{}
+	collectCatalogParams(c, params)
+	controls := c.AllControls()
+	renderStatements(controls, params)
+	return controls
+}
+
+func collectCatalogParams(catalog *Catalog, params map[string]Parameter) {
+	collectParams(params, catalog.Params)
+	var walk func(groups []Group, controls []Control)
+	walk = func(groups []Group, controls []Control) {
+		for _, control := range controls {
+			collectParams(params, control.Params)
+			walk(nil, control.Controls)
+		}
+		for _, group := range groups {
+			collectParams(params, group.Params)
+			walk(group.Groups, group.Controls)
+		}
+	}
+	walk(catalog.Groups, catalog.Controls)
+}
+
+func findCatalog(href string, catalogs map[string]*Catalog) *Catalog {
+	if catalog, ok := catalogs[path.Base(href)]; ok {
+		return catalog
+	}
+	if strings.HasPrefix(href, "#") {
+		for _, catalog := range catalogs {
+			if catalog.UUID == strings.TrimPrefix(href, "#") {
+				return catalog
+			}
+		}
+	}
+	if len(catalogs) == 1 {
+		for _, catalog := range catalogs {
+			return catalog
+		}
+	}
+	return nil
+}
+
+// selectControls returns the IDs of the catalog controls an import selects.
+func selectControls(catalog *Catalog, imp Import) map[string]bool {
+	children := map[string][]string{}
+	var index func(controls []Control)
+	index = func(controls []Control) {
+		for _, control := range controls {
+			for _, child := range control.Controls {
+				children[control.ID] = append(children[control.ID], child.ID)
+			}
+			index(control.Controls)
+		}
+	}
+	index(catalog.Controls)
+	var indexGroups func(groups []Group)
+	indexGroups = func(groups []Group) {
+		for _, group := range groups {
+			index(group.Controls)
+			indexGroups(group.Groups)
+		}
+	}
+	indexGroups(catalog.Groups)
+
+	var mark func(selected map[string]bool, id string, withChildren bool)
+	mark = func(selected map[string]bool, id string, withChildren bool) {
+		selected[id] = true
+		if withChildren {
+			for _, child := range children[id] {
+				mark(selected, child, true)
+			}
+		}
+	}
+
+	selected := map[string]bool{}
+	if imp.IncludeAll != nil {
+		for _, control := range catalog.AllControls() {
+			selected[control.ID] = true
+		}
+	}
+	for _, include := range imp.IncludeControls {
+		for _, id := range include.WithIDs {
+			mark(selected, id, include.WithChildControls == "yes")
+		}
+	}
+
+	excluded := map[string]bool{}
+	for _, exclude := range imp.ExcludeControls {
+		for _, id := range exclude.WithIDs {
+			mark(excluded, id, exclude.WithChildControls == "yes")
+		}
+	}
+	for id := range excluded {
+		delete(selected, id)
+	}
+	return selected
+}
+
+// statementTemplate flattens a control's statement part, with lettered items
+// on their own lines, leaving parameter placeholders in place.
+func statementTemplate(parts []Part) string {
+	for _, part := range parts {
+		if part.Name == "statement" {
+			return strings.TrimSpace(flattenPart(part, ""))
+		}
+	}
+	return ""
+}
+
+func flattenPart(part Part, indent string) string {
+	var b strings.Builder
+	label := ""
+	for _, prop := range part.Props {
+		if prop.Name == "label" {
+			label = prop.Value + " "
+		}
+	}
+	if part.Prose != "" {
+		b.WriteString(indent + label + part.Prose + "\n")
+	}
+	childIndent := indent
+	if part.Name == "item" {
+		childIndent = indent + "  "
+	}
+	for _, child := range part.Parts {
+		if child.Name == "item" || child.Name == "statement" {
+			b.WriteString(flattenPart(child, childIndent))
+		}
+	}
+	return b.String()
+}
+
+var insertPattern = regexp.MustCompile(`\{\{\s*insert:\s*param,\s*([^\s}]+)\s*\}\}`)
+
+// renderStatements substitutes parameter placeholders the way NIST renders
+// them: set values verbatim, otherwise [Selection: ...] or [Assignment: ...].
+func renderStatements(controls []ResolvedControl, params map[string]Parameter) {
+	for i := range controls {
+		controls[i].Statement = insertPattern.ReplaceAllStringFunc(controls[i].Statement, func(match string) string {
+			id := insertPattern.FindStringSubmatch(match)[1]
+			return renderParam(params[id], id)
+		})
+	}
+}
+
+func renderParam(param Parameter, id string) string {
+	switch {
+	case len(param.Values) > 0:
+		return strings.Join(param.Values, ", ")
+	case param.Select != nil && len(param.Select.Choice) > 0:
+		prefix := "Selection"
+		if param.Select.HowMany == "one-or-more" {
+			prefix = "Selection (one or more)"
+		}
+		return fmt.Sprintf("[%s: %s]", prefix, strings.Join(param.Select.Choice, "; "))
+	case param.Label != "":
+		return fmt.Sprintf("[Assignment: %s]", param.Label)
+	default:
+		return fmt.Sprintf("[Assignment: %s]", id)
+	}
+}
@@ -0,0 +1,292 @@
+// Package oscal models the subset of NIST OSCAL (Open Security Controls
+// Assessment Language) that tessellate-projects exchanges with clients:
+// catalogs and profiles on import, assessment results and POA&M documents on
+// export. Every model round-trips through both the JSON and the XML
+// representation.
+package oscal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// Version is the OSCAL release the exported documents conform to.
+	Version = "1.1.2"
+
+	// Namespace is the XML namespace of OSCAL documents.
+	Namespace = "http://csrc.nist.gov/ns/oscal/1.0"
+
+	// PropertyNamespace qualifies the properties this application adds to
+	// exported documents.
+	PropertyNamespace = "urn:tessellate-projects:oscal"
+)
+
+// Format is the serialization of an OSCAL document.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml"
+)
+
+// ErrUnknownDocument is returned for input that is neither an OSCAL
+// catalog nor a profile.
+var ErrUnknownDocument = errors.New("document is not an OSCAL catalog or profile")
+
+// Metadata is the metadata block every OSCAL document starts with.
+type Metadata struct {
+	Title        string     `json:"title" xml:"title"`
+	Published    *time.Time `json:"published,omitempty" xml:"published,omitempty"`
+	LastModified time.Time  `json:"last-modified" xml:"last-modified"`
+	Version      string     `json:"version" xml:"version"`
+	OscalVersion string     `json:"oscal-version" xml:"oscal-version"`
+	Props        []Property `json:"props,omitempty" xml:"prop"`
+}
+
+// Property is a name/value pair attached to most OSCAL objects.
+type Property struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Value string `json:"value" xml:"value,attr"`
+	NS    string `json:"ns,omitempty" xml:"ns,attr,omitempty"`
+	Class string `json:"class,omitempty" xml:"class,attr,omitempty"`
+}
+
+// BackMatter holds resources referenced from the rest of a document.
+type BackMatter struct {
+	Resources []Resource `json:"resources,omitempty" xml:"resource"`
+}
+
+// Resource is a back-matter entry, addressed elsewhere as "#<uuid>".
+type Resource struct {
+	UUID        string `json:"uuid" xml:"uuid,attr"`
+	Title       string `json:"title,omitempty" xml:"title,omitempty"`
+	Description Markup `json:"description,omitempty" xml:"description,omitempty"`
+}
+
+// Markup is OSCAL markup-multiline content. In JSON it is a Markdown string;
+// in XML it is a sequence of block elements, written here as one <p> per
+// paragraph.
+type Markup string
+
+func (m Markup) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, paragraph := range strings.Split(string(m), "\n\n") {
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		if err := e.EncodeElement(paragraph, xml.StartElement{Name: xml.Name{Local: "p"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (m *Markup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var paragraphs []string
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			text, err := readProse(d, t)
+			if err != nil {
+				return err
+			}
+			if text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		case xml.EndElement:
+			*m = Markup(strings.Join(paragraphs, "\n\n"))
+			return nil
+		}
+	}
+}
+
+// readProse flattens a block of OSCAL XML markup (p, ol, li, em, insert, ...)
+// into Markdown-like text. Parameter insertions become the same
+// "{{ insert: param, id }}" placeholders the JSON format uses.
+func readProse(d *xml.Decoder, start xml.StartElement) (string, error) {
+	var text strings.Builder
+	if start.Name.Local == "insert" {
+		text.WriteString(insertPlaceholder(start))
+	}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			inner, err := readProse(d, t)
+			if err != nil {
+				return "", err
+			}
+			if isBlockElement(t.Name.Local) && text.Len() > 0 {
+				text.WriteString("\n")
+			}
+			text.WriteString(inner)
+		case xml.CharData:
+			text.WriteString(string(t))
+		case xml.EndElement:
+			return collapseSpace(text.String()), nil
+		}
+	}
+}
+
+// collapseSpace normalizes the whitespace of each line and drops blank lines.
+func collapseSpace(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func insertPlaceholder(start xml.StartElement) string {
+	kind, id := "param", ""
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "type":
+			kind = attr.Value
+		case "id-ref":
+			id = attr.Value
+		}
+	}
+	return fmt.Sprintf("{{ insert: %s, %s }}", kind, id)
+}
+
+func isBlockElement(name string) bool {
+	switch name {
+	case "p", "li", "ol", "ul", "pre", "table", "tr", "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	}
+	return false
+}
+
+// Document is a parsed import document; exactly one field is set.
+type Document struct {
+	Catalog *Catalog
+	Profile *Profile
+}
+
+// Parse decodes an OSCAL catalog or profile in either JSON or XML, detected
+// from the content.
+func Parse(data []byte) (*Document, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, ErrUnknownDocument
+	}
+
+	if trimmed[0] == '{' {
+		var wrapper struct {
+			Catalog *Catalog `json:"catalog"`
+			Profile *Profile `json:"profile"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid OSCAL JSON: %w", err)
+		}
+		if wrapper.Catalog == nil && wrapper.Profile == nil {
+			return nil, ErrUnknownDocument
+		}
+		return &Document{Catalog: wrapper.Catalog, Profile: wrapper.Profile}, nil
+	}
+
+	root, err := rootElement(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OSCAL XML: %w", err)
+	}
+	if root.Space != Namespace {
+		return nil, fmt.Errorf("invalid OSCAL XML: root element must be in the %s namespace", Namespace)
+	}
+	switch root.Local {
+	case "catalog":
+		var catalog Catalog
+		if err := xml.Unmarshal(trimmed, &catalog); err != nil {
+			return nil, fmt.Errorf("invalid OSCAL XML: %w", err)
+		}
+		return &Document{Catalog: &catalog}, nil
+	case "profile":
+		var profile Profile
+		if err := xml.Unmarshal(trimmed, &profile); err != nil {
+			return nil, fmt.Errorf("invalid OSCAL XML: %w", err)
+		}
+		return &Document{Profile: &profile}, nil
+	}
+	return nil, ErrUnknownDocument
+}
+
+func rootElement(data []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return xml.Name{}, errors.New("no root element")
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// Marshal serializes an exported document. JSON documents are wrapped in an
+// object keyed by the document's root name, e.g. {"assessment-results": ...}.
+func Marshal(root string, document interface{}, format Format) ([]byte, error) {
+	if format == FormatXML {
+		out, err := xml.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), out...), nil
+	}
+	return json.MarshalIndent(map[string]interface{}{root: document}, "", "  ")
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b[:])
+}
+
+// nameSpaceUUID is the namespace for the name-based UUIDs of exported objects.
+var nameSpaceUUID = []byte("tessellate-projects/oscal")
+
+// NameUUID returns a name-based (version 5) UUID, so an entity keeps the same
+// UUID across exports.
+func NameUUID(name string) string {
+	h := sha1.New()
+	h.Write(nameSpaceUUID)
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,334 @@
+package oscal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The OSCAL schemas constrain identifiers with these patterns.
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[45][0-9A-Fa-f]{3}-[89ABab][0-9A-Fa-f]{3}-[0-9A-Fa-f]{12}$`)
+	tokenPattern = regexp.MustCompile(`^(\p{L}|_)(\p{L}|\p{N}|[.\-_])*$`)
+)
+
+var (
+	observationMethods = []string{"EXAMINE", "INTERVIEW", "TEST", "UNKNOWN"}
+	findingTargetTypes = []string{"statement-id", "objective-id"}
+	objectiveStates    = []string{"satisfied", "not-satisfied"}
+	objectiveReasons   = []string{"pass", "fail", "other"}
+	riskStatuses       = []string{"open", "investigating", "remediating", "deviation-requested", "deviation-approved", "closed"}
+)
+
+// ValidationError lists every constraint a document violates.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid OSCAL document: " + strings.Join(e.Problems, "; ")
+}
+
+// IsToken reports whether s is a valid OSCAL token, the type used for
+// control IDs.
+func IsToken(s string) bool {
+	return tokenPattern.MatchString(s)
+}
+
+// validator collects problems found while walking a document.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(path, "is required")
+	}
+}
+
+func (v *validator) uuid(path, value string) {
+	if !uuidPattern.MatchString(value) {
+		v.fail(path, "%q is not a valid UUID", value)
+	}
+}
+
+func (v *validator) token(path, value string) {
+	if !tokenPattern.MatchString(value) {
+		v.fail(path, "%q is not a valid token", value)
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(path, "%q must be one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) metadata(path string, m Metadata) {
+	v.required(path+".title", m.Title)
+	if m.LastModified.IsZero() {
+		v.fail(path+".last-modified", "is required")
+	}
+	v.required(path+".version", m.Version)
+	v.required(path+".oscal-version", m.OscalVersion)
+	v.props(path+".props", m.Props)
+}
+
+func (v *validator) props(path string, props []Property) {
+	for i, prop := range props {
+		v.token(fmt.Sprintf("%s[%d].name", path, i), prop.Name)
+		v.required(fmt.Sprintf("%s[%d].value", path, i), prop.Value)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Validate checks a catalog against the constraints of the OSCAL catalog
+// model: required fields, identifier formats and unique control IDs.
+func (c *Catalog) Validate() error {
+	v := &validator{}
+	v.uuid("catalog.uuid", c.UUID)
+	v.metadata("catalog.metadata", c.Metadata)
+
+	ids := map[string]bool{}
+	var controls func(path string, list []Control)
+	controls = func(path string, list []Control) {
+		for i, control := range list {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			v.token(p+".id", control.ID)
+			if ids[control.ID] {
+				v.fail(p+".id", "duplicate control ID %q", control.ID)
+			}
+			ids[control.ID] = true
+			v.required(p+".title", control.Title)
+			v.params(p+".params", control.Params)
+			v.props(p+".props", control.Props)
+			v.parts(p+".parts", control.Parts)
+			controls(p+".controls", control.Controls)
+		}
+	}
+	var groups func(path string, list []Group)
+	groups = func(path string, list []Group) {
+		for i, group := range list {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if group.ID != "" {
+				v.token(p+".id", group.ID)
+			}
+			v.required(p+".title", group.Title)
+			v.params(p+".params", group.Params)
+			v.parts(p+".parts", group.Parts)
+			controls(p+".controls", group.Controls)
+			groups(p+".groups", group.Groups)
+		}
+	}
+
+	v.params("catalog.params", c.Params)
+	controls("catalog.controls", c.Controls)
+	groups("catalog.groups", c.Groups)
+	return v.err()
+}
+
+func (v *validator) params(path string, params []Parameter) {
+	for i, param := range params {
+		v.token(fmt.Sprintf("%s[%d].id", path, i), param.ID)
+		if param.Select != nil && param.Select.HowMany != "" {
+			v.oneOf(fmt.Sprintf("%s[%d].select.how-many", path, i), param.Select.HowMany, []string{"one", "one-or-more"})
+		}
+	}
+}
+
+func (v *validator) parts(path string, parts []Part) {
+	for i, part := range parts {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if part.ID != "" {
+			v.token(p+".id", part.ID)
+		}
+		v.token(p+".name", part.Name)
+		v.props(p+".props", part.Props)
+		v.parts(p+".parts", part.Parts)
+	}
+}
+
+// Validate checks a profile against the constraints of the OSCAL profile
+// model.
+func (p *Profile) Validate() error {
+	v := &validator{}
+	v.uuid("profile.uuid", p.UUID)
+	v.metadata("profile.metadata", p.Metadata)
+	if len(p.Imports) == 0 {
+		v.fail("profile.imports", "at least one import is required")
+	}
+	for i, imp := range p.Imports {
+		path := fmt.Sprintf("profile.imports[%d]", i)
+		v.required(path+".href", imp.Href)
+		if imp.IncludeAll == nil && len(imp.IncludeControls) == 0 {
+			v.fail(path, "include-all or include-controls is required")
+		}
+		for j, sel := range append(append([]SelectControl{}, imp.IncludeControls...), imp.ExcludeControls...) {
+			for k, id := range sel.WithIDs {
+				v.token(fmt.Sprintf("%s.controls[%d].with-ids[%d]", path, j, k), id)
+			}
+		}
+	}
+	if p.Modify != nil {
+		for i, set := range p.Modify.SetParameters {
+			v.token(fmt.Sprintf("profile.modify.set-parameters[%d].param-id", i), set.ParamID)
+		}
+	}
+	return v.err()
+}
+
+// Validate checks assessment results against the constraints of the OSCAL
+// assessment results model, including references between observations,
+// risks and findings.
+func (a *AssessmentResults) Validate() error {
+	v := &validator{}
+	v.uuid("assessment-results.uuid", a.UUID)
+	v.metadata("assessment-results.metadata", a.Metadata)
+	v.required("assessment-results.import-ap.href", a.ImportAP.Href)
+	if len(a.Results) == 0 {
+		v.fail("assessment-results.results", "at least one result is required")
+	}
+
+	for i, result := range a.Results {
+		path := fmt.Sprintf("assessment-results.results[%d]", i)
+		v.uuid(path+".uuid", result.UUID)
+		v.required(path+".title", result.Title)
+		v.required(path+".description", string(result.Description))
+		if result.Start.IsZero() {
+			v.fail(path+".start", "is required")
+		}
+		if result.End != nil && result.End.Before(result.Start) {
+			v.fail(path+".end", "is before start")
+		}
+		v.props(path+".props", result.Props)
+		v.reviewedControls(path+".reviewed-controls", result.ReviewedControls)
+
+		observations := v.observations(path+".observations", result.Observations)
+		risks := v.risks(path+".risks", result.Risks, observations)
+		for j, finding := range result.Findings {
+			p := fmt.Sprintf("%s.findings[%d]", path, j)
+			v.uuid(p+".uuid", finding.UUID)
+			v.required(p+".title", finding.Title)
+			v.required(p+".description", string(finding.Description))
+			v.props(p+".props", finding.Props)
+			v.oneOf(p+".target.type", finding.Target.Type, findingTargetTypes)
+			v.token(p+".target.target-id", finding.Target.TargetID)
+			v.oneOf(p+".target.status.state", finding.Target.Status.State, objectiveStates)
+			if finding.Target.Status.Reason != "" {
+				v.oneOf(p+".target.status.reason", finding.Target.Status.Reason, objectiveReasons)
+			}
+			v.relatedObservations(p+".related-observations", finding.RelatedObservations, observations)
+			for k, related := range finding.RelatedRisks {
+				if !risks[related.RiskUUID] {
+					v.fail(fmt.Sprintf("%s.related-risks[%d].risk-uuid", p, k), "refers to unknown risk %q", related.RiskUUID)
+				}
+			}
+		}
+	}
+	return v.err()
+}
+
+func (v *validator) reviewedControls(path string, reviewed ReviewedControls) {
+	if len(reviewed.ControlSelections) == 0 {
+		v.fail(path+".control-selections", "at least one control selection is required")
+	}
+	for i, selection := range reviewed.ControlSelections {
+		p := fmt.Sprintf("%s.control-selections[%d]", path, i)
+		if selection.IncludeAll == nil && len(selection.IncludeControls) == 0 {
+			v.fail(p, "include-all or include-controls is required")
+		}
+		for j, control := range selection.IncludeControls {
+			v.token(fmt.Sprintf("%s.include-controls[%d].control-id", p, j), control.ControlID)
+		}
+	}
+}
+
+func (v *validator) observations(path string, observations []Observation) map[string]bool {
+	uuids := map[string]bool{}
+	for i, observation := range observations {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		v.uuid(p+".uuid", observation.UUID)
+		uuids[observation.UUID] = true
+		v.required(p+".description", string(observation.Description))
+		v.props(p+".props", observation.Props)
+		if len(observation.Methods) == 0 {
+			v.fail(p+".methods", "at least one method is required")
+		}
+		for j, method := range observation.Methods {
+			v.oneOf(fmt.Sprintf("%s.methods[%d]", p, j), method, observationMethods)
+		}
+		if observation.Collected.IsZero() {
+			v.fail(p+".collected", "is required")
+		}
+	}
+	return uuids
+}
+
+func (v *validator) risks(path string, risks []Risk, observations map[string]bool) map[string]bool {
+	uuids := map[string]bool{}
+	for i, risk := range risks {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		v.uuid(p+".uuid", risk.UUID)
+		uuids[risk.UUID] = true
+		v.required(p+".title", risk.Title)
+		v.required(p+".description", string(risk.Description))
+		v.required(p+".statement", string(risk.Statement))
+		v.props(p+".props", risk.Props)
+		v.oneOf(p+".status", risk.Status, riskStatuses)
+		v.relatedObservations(p+".related-observations", risk.RelatedObservations, observations)
+	}
+	return uuids
+}
+
+func (v *validator) relatedObservations(path string, related []RelatedObservation, observations map[string]bool) {
+	for i, r := range related {
+		if !observations[r.ObservationUUID] {
+			v.fail(fmt.Sprintf("%s[%d].observation-uuid", path, i), "refers to unknown observation %q", r.ObservationUUID)
+		}
+	}
+}
+
+// Validate checks a POA&M against the constraints of the OSCAL plan of action
+// and milestones model.
+func (p *PlanOfActionAndMilestones) Validate() error {
+	v := &validator{}
+	v.uuid("plan-of-action-and-milestones.uuid", p.UUID)
+	v.metadata("plan-of-action-and-milestones.metadata", p.Metadata)
+	if p.SystemID == nil || p.SystemID.ID == "" {
+		v.fail("plan-of-action-and-milestones.system-id", "is required when no system security plan is imported")
+	}
+
+	observations := v.observations("plan-of-action-and-milestones.observations", p.Observations)
+	risks := v.risks("plan-of-action-and-milestones.risks", p.Risks, observations)
+
+	if len(p.PoamItems) == 0 {
+		v.fail("plan-of-action-and-milestones.poam-items", "at least one POA&M item is required")
+	}
+	for i, item := range p.PoamItems {
+		path := fmt.Sprintf("plan-of-action-and-milestones.poam-items[%d]", i)
+		v.uuid(path+".uuid", item.UUID)
+		v.required(path+".title", item.Title)
+		v.required(path+".description", string(item.Description))
+		v.props(path+".props", item.Props)
+		v.relatedObservations(path+".related-observations", item.RelatedObservations, observations)
+		for j, related := range item.RelatedRisks {
+			if !risks[related.RiskUUID] {
+				v.fail(fmt.Sprintf("%s.related-risks[%d].risk-uuid", path, j), "refers to unknown risk %q", related.RiskUUID)
+			}
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,89 @@
+// Package risk rates issues on a likelihood × impact matrix. Each client can
+// configure its own matrix; clients without one use Default.
+package risk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Size limits for a matrix axis.
+const (
+	MinLevels = 2
+	MaxLevels = 10
+)
+
+var ErrOutOfRange = errors.New("score is outside the risk matrix")
+
+// Matrix maps every likelihood and impact pair onto a rating. Levels are
+// numbered from 1, lowest first; the labels name them for reports.
+type Matrix struct {
+	Likelihoods []string `json:"likelihoods"`
+	Impacts     []string `json:"impacts"`
+	// Ratings[l-1][i-1] is the rating for likelihood l and impact i
+	Ratings [][]string `json:"ratings"`
+}
+
+// Default is the 5×5 matrix used when a client has not configured one. A
+// cell is rated by the product of its levels: up to 4 is LOW, up to 9
+// MEDIUM, up to 16 HIGH and above that CRITICAL.
+var Default = Matrix{
+	Likelihoods: []string{"Rare", "Unlikely", "Possible", "Likely", "Almost certain"},
+	Impacts:     []string{"Insignificant", "Minor", "Moderate", "Major", "Severe"},
+	Ratings: [][]string{
+		{"LOW", "LOW", "LOW", "LOW", "MEDIUM"},
+		{"LOW", "LOW", "MEDIUM", "MEDIUM", "HIGH"},
+		{"LOW", "MEDIUM", "MEDIUM", "HIGH", "HIGH"},
+		{"LOW", "MEDIUM", "HIGH", "HIGH", "CRITICAL"},
+		{"MEDIUM", "HIGH", "HIGH", "CRITICAL", "CRITICAL"},
+	},
+}
+
+// Validate checks the matrix shape and that every cell uses one of the given
+// ratings, which are the values the database accepts.
+func (m Matrix) Validate(allowedRatings []string) error {
+	if len(m.Likelihoods) < MinLevels || len(m.Likelihoods) > MaxLevels {
+		return fmt.Errorf("matrix must have between %d and %d likelihood levels", MinLevels, MaxLevels)
+	}
+	if len(m.Impacts) < MinLevels || len(m.Impacts) > MaxLevels {
+		return fmt.Errorf("matrix must have between %d and %d impact levels", MinLevels, MaxLevels)
+	}
+	if len(m.Ratings) != len(m.Likelihoods) {
+		return fmt.Errorf("matrix must have a row of ratings for each of its %d likelihood levels", len(m.Likelihoods))
+	}
+	for l, row := range m.Ratings {
+		if len(row) != len(m.Impacts) {
+			return fmt.Errorf("likelihood %d must have a rating for each of the %d impact levels", l+1, len(m.Impacts))
+		}
+		for i, rating := range row {
+			if !contains(allowedRatings, rating) {
+				return fmt.Errorf("likelihood %d, impact %d has unknown rating %q", l+1, i+1, rating)
+			}
+		}
+	}
+	return nil
+}
+
+// Rate returns the rating of a likelihood and impact pair.
+func (m Matrix) Rate(likelihood, impact int) (string, error) {
+	if likelihood < 1 || likelihood > len(m.Likelihoods) || impact < 1 || impact > len(m.Impacts) {
+		return "", fmt.Errorf("%w: likelihood %d, impact %d on a %d×%d matrix",
+			ErrOutOfRange, likelihood, impact, len(m.Likelihoods), len(m.Impacts))
+	}
+	return m.Ratings[likelihood-1][impact-1], nil
+}
+
+// Score is the product of likelihood and impact, used to order risks that
+// share a rating.
+func Score(likelihood, impact int) int {
+	return likelihood * impact
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
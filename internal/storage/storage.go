@@ -0,0 +1,73 @@
+// Package storage keeps binary objects, such as audit evidence, outside the
+// database. Objects are addressed by slash-separated keys and can live on
+// the local filesystem or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned by Get when no object has the key.
+	ErrNotFound = errors.New("storage: object not found")
+
+	// ErrExists is returned by Put when an object already has the key.
+	ErrExists = errors.New("storage: object already exists")
+)
+
+// Storage is a flat object store.
+type Storage interface {
+	// Put stores size bytes read from body under key. Objects are
+	// write-once: Put never replaces an existing object.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get opens the object stored under key. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object stored under key. Deleting a missing object
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv builds the backend selected by STORAGE_BACKEND: "local" (the
+// default) stores objects below STORAGE_PATH, "s3" in the S3_BUCKET bucket of
+// the store at S3_ENDPOINT.
+func FromEnv() (Storage, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		root := os.Getenv("STORAGE_PATH")
+		if root == "" {
+			root = "storage"
+		}
+		return NewLocal(root)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			VirtualHosted:   os.Getenv("S3_VIRTUAL_HOSTED") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", backend)
+	}
+}
+
+// validKey rejects keys that are empty, absolute or escape their prefix.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package workflow
+
+var staff = []string{"ADMIN", "CONSULTANT"}
+
+var assessmentResults = []string{"NOT_TESTED", "NOT_MET", "PARTIALLY_MET", "MET", "COMPENSATING_CONTROL", "NOT_APPLICABLE"}
+
+// assessmentTransitions lets staff reassess a requirement from any result to
+// any other. Withdrawing a result that counted as met needs a reason.
+func assessmentTransitions() []Transition {
+	var transitions []Transition
+	for _, from := range assessmentResults {
+		for _, to := range assessmentResults {
+			if from == to {
+				continue
+			}
+			t := Transition{From: from, To: to, Roles: staff}
+			if from == "MET" || from == "COMPENSATING_CONTROL" {
+				t.RequiredFields = []string{"reason"}
+			}
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// Defaults are the built-in workflows used when neither the project nor the
+// installation has configured an override.
+var Defaults = map[string]Definition{
+	EntityProject: {
+		States:        []string{"NEW", "IN_PROGRESS", "COMPLETED", "ARCHIVED"},
+		InitialStates: []string{"NEW"},
+		Transitions: []Transition{
+			{From: "NEW", To: "IN_PROGRESS", Roles: staff},
+			{From: "IN_PROGRESS", To: "COMPLETED", Roles: staff},
+			{From: "COMPLETED", To: "IN_PROGRESS", Roles: staff, RequiredFields: []string{"reason"}},
+			{From: "COMPLETED", To: "ARCHIVED", Roles: staff},
+			{From: "ARCHIVED", To: "COMPLETED", Roles: []string{"ADMIN"}, RequiredFields: []string{"reason"}},
+		},
+	},
+	EntityRequirement: {
+		States:        assessmentResults,
+		InitialStates: []string{"NOT_TESTED", "NOT_MET", "MET", "NOT_APPLICABLE"},
+		Transitions:   assessmentTransitions(),
+	},
+	EntityAuditTask: {
+		States:        []string{"PENDING", "IN_PROGRESS", "PREPARED", "IN_REVIEW", "REVIEWED"},
+		InitialStates: []string{"PENDING"},
+		Transitions: []Transition{
+			{From: "PENDING", To: "IN_PROGRESS", Roles: staff},
+			{From: "IN_PROGRESS", To: "PENDING", Roles: staff},
+			// Four-eyes review: a second person signs off the testing
+			{From: "IN_PROGRESS", To: "PREPARED", Roles: staff},
+			{From: "PREPARED", To: "IN_PROGRESS", Roles: staff},
+			{From: "PREPARED", To: "IN_REVIEW", Roles: staff},
+			{From: "IN_REVIEW", To: "REVIEWED", Roles: staff},
+			{From: "IN_REVIEW", To: "IN_PROGRESS", Roles: staff, RequiredFields: []string{"reviewNotes"}},
+			{From: "REVIEWED", To: "IN_PROGRESS", Roles: staff, RequiredFields: []string{"reason"}},
+		},
+	},
+	EntityIssue: {
+		States:        []string{"OPEN", "IN_PROGRESS", "REMEDIATION_PLANNED", "REMEDIATED", "VALIDATED", "RESOLVED", "CLOSED"},
+		InitialStates: []string{"OPEN"},
+		Transitions: []Transition{
+			{From: "OPEN", To: "IN_PROGRESS"},
+			{From: "IN_PROGRESS", To: "OPEN"},
+			{From: "IN_PROGRESS", To: "RESOLVED"},
+			{From: "RESOLVED", To: "IN_PROGRESS", RequiredFields: []string{"reason"}},
+			{From: "RESOLVED", To: "CLOSED", Roles: staff, RequiredFields: []string{"resolutionNote"}},
+			{From: "OPEN", To: "CLOSED", Roles: staff, RequiredFields: []string{"resolutionNote"}},
+			{From: "CLOSED", To: "OPEN", Roles: staff, RequiredFields: []string{"reason"}},
+			// Remediation by the client, validated by a retest
+			{From: "OPEN", To: "REMEDIATION_PLANNED"},
+			{From: "IN_PROGRESS", To: "REMEDIATION_PLANNED"},
+			{From: "REMEDIATION_PLANNED", To: "REMEDIATED"},
+			{From: "REMEDIATED", To: "REMEDIATION_PLANNED", RequiredFields: []string{"reason"}},
+			{From: "REMEDIATED", To: "VALIDATED", Roles: staff},
+			{From: "VALIDATED", To: "REMEDIATION_PLANNED", Roles: staff, RequiredFields: []string{"reason"}},
+			{From: "VALIDATED", To: "CLOSED", Roles: staff, RequiredFields: []string{"resolutionNote"}},
+		},
+	},
+	EntityDocumentRequest: {
+		States:        []string{"REQUESTED", "SUBMITTED", "ACCEPTED", "REJECTED"},
+		InitialStates: []string{"REQUESTED"},
+		Transitions: []Transition{
+			{From: "REQUESTED", To: "SUBMITTED"},
+			{From: "REJECTED", To: "SUBMITTED"},
+			{From: "SUBMITTED", To: "ACCEPTED", Roles: staff},
+			{From: "SUBMITTED", To: "REJECTED", Roles: staff, RequiredFields: []string{"reason"}},
+			{From: "ACCEPTED", To: "REQUESTED", Roles: staff, RequiredFields: []string{"reason"}},
+		},
+	},
+}
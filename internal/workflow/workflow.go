@@ -0,0 +1,161 @@
+// Package workflow defines status workflows: the states an entity may be in,
+// the transitions allowed between them, the fields a transition requires and
+// the roles allowed to perform it.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Entity types that carry a workflow-managed status.
+const (
+	EntityProject         = "project"
+	EntityRequirement     = "requirement"
+	EntityAuditTask       = "auditTask"
+	EntityIssue           = "issue"
+	EntityDocumentRequest = "documentRequest"
+)
+
+// EntityTypes lists every entity type that has a workflow.
+var EntityTypes = []string{EntityProject, EntityRequirement, EntityAuditTask, EntityIssue, EntityDocumentRequest}
+
+var (
+	ErrIllegalTransition = errors.New("transition is not allowed by the workflow")
+	ErrRoleNotAllowed    = errors.New("role is not allowed to perform this transition")
+)
+
+// MissingFieldsError is returned when a transition is attempted without the
+// fields it requires, e.g. closing an issue without a resolution note.
+type MissingFieldsError struct {
+	Fields []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	return "missing required fields: " + strings.Join(e.Fields, ", ")
+}
+
+// PreconditionError is returned when a transition is allowed by the workflow
+// but the entity is not ready for it, e.g. validating an issue that has not
+// passed a retest.
+type PreconditionError struct {
+	Reason string
+}
+
+func (e *PreconditionError) Error() string {
+	return "precondition not met: " + e.Reason
+}
+
+// Transition is a single allowed move between two states.
+type Transition struct {
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	RequiredFields []string `json:"requiredFields,omitempty"`
+	Roles          []string `json:"roles,omitempty"` // empty means any role
+}
+
+// Definition is the workflow for one entity type.
+type Definition struct {
+	States        []string     `json:"states"`
+	InitialStates []string     `json:"initialStates"`
+	Transitions   []Transition `json:"transitions"`
+}
+
+// Validate checks that the definition is internally consistent and only uses
+// the given states and roles, which are the values the database accepts.
+func (d Definition) Validate(allowedStates, allowedRoles []string) error {
+	if len(d.States) == 0 {
+		return errors.New("workflow must define at least one state")
+	}
+	if len(d.InitialStates) == 0 {
+		return errors.New("workflow must define at least one initial state")
+	}
+
+	states := make(map[string]bool, len(d.States))
+	for _, state := range d.States {
+		if !contains(allowedStates, state) {
+			return fmt.Errorf("unknown state %q", state)
+		}
+		states[state] = true
+	}
+	for _, state := range d.InitialStates {
+		if !states[state] {
+			return fmt.Errorf("initial state %q is not a workflow state", state)
+		}
+	}
+
+	seen := make(map[string]bool, len(d.Transitions))
+	for _, t := range d.Transitions {
+		if !states[t.From] || !states[t.To] {
+			return fmt.Errorf("transition %s -> %s uses a state outside the workflow", t.From, t.To)
+		}
+		if t.From == t.To {
+			return fmt.Errorf("transition %s -> %s does not change state", t.From, t.To)
+		}
+		for _, role := range t.Roles {
+			if !contains(allowedRoles, role) {
+				return fmt.Errorf("transition %s -> %s uses unknown role %q", t.From, t.To, role)
+			}
+		}
+		key := t.From + "->" + t.To
+		if seen[key] {
+			return fmt.Errorf("transition %s -> %s is defined more than once", t.From, t.To)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// IsInitial reports whether a new entity may be created in the given state.
+func (d Definition) IsInitial(state string) bool {
+	return contains(d.InitialStates, state)
+}
+
+// Check validates a transition from one state to another for a caller with
+// the given role, returning the matching transition on success.
+func (d Definition) Check(from, to, role string, fields map[string]string) (*Transition, error) {
+	for i := range d.Transitions {
+		t := &d.Transitions[i]
+		if t.From != from || t.To != to {
+			continue
+		}
+
+		if len(t.Roles) > 0 && !contains(t.Roles, role) {
+			return nil, ErrRoleNotAllowed
+		}
+
+		var missing []string
+		for _, field := range t.RequiredFields {
+			if strings.TrimSpace(fields[field]) == "" {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, &MissingFieldsError{Fields: missing}
+		}
+
+		return t, nil
+	}
+	return nil, ErrIllegalTransition
+}
+
+// Available lists the transitions out of a state that the given role may take.
+func (d Definition) Available(from, role string) []Transition {
+	available := []Transition{}
+	for _, t := range d.Transitions {
+		if t.From == from && (len(t.Roles) == 0 || contains(t.Roles, role)) {
+			available = append(available, t)
+		}
+	}
+	return available
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}